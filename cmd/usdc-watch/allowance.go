@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"usdc-watch/internal/alert"
+	"usdc-watch/internal/eth"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/usdc"
+)
+
+// Allowance is a monitored owner/spender pair. It alerts when the allowance
+// is unlimited or, with a Limit, exceeds it.
+type Allowance struct {
+	Owner   string
+	Spender string
+	Limit   *big.Int
+}
+
+// allowanceTarget is the checking state of one owner/spender pair.
+type allowanceTarget struct {
+	Allowance
+	callData string
+	// exceeded is whether the allowance was over its limit when last read;
+	// nil until read.
+	exceeded *bool
+}
+
+// exceeds reports whether amount breaks the pair's rule.
+func (a Allowance) exceeds(amount *big.Int) bool {
+	return usdc.IsUnlimited(amount) || a.Limit != nil && amount.Cmp(a.Limit) > 0
+}
+
+// SetAllowances replaces the monitored owner/spender pairs. Pairs that remain
+// keep their last reading.
+func (w *Watcher) SetAllowances(allowances []Allowance) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.setAllowancesLocked(allowances)
+}
+
+func (w *Watcher) setAllowancesLocked(allowances []Allowance) error {
+	targets, err := w.allowanceTargetsLocked(allowances)
+	if err != nil {
+		return err
+	}
+	w.allowances = targets
+	return nil
+}
+
+// allowanceTargetsLocked validates allowances and builds their targets,
+// carrying over the state of pairs that remain monitored.
+func (w *Watcher) allowanceTargetsLocked(allowances []Allowance) ([]*allowanceTarget, error) {
+	current := make(map[string]*allowanceTarget, len(w.allowances))
+	for _, a := range w.allowances {
+		current[a.Owner+"/"+a.Spender] = a
+	}
+	targets := make([]*allowanceTarget, 0, len(allowances))
+	seen := make(map[string]bool, len(allowances))
+	for _, allowance := range allowances {
+		key := allowance.Owner + "/" + allowance.Spender
+		if seen[key] {
+			return nil, fmt.Errorf("allowance %s: duplicate pair", key)
+		}
+		seen[key] = true
+		callData, err := usdc.EncodeAllowanceCall(allowance.Owner, allowance.Spender)
+		if err != nil {
+			return nil, fmt.Errorf("allowance %s: encode call data: %w", key, err)
+		}
+		a := &allowanceTarget{Allowance: allowance, callData: callData}
+		if old, ok := current[key]; ok {
+			a.exceeded = old.exceeded
+		}
+		targets = append(targets, a)
+	}
+	return targets, nil
+}
+
+// approvalState is what the approval scan knows between poll cycles.
+type approvalState struct {
+	// block is the last block scanned for Approval events; zero before the
+	// first check.
+	block uint64
+}
+
+// checkAllowances reads every monitored allowance at the head block and, when
+// enabled, scans the Approval events of watched addresses and allowance owners
+// since the previous check for spenders without an allowance rule.
+func (w *Watcher) checkAllowances(ctx context.Context) {
+	w.mu.Lock()
+	pairs := append([]*allowanceTarget(nil), w.allowances...)
+	book := w.book
+	w.mu.Unlock()
+	if len(pairs) == 0 && !w.cfg.Approvals {
+		return
+	}
+	logger := w.cfg.Logger
+	checkCtx, cancel := context.WithTimeout(ctx, w.cfg.PollTimeout)
+	defer cancel()
+	head, endpoint, err := w.cfg.Client.BlockNumber(checkCtx)
+	if err != nil {
+		logger.Warn("allowance check failed", "endpoint", endpoint.Name, "error", err)
+		return
+	}
+
+	labels := make(map[string]string)
+	for _, t := range w.snapshot() {
+		labels[t.Address] = t.Label
+	}
+	known := make(map[string]bool, len(pairs))
+	for _, a := range pairs {
+		known[a.Owner+"/"+a.Spender] = true
+		if _, ok := labels[a.Owner]; !ok {
+			labels[a.Owner] = book.Label(a.Owner)
+		}
+	}
+
+	if w.cfg.Approvals {
+		if from := w.approvals.block + 1; w.approvals.block > 0 && from <= head {
+			if head-from >= maxTransferLookback {
+				from = head - maxTransferLookback + 1
+			}
+			owners := make([]string, 0, len(labels))
+			for owner := range labels {
+				owners = append(owners, owner)
+			}
+			sort.Strings(owners)
+			approvals, err := fetchApprovals(checkCtx, w.cfg.Client, owners, from, head)
+			if err != nil {
+				logger.Warn("approval lookup failed", "from_block", from, "to_block", head, "error", err)
+			} else {
+				w.approvals.block = head
+			}
+			for _, approval := range approvals {
+				if approval.Value.Sign() == 0 || known[approval.Owner+"/"+approval.Spender] {
+					continue
+				}
+				w.raiseChangeAlert(ctx, alert.Data{
+					Rule:      alert.RuleUnknownSpender,
+					Address:   approval.Owner,
+					Label:     labels[approval.Owner],
+					Chain:     w.cfg.Chain,
+					Spender:   approval.Spender,
+					Allowance: approval.Value,
+					Block:     approval.Block,
+					TxHash:    approval.TxHash,
+					Time:      w.clock.Now(),
+				}, "event")
+			}
+		} else if w.approvals.block == 0 {
+			w.approvals.block = head
+		}
+	}
+
+	for _, a := range pairs {
+		amount, endpoint, err := fetchAllowance(checkCtx, w.cfg.Client, a.callData, head)
+		if err != nil {
+			logger.Warn("allowance read failed", addressAttr(a.Owner), "spender", a.Spender, "endpoint", endpoint, "error", err)
+			continue
+		}
+		logger.Debug("allowance checked", addressAttr(a.Owner), "spender", a.Spender, "allowance", allowanceText(amount), "block", head)
+		exceeded := a.exceeds(amount)
+		w.mu.Lock()
+		previous := a.exceeded
+		a.exceeded = &exceeded
+		w.mu.Unlock()
+		if previous == nil && !exceeded || previous != nil && *previous == exceeded {
+			continue
+		}
+		w.raiseChangeAlert(ctx, alert.Data{
+			Rule:      alert.RuleAllowance,
+			Address:   a.Owner,
+			Label:     labels[a.Owner],
+			Chain:     w.cfg.Chain,
+			Spender:   a.Spender,
+			Allowance: amount,
+			Threshold: a.Limit,
+			Block:     head,
+			Endpoint:  endpoint,
+			Time:      w.clock.Now(),
+			Recovered: !exceeded,
+		}, "call")
+	}
+}
+
+// allowanceText formats an allowance for logs.
+func allowanceText(amount *big.Int) string {
+	if usdc.IsUnlimited(amount) {
+		return "unlimited"
+	}
+	return usdc.FormatAmount(amount)
+}
+
+// fetchAllowance reads the allowance encoded by callData at block and returns
+// the name of the endpoint that answered.
+func fetchAllowance(ctx context.Context, client *rpc.Client, callData string, block uint64) (*big.Int, string, error) {
+	result, endpoint, err := callUSDC(ctx, client, callData, block)
+	if err != nil {
+		return nil, endpoint, err
+	}
+	amount, err := usdc.DecodeAmount(result)
+	return amount, endpoint, err
+}
+
+// fetchApprovals returns the Approval events granted by owners in
+// [fromBlock, toBlock], in chain order.
+func fetchApprovals(ctx context.Context, client *rpc.Client, owners []string, fromBlock, toBlock uint64) ([]usdc.Approval, error) {
+	if len(owners) == 0 {
+		return nil, nil
+	}
+	topics := make([]string, 0, len(owners))
+	for _, owner := range owners {
+		topic, err := eth.AddressTopic(owner)
+		if err != nil {
+			return nil, err
+		}
+		topics = append(topics, topic)
+	}
+	logs, _, err := client.GetLogs(ctx, rpc.LogFilter{
+		FromBlock: fromBlock,
+		ToBlock:   toBlock,
+		Address:   usdc.ContractAddress,
+		Topics:    []interface{}{usdc.ApprovalTopic, topics},
+	})
+	if err != nil {
+		return nil, err
+	}
+	approvals := make([]usdc.Approval, 0, len(logs))
+	for _, log := range logs {
+		if log.Removed {
+			continue
+		}
+		approval, err := usdc.DecodeApproval(log)
+		if err != nil {
+			return nil, err
+		}
+		approvals = append(approvals, approval)
+	}
+	sort.Slice(approvals, func(i, j int) bool {
+		if approvals[i].Block != approvals[j].Block {
+			return approvals[i].Block < approvals[j].Block
+		}
+		return approvals[i].LogIndex < approvals[j].LogIndex
+	})
+	return approvals, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/rpctest"
+	"usdc-watch/internal/usdc"
+)
+
+func TestWatcherReportsAllowances(t *testing.T) {
+	const (
+		router  = "0x00000000000000000000000000000000000000c1"
+		bridge  = "0x00000000000000000000000000000000000000c2"
+		unknown = "0x00000000000000000000000000000000000000c9"
+		revoked = "0x00000000000000000000000000000000000000c8"
+	)
+	node := rpctest.NewNode(t)
+	node.SetHead(100)
+	node.SetAllowance(90, watchedAddress, router, big.NewInt(500_000_000), "0xa0")
+	node.SetAllowance(95, watchedAddress, bridge, usdc.MaxUint256, "0xa1")
+
+	messages := make(chan string, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		messages <- r.URL.Query().Get("message")
+	}))
+	defer webhook.Close()
+
+	client, err := rpc.NewClient([]config.Endpoint{node.Endpoint("node")}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	watcher, err := NewWatcher(WatcherConfig{
+		Logger:   discardLogger(),
+		Client:   client,
+		Watches:  []Watch{{Address: watchedAddress, Label: "ops", Threshold: big.NewInt(1)}},
+		Interval: time.Minute,
+		AlertURL: webhook.URL,
+		Allowances: []Allowance{
+			{Owner: watchedAddress, Spender: router, Limit: big.NewInt(1_000_000_000)},
+			{Owner: watchedAddress, Spender: bridge},
+		},
+		Approvals: true,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher error: %v", err)
+	}
+	drain := func() []string {
+		var got []string
+		for {
+			select {
+			case msg := <-messages:
+				got = append(got, msg)
+			default:
+				return got
+			}
+		}
+	}
+	ctx := context.Background()
+
+	watcher.checkAllowances(ctx)
+	if got, want := drain(), []string{"ops: USDC allowance for " + bridge + " is unlimited"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("first check alerts = %q, want %q", got, want)
+	}
+
+	node.SetAllowance(102, watchedAddress, router, big.NewInt(2_000_000_000), "0xa2")
+	node.SetAllowance(103, watchedAddress, unknown, big.NewInt(10_000_000), "0xa3")
+	node.SetAllowance(103, watchedAddress, revoked, big.NewInt(0), "0xa4")
+	node.SetHead(104)
+	watcher.checkAllowances(ctx)
+	want := []string{
+		"ops: " + watchedAddress + " approved unknown spender " + unknown + " for 10.000000 at block 103",
+		"ops: USDC allowance for " + router + " 2000.000000 > limit 1000.000000",
+	}
+	if got := drain(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("second check alerts = %q, want %q", got, want)
+	}
+
+	node.SetAllowance(105, watchedAddress, router, big.NewInt(0), "0xa5")
+	node.SetHead(105)
+	watcher.checkAllowances(ctx)
+	if got, want := drain(), []string{"ops: USDC allowance for " + router + " back within limit at 0.000000"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("recovery alerts = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"usdc-watch/internal/eth"
+	"usdc-watch/internal/history"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/state"
+	"usdc-watch/internal/usdc"
+)
+
+type addressResponse struct {
+	Address     string `json:"address"`
+	Balance     string `json:"balance,omitempty"`
+	BalanceRaw  string `json:"balance_raw,omitempty"`
+	Endpoint    string `json:"endpoint,omitempty"`
+	UpdatedAt   string `json:"updated_at,omitempty"`
+	LastError   string `json:"last_error,omitempty"`
+	LastErrorAt string `json:"last_error_at,omitempty"`
+}
+
+type observationResponse struct {
+	Time       string `json:"time"`
+	Block      uint64 `json:"block,omitempty"`
+	Balance    string `json:"balance"`
+	BalanceRaw string `json:"balance_raw"`
+	Endpoint   string `json:"endpoint"`
+}
+
+type endpointResponse struct {
+	Name                string  `json:"name"`
+	Healthy             bool    `json:"healthy"`
+	Requests            uint64  `json:"requests"`
+	Failures            uint64  `json:"failures"`
+	ConsecutiveFailures int     `json:"consecutive_failures"`
+	LastLatencyMS       float64 `json:"last_latency_ms"`
+	LastSuccess         string  `json:"last_success,omitempty"`
+	LastFailure         string  `json:"last_failure,omitempty"`
+	LastError           string  `json:"last_error,omitempty"`
+	Head                uint64  `json:"head,omitempty"`
+	Lag                 uint64  `json:"lag"`
+	Excluded            bool    `json:"excluded"`
+}
+
+// registerAPI mounts the read-only status API and the manual check trigger on mux.
+// A send on checks asks the watch loop to poll immediately. When series is
+// non-nil, history requests with from/to parameters are served from it.
+func registerAPI(mux *http.ServeMux, store *state.Store, series *history.Store, client *rpc.Client, checks chan<- struct{}) {
+	mux.HandleFunc("GET /v1/addresses", func(w http.ResponseWriter, r *http.Request) {
+		states := store.Addresses()
+		out := make([]addressResponse, 0, len(states))
+		for _, st := range states {
+			resp := addressResponse{
+				Address:     st.Address,
+				Endpoint:    st.Endpoint,
+				UpdatedAt:   formatTime(st.UpdatedAt),
+				LastError:   st.LastError,
+				LastErrorAt: formatTime(st.LastErrorAt),
+			}
+			if st.Balance != nil {
+				resp.Balance = usdc.FormatAmount(st.Balance)
+				resp.BalanceRaw = st.Balance.String()
+			}
+			out = append(out, resp)
+		}
+		writeJSON(w, http.StatusOK, out)
+	})
+
+	mux.HandleFunc("GET /v1/addresses/{addr}/history", func(w http.ResponseWriter, r *http.Request) {
+		address, err := eth.NormalizeAddress(r.PathValue("addr"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		observations, ok := store.History(address)
+		if !ok {
+			writeError(w, http.StatusNotFound, "address is not watched")
+			return
+		}
+		if series != nil && (r.URL.Query().Has("from") || r.URL.Query().Has("to")) {
+			from, to, err := parseRange(r, time.Now())
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			points, err := series.Query(address, from, to)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			observations = observations[:0:0]
+			for _, p := range points {
+				observations = append(observations, state.Observation{Time: p.Time, Balance: p.Balance, Endpoint: p.Endpoint, Block: p.Block})
+			}
+		}
+		if raw := r.URL.Query().Get("limit"); raw != "" {
+			limit, err := strconv.Atoi(raw)
+			if err != nil || limit <= 0 {
+				writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+				return
+			}
+			if limit < len(observations) {
+				observations = observations[len(observations)-limit:]
+			}
+		}
+		out := make([]observationResponse, 0, len(observations))
+		for _, obs := range observations {
+			out = append(out, observationResponse{
+				Time:       formatTime(obs.Time),
+				Block:      obs.Block,
+				Balance:    usdc.FormatAmount(obs.Balance),
+				BalanceRaw: obs.Balance.String(),
+				Endpoint:   obs.Endpoint,
+			})
+		}
+		writeJSON(w, http.StatusOK, out)
+	})
+
+	mux.HandleFunc("GET /v1/endpoints", func(w http.ResponseWriter, r *http.Request) {
+		health := client.Health()
+		out := make([]endpointResponse, 0, len(health))
+		for _, h := range health {
+			out = append(out, endpointResponse{
+				Name:                h.Name,
+				Healthy:             h.Healthy(),
+				Requests:            h.Requests,
+				Failures:            h.Failures,
+				ConsecutiveFailures: h.ConsecutiveFailures,
+				LastLatencyMS:       float64(h.LastLatency) / float64(time.Millisecond),
+				LastSuccess:         formatTime(h.LastSuccess),
+				LastFailure:         formatTime(h.LastFailure),
+				LastError:           h.LastError,
+				Head:                h.Head,
+				Lag:                 h.Lag,
+				Excluded:            h.Excluded,
+			})
+		}
+		writeJSON(w, http.StatusOK, out)
+	})
+
+	mux.HandleFunc("POST /v1/check", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case checks <- struct{}{}:
+			writeJSON(w, http.StatusAccepted, map[string]string{"status": "scheduled"})
+		default:
+			writeJSON(w, http.StatusAccepted, map[string]string{"status": "pending"})
+		}
+	})
+}
+
+// parseRange reads RFC 3339 from/to query parameters, defaulting to the last 24 hours.
+func parseRange(r *http.Request, now time.Time) (time.Time, time.Time, error) {
+	from, to := now.Add(-24*time.Hour), now
+	if raw := r.URL.Query().Get("from"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from: %w", err)
+		}
+		from = parsed
+	}
+	if raw := r.URL.Query().Get("to"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to: %w", err)
+		}
+		to = parsed
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("to must not be before from")
+	}
+	return from, to, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
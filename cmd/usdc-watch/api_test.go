@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/history"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/state"
+)
+
+func newTestAPI(t *testing.T, series *history.Store) (*httptest.Server, *state.Store, chan struct{}) {
+	t.Helper()
+	client, err := rpc.NewClient([]config.Endpoint{{Name: "primary", URL: "http://127.0.0.1:0"}}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	store := state.NewStore(10)
+	checks := make(chan struct{}, 1)
+	mux := http.NewServeMux()
+	registerAPI(mux, store, series, client, checks)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, store, checks
+}
+
+func TestAPIAddressesAndHistory(t *testing.T) {
+	server, store, _ := newTestAPI(t, nil)
+	address := "0x0000000000000000000000000000000000000001"
+	store.Record(address, state.Observation{Time: time.Unix(1_700_000_000, 0), Balance: big.NewInt(1_500_000), Endpoint: "primary"})
+	store.Record(address, state.Observation{Time: time.Unix(1_700_000_060, 0), Balance: big.NewInt(2_000_000), Endpoint: "primary"})
+
+	var addresses []addressResponse
+	getJSON(t, server.URL+"/v1/addresses", http.StatusOK, &addresses)
+	if len(addresses) != 1 || addresses[0].Balance != "2" || addresses[0].BalanceRaw != "2000000" {
+		t.Fatalf("unexpected addresses response: %+v", addresses)
+	}
+
+	var history []observationResponse
+	getJSON(t, server.URL+"/v1/addresses/0x0000000000000000000000000000000000000001/history?limit=1", http.StatusOK, &history)
+	if len(history) != 1 || history[0].Balance != "2" {
+		t.Fatalf("unexpected history response: %+v", history)
+	}
+
+	getJSON(t, server.URL+"/v1/addresses/0x0000000000000000000000000000000000000002/history", http.StatusNotFound, nil)
+	getJSON(t, server.URL+"/v1/addresses/nothex/history", http.StatusBadRequest, nil)
+}
+
+func TestAPIEndpointsAndCheck(t *testing.T) {
+	server, _, checks := newTestAPI(t, nil)
+
+	var endpoints []endpointResponse
+	getJSON(t, server.URL+"/v1/endpoints", http.StatusOK, &endpoints)
+	if len(endpoints) != 1 || endpoints[0].Name != "primary" || !endpoints[0].Healthy {
+		t.Fatalf("unexpected endpoints response: %+v", endpoints)
+	}
+
+	resp, err := http.Post(server.URL+"/v1/check", "application/json", nil)
+	if err != nil {
+		t.Fatalf("POST /v1/check error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected HTTP 202, got %d", resp.StatusCode)
+	}
+	select {
+	case <-checks:
+	default:
+		t.Fatalf("expected a check to be queued")
+	}
+}
+
+func getJSON(t *testing.T, url string, wantStatus int, out interface{}) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s error: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != wantStatus {
+		t.Fatalf("GET %s: expected HTTP %d, got %d", url, wantStatus, resp.StatusCode)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("decode %s: %v", url, err)
+		}
+	}
+}
+
+func TestAPIHistoryRangeFromSeries(t *testing.T) {
+	series, err := history.Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("history.Open error: %v", err)
+	}
+	defer series.Close()
+	server, store, _ := newTestAPI(t, series)
+	address := "0x0000000000000000000000000000000000000001"
+	store.Track(address)
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i := int64(0); i < 3; i++ {
+		series.Append(history.Point{Time: base.Add(time.Duration(i) * time.Hour), Address: address, Block: uint64(10 + i), Balance: big.NewInt(i * 1_000_000)})
+	}
+
+	var observations []observationResponse
+	getJSON(t, server.URL+"/v1/addresses/"+address+"/history?from=2026-10-01T12:30:00Z&to=2026-10-01T15:00:00Z", http.StatusOK, &observations)
+	if len(observations) != 2 || observations[0].Block != 11 || observations[1].Balance != "2" {
+		t.Fatalf("unexpected ranged history: %+v", observations)
+	}
+	getJSON(t, server.URL+"/v1/addresses/"+address+"/history?from=yesterday", http.StatusBadRequest, nil)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/eth"
+	"usdc-watch/internal/history"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/usdc"
+)
+
+// runBackfill implements the backfill subcommand: it samples historical balances
+// and transfers into the history store. Historical eth_call requires archive endpoints.
+func runBackfill(args []string) int {
+	fs := newFlagSet("backfill", "backfill --history-dir DIR --from-block N [flags] ADDRESS...",
+		"Read balances every --step blocks and all transfers between --from-block and --to-block into the history store.")
+	cfgPath := fs.String("config", "config/rpc_endpoints.toml", "Path to RPC endpoints configuration")
+	historyDir := fs.String("history-dir", "", "Directory holding the balance history (required)")
+	addresses := fs.String("address", "", "Comma-separated addresses (in addition to positional arguments)")
+	fromBlock := fs.Uint64("from-block", 0, "First block to backfill (required)")
+	toBlock := fs.Uint64("to-block", 0, "Last block to backfill (default: current head)")
+	step := fs.Uint64("step", 300, "Blocks between balance samples")
+	skipTransfers := fs.Bool("skip-transfers", false, "Only sample balances, do not scan Transfer logs")
+	logOpts := addLogFlags(fs)
+	if ok, code := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	logger, err := logOpts.logger(os.Stderr)
+	if err != nil {
+		return usageError(fs, "configure logging: %v", err)
+	}
+	if *historyDir == "" {
+		return usageError(fs, "--history-dir is required")
+	}
+	if *fromBlock == 0 {
+		return usageError(fs, "--from-block is required")
+	}
+	if *step == 0 {
+		return usageError(fs, "--step must be positive")
+	}
+	selected, err := parseAddressList(*addresses, fs.Args())
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+	if len(selected) == 0 {
+		return usageError(fs, "at least one address is required")
+	}
+
+	endpoints, err := config.LoadEndpoints(*cfgPath)
+	if err != nil {
+		logger.Error("load endpoints", "error", err)
+		return exitFailure
+	}
+	client, err := rpc.NewClient(endpoints, nil)
+	if err != nil {
+		logger.Error("build rpc client", "error", err)
+		return exitFailure
+	}
+	series, err := history.Open(*historyDir, 0)
+	if err != nil {
+		logger.Error("open balance history", "error", err)
+		return exitFailure
+	}
+	defer series.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	last := *toBlock
+	if last == 0 {
+		last, _, err = client.BlockNumber(ctx)
+		if err != nil {
+			logger.Error("resolve head block", "error", err)
+			return exitFailure
+		}
+	}
+	if last < *fromBlock {
+		return usageError(fs, "--to-block must not be before --from-block")
+	}
+
+	b := &backfiller{client: client, series: series, logger: logger, timestamps: make(map[uint64]time.Time)}
+	for _, address := range selected {
+		if err := b.balances(ctx, address, *fromBlock, last, *step); err != nil {
+			logger.Error("backfill balances failed", addressAttr(address), "error", err)
+			return exitFailure
+		}
+		if *skipTransfers {
+			continue
+		}
+		if err := b.transfers(ctx, address, *fromBlock, last); err != nil {
+			logger.Error("backfill transfers failed", addressAttr(address), "error", err)
+			return exitFailure
+		}
+	}
+	return exitOK
+}
+
+type backfiller struct {
+	client     *rpc.Client
+	series     *history.Store
+	logger     *slog.Logger
+	timestamps map[uint64]time.Time
+}
+
+func (b *backfiller) balances(ctx context.Context, address string, from, to, step uint64) error {
+	callData, err := usdc.EncodeBalanceOfCall(address)
+	if err != nil {
+		return err
+	}
+	count := 0
+	for block := from; ; block += step {
+		if block > to {
+			block = to
+		}
+		reading, err := fetchBalanceAt(ctx, b.client, callData, block)
+		if err != nil {
+			return fmt.Errorf("block %d: %w", block, err)
+		}
+		at, err := b.blockTime(ctx, block)
+		if err != nil {
+			return err
+		}
+		point := history.Point{Time: at, Address: address, Block: block, Balance: reading.Balance, Endpoint: reading.Endpoint}
+		if err := b.series.Append(point); err != nil {
+			return err
+		}
+		count++
+		if block == to {
+			break
+		}
+	}
+	b.logger.Info("balances backfilled", addressAttr(address), "samples", count)
+	return nil
+}
+
+func (b *backfiller) transfers(ctx context.Context, address string, from, to uint64) error {
+	count := 0
+	for start := from; start <= to; start += maxTransferLookback {
+		end := start + maxTransferLookback - 1
+		if end > to {
+			end = to
+		}
+		transfers, err := fetchTransfers(ctx, b.client, address, start, end)
+		if err != nil {
+			return fmt.Errorf("blocks %d-%d: %w", start, end, err)
+		}
+		for _, t := range transfers {
+			at, err := b.blockTime(ctx, t.Block)
+			if err != nil {
+				return err
+			}
+			record := history.Transfer{
+				Time:     at,
+				Address:  address,
+				Block:    t.Block,
+				TxHash:   t.TxHash,
+				LogIndex: t.LogIndex,
+				From:     t.From,
+				To:       t.To,
+				Value:    t.Value,
+			}
+			if err := b.series.AppendTransfer(record); err != nil {
+				return err
+			}
+			count++
+		}
+	}
+	b.logger.Info("transfers backfilled", addressAttr(address), "transfers", count)
+	return nil
+}
+
+// blockTime returns the timestamp of block, caching lookups.
+func (b *backfiller) blockTime(ctx context.Context, block uint64) (time.Time, error) {
+	if at, ok := b.timestamps[block]; ok {
+		return at, nil
+	}
+	header, _, err := b.client.HeaderByNumber(ctx, block)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("block %d header: %w", block, err)
+	}
+	seconds, err := eth.ParseQuantity(header.Timestamp)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("block %d timestamp: %w", block, err)
+	}
+	at := time.Unix(int64(seconds), 0).UTC()
+	b.timestamps[block] = at
+	return at, nil
+}
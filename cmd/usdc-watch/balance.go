@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/multicall"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/usdc"
+)
+
+type balanceResult struct {
+	Address    string `json:"address"`
+	Balance    string `json:"balance,omitempty"`
+	BalanceRaw string `json:"balance_raw,omitempty"`
+	Block      uint64 `json:"block"`
+	Endpoint   string `json:"endpoint,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// runBalance implements the balance subcommand: a one-shot query for one or more addresses.
+func runBalance(args []string) int {
+	fs := newFlagSet("balance", "balance [flags] ADDRESS...",
+		"Print the current USDC balance of each address, all read at the same block.")
+	cfgPath := fs.String("config", "config/rpc_endpoints.toml", "Path to RPC endpoints configuration")
+	addresses := fs.String("address", "", "Comma-separated addresses (in addition to positional arguments)")
+	format := fs.String("format", "table", "Output format: table or json")
+	timeout := fs.Duration("timeout", 30*time.Second, "Overall timeout for the query")
+	useMulticall := fs.Bool("multicall", true, "Batch the balanceOf calls through Multicall3, falling back to one call per address where it is not deployed or fails")
+	logOpts := addLogFlags(fs)
+	if ok, code := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	logger, err := logOpts.logger(os.Stderr)
+	if err != nil {
+		return usageError(fs, "configure logging: %v", err)
+	}
+	selected, err := parseAddressList(*addresses, fs.Args())
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+	if len(selected) == 0 {
+		return usageError(fs, "at least one address is required")
+	}
+	if *format != "table" && *format != "json" {
+		return usageError(fs, "unknown format %q (expected table or json)", *format)
+	}
+
+	endpoints, err := config.LoadEndpoints(*cfgPath)
+	if err != nil {
+		logger.Error("load endpoints", "error", err)
+		return exitFailure
+	}
+	client, err := rpc.NewClient(endpoints, nil)
+	if err != nil {
+		logger.Error("build rpc client", "error", err)
+		return exitFailure
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	block, _, err := client.BlockNumber(ctx)
+	if err != nil {
+		logger.Error("resolve head block", "error", err)
+		return exitFailure
+	}
+
+	var results []balanceResult
+	if *useMulticall {
+		results = readBalancesMulticall(ctx, logger, client, selected, block)
+	} else {
+		results = readBalances(ctx, client, selected, block)
+	}
+	failed := false
+	for _, result := range results {
+		failed = failed || result.Error != ""
+	}
+
+	if err := writeBalances(os.Stdout, *format, results); err != nil {
+		logger.Error("write output", "error", err)
+		return exitFailure
+	}
+	if failed {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// readBalances reads each address's balance at block with its own eth_call.
+func readBalances(ctx context.Context, client *rpc.Client, addresses []string, block uint64) []balanceResult {
+	results := make([]balanceResult, 0, len(addresses))
+	for _, address := range addresses {
+		result := balanceResult{Address: address, Block: block}
+		callData, err := usdc.EncodeBalanceOfCall(address)
+		if err == nil {
+			var reading balanceReading
+			reading, err = fetchBalanceAt(ctx, client, callData, block)
+			if err == nil {
+				result.Balance = usdc.FormatAmount(reading.Balance)
+				result.BalanceRaw = reading.Balance.String()
+				result.Endpoint = reading.Endpoint
+			}
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// readBalancesMulticall reads every balance at block through Multicall3,
+// falling back to one eth_call per address when the multicall read fails as
+// a whole.
+func readBalancesMulticall(ctx context.Context, logger *slog.Logger, client *rpc.Client, addresses []string, block uint64) []balanceResult {
+	balances, endpoint, err := multicall.New(client, multicall.Options{}).Balances(ctx, addresses, block)
+	if err != nil {
+		logger.Warn("multicall balance read failed, reading each address on its own", "endpoint", endpoint.Name, "error", err)
+		return readBalances(ctx, client, addresses, block)
+	}
+	results := make([]balanceResult, len(addresses))
+	for i, address := range addresses {
+		results[i] = balanceResult{Address: address, Block: block, Endpoint: endpoint.Name}
+		if balances[i].Err != nil {
+			results[i].Error = balances[i].Err.Error()
+			continue
+		}
+		results[i].Balance = usdc.FormatAmount(balances[i].Amount)
+		results[i].BalanceRaw = balances[i].Amount.String()
+	}
+	return results
+}
+
+func writeBalances(w io.Writer, format string, results []balanceResult) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ADDRESS\tBALANCE\tBLOCK\tENDPOINT\tERROR")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", r.Address, r.Balance, r.Block, r.Endpoint, r.Error)
+	}
+	return tw.Flush()
+}
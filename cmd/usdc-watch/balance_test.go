@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/multicall"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/rpctest"
+	"usdc-watch/internal/usdc"
+)
+
+func TestReadBalancesMulticall(t *testing.T) {
+	const other = "0x00000000000000000000000000000000000000e1"
+	for _, deployed := range []bool{true, false} {
+		node := rpctest.NewNode(t)
+		node.SetHead(50)
+		node.SetBalance(watchedAddress, 40, big.NewInt(2_500_000))
+		if deployed {
+			node.DeployMulticall(multicall.Address)
+		}
+		client, err := rpc.NewClient([]config.Endpoint{node.Endpoint("node")}, nil)
+		if err != nil {
+			t.Fatalf("NewClient error: %v", err)
+		}
+		addresses := []string{watchedAddress, other}
+		got := readBalancesMulticall(context.Background(), discardLogger(), client, addresses, 50)
+		want := readBalances(context.Background(), client, addresses, 50)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("deployed=%v: multicall read %+v, individual calls %+v", deployed, got, want)
+		}
+		if got[0].BalanceRaw != "2500000" || got[1].BalanceRaw != "0" {
+			t.Fatalf("deployed=%v: balances %+v", deployed, got)
+		}
+	}
+}
+
+func TestReadBalancesMulticallFallsBack(t *testing.T) {
+	node := rpctest.NewNode(t)
+	node.SetHead(50)
+	node.SetBalance(watchedAddress, 40, big.NewInt(2_500_000))
+	node.InjectFault(rpctest.Fault{Methods: []string{"eth_getCode"}, RPCError: &rpctest.RPCError{Code: -32601, Message: "method not found"}})
+	client, err := rpc.NewClient([]config.Endpoint{node.Endpoint("node")}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	got := readBalancesMulticall(context.Background(), discardLogger(), client, []string{watchedAddress}, 50)
+	if len(got) != 1 || got[0].Error != "" || got[0].BalanceRaw != "2500000" {
+		t.Fatalf("balances %+v; want a read one address at a time", got)
+	}
+}
+
+func TestFetchBalanceReadsFromHeadEndpoint(t *testing.T) {
+	ahead, lagging := rpctest.NewNode(t), rpctest.NewNode(t)
+	ahead.SetHead(100)
+	lagging.SetHead(90)
+	for _, node := range []*rpctest.Node{ahead, lagging} {
+		node.SetBalance(watchedAddress, 10, big.NewInt(7_000_000))
+	}
+	client, err := rpc.NewClient([]config.Endpoint{ahead.Endpoint("ahead"), lagging.Endpoint("lagging")}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	callData, err := usdc.EncodeBalanceOfCall(watchedAddress)
+	if err != nil {
+		t.Fatalf("EncodeBalanceOfCall error: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		reading, err := fetchBalance(context.Background(), client, callData)
+		if err != nil {
+			t.Fatalf("fetchBalance error: %v", err)
+		}
+		if reading.Balance.Cmp(big.NewInt(7_000_000)) != 0 {
+			t.Fatalf("balance %s, want 7000000", reading.Balance)
+		}
+	}
+	for name, node := range map[string]*rpctest.Node{"ahead": ahead, "lagging": lagging} {
+		if calls, heads := node.Requests("eth_call"), node.Requests("eth_blockNumber"); calls != heads {
+			t.Fatalf("%s answered %d eth_calls for %d head reads", name, calls, heads)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const configUsage = `Usage: usdc-watch config <command> [flags]
+
+Commands:
+  validate   Parse and check the configuration file without contacting endpoints
+`
+
+// runConfig dispatches the config subcommands.
+func runConfig(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, configUsage)
+		return exitUsage
+	}
+	switch args[0] {
+	case "validate":
+		return runConfigValidate(args[1:])
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, configUsage)
+		return exitOK
+	default:
+		fmt.Fprintf(os.Stderr, "unknown config command %q\n\n%s", args[0], configUsage)
+		return exitUsage
+	}
+}
+
+// runConfigValidate exits with exitFailure when the configuration cannot be used.
+func runConfigValidate(args []string) int {
+	fs := newFlagSet("config validate", "config validate [flags]",
+		"Parse the configuration file and report every problem found.")
+	cfgPath := fs.String("config", "config/rpc_endpoints.toml", "Path to RPC endpoints configuration")
+	if ok, code := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	cfg, err := loadConfig(*cfgPath, "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *cfgPath, err)
+		return exitFailure
+	}
+	fmt.Fprintf(os.Stdout, "%s: ok (%d endpoints, %d watches)\n", *cfgPath, len(cfg.Endpoints), len(cfg.Watches))
+	return exitOK
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/rpc"
+)
+
+const endpointsUsage = `Usage: usdc-watch endpoints <command> [flags]
+
+Commands:
+  check   Probe every configured endpoint for latency, chain ID and head block
+  bench   Sample every endpoint over a window and report latency percentiles and head lag
+`
+
+// runEndpoints dispatches the endpoints subcommands.
+func runEndpoints(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, endpointsUsage)
+		return exitUsage
+	}
+	switch args[0] {
+	case "check":
+		return runEndpointsCheck(args[1:])
+	case "bench":
+		return runEndpointsBench(args[1:])
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, endpointsUsage)
+		return exitOK
+	default:
+		fmt.Fprintf(os.Stderr, "unknown endpoints command %q\n\n%s", args[0], endpointsUsage)
+		return exitUsage
+	}
+}
+
+type endpointProbe struct {
+	Name      string  `json:"name"`
+	OK        bool    `json:"ok"`
+	LatencyMS float64 `json:"latency_ms"`
+	ChainID   uint64  `json:"chain_id,omitempty"`
+	Head      uint64  `json:"head,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// runEndpointsCheck probes each endpoint independently and exits non-zero if any fails.
+func runEndpointsCheck(args []string) int {
+	fs := newFlagSet("endpoints check", "endpoints check [flags]",
+		"Query eth_chainId and eth_blockNumber on every configured endpoint and report the results.")
+	cfgPath := fs.String("config", "config/rpc_endpoints.toml", "Path to RPC endpoints configuration")
+	format := fs.String("format", "table", "Output format: table or json")
+	timeout := fs.Duration("timeout", 10*time.Second, "Per-endpoint timeout")
+	logOpts := addLogFlags(fs)
+	if ok, code := parseFlags(fs, args); !ok {
+		return code
+	}
+	logger, err := logOpts.logger(os.Stderr)
+	if err != nil {
+		return usageError(fs, "configure logging: %v", err)
+	}
+	if *format != "table" && *format != "json" {
+		return usageError(fs, "unknown format %q (expected table or json)", *format)
+	}
+
+	endpoints, err := config.LoadEndpoints(*cfgPath)
+	if err != nil {
+		logger.Error("load endpoints", "error", err)
+		return exitFailure
+	}
+
+	probes := probeEndpoints(context.Background(), endpoints, *timeout)
+	if err := writeProbes(os.Stdout, *format, probes); err != nil {
+		logger.Error("write output", "error", err)
+		return exitFailure
+	}
+	for _, p := range probes {
+		if !p.OK {
+			return exitFailure
+		}
+	}
+	return exitOK
+}
+
+// probeEndpoints checks every endpoint concurrently, returning results in configuration order.
+func probeEndpoints(ctx context.Context, endpoints []config.Endpoint, timeout time.Duration) []endpointProbe {
+	probes := make([]endpointProbe, len(endpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			probeCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			probes[i] = probeEndpoint(probeCtx, endpoint)
+		}()
+	}
+	wg.Wait()
+	return probes
+}
+
+func probeEndpoint(ctx context.Context, endpoint config.Endpoint) endpointProbe {
+	probe := endpointProbe{Name: endpoint.Name}
+	client, err := rpc.NewClient([]config.Endpoint{endpoint}, nil)
+	if err != nil {
+		probe.Error = err.Error()
+		return probe
+	}
+	chainID, _, err := client.ChainID(ctx)
+	if err != nil {
+		probe.Error = err.Error()
+		return probe
+	}
+	started := time.Now()
+	head, _, err := client.BlockNumber(ctx)
+	probe.LatencyMS = float64(time.Since(started)) / float64(time.Millisecond)
+	if err != nil {
+		probe.Error = err.Error()
+		return probe
+	}
+	probe.OK = true
+	probe.ChainID = chainID
+	probe.Head = head
+	return probe
+}
+
+func writeProbes(w io.Writer, format string, probes []endpointProbe) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(probes)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSTATUS\tLATENCY\tCHAIN\tHEAD\tERROR")
+	for _, p := range probes {
+		status := "ok"
+		if !p.OK {
+			status = "fail"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.0fms\t%d\t%d\t%s\n", p.Name, status, p.LatencyMS, p.ChainID, p.Head, p.Error)
+	}
+	return tw.Flush()
+}
+
+type endpointBench struct {
+	Name      string  `json:"name"`
+	Samples   int     `json:"samples"`
+	Errors    int     `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+	P50MS     float64 `json:"p50_ms"`
+	P95MS     float64 `json:"p95_ms"`
+	Head      uint64  `json:"head,omitempty"`
+	MaxLag    uint64  `json:"max_lag"`
+}
+
+// runEndpointsBench samples eth_blockNumber on every endpoint for a window.
+func runEndpointsBench(args []string) int {
+	fs := newFlagSet("endpoints bench", "endpoints bench [flags]",
+		"Call eth_blockNumber on every endpoint each --interval for --duration and report\n"+
+			"p50/p95 latency, error rate and the largest lag behind the highest head seen.")
+	cfgPath := fs.String("config", "config/rpc_endpoints.toml", "Path to RPC endpoints configuration")
+	format := fs.String("format", "table", "Output format: table or json")
+	duration := fs.Duration("duration", time.Minute, "Sampling window")
+	interval := fs.Duration("interval", 5*time.Second, "Time between samples")
+	timeout := fs.Duration("timeout", 5*time.Second, "Per-request timeout")
+	logOpts := addLogFlags(fs)
+	if ok, code := parseFlags(fs, args); !ok {
+		return code
+	}
+	logger, err := logOpts.logger(os.Stderr)
+	if err != nil {
+		return usageError(fs, "configure logging: %v", err)
+	}
+	if *format != "table" && *format != "json" {
+		return usageError(fs, "unknown format %q (expected table or json)", *format)
+	}
+	if *duration <= 0 || *interval <= 0 {
+		return usageError(fs, "--duration and --interval must be positive")
+	}
+
+	endpoints, err := config.LoadEndpoints(*cfgPath)
+	if err != nil {
+		logger.Error("load endpoints", "error", err)
+		return exitFailure
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *duration)
+	defer cancel()
+
+	results := benchEndpoints(ctx, endpoints, *interval, *timeout)
+	if err := writeBench(os.Stdout, *format, results); err != nil {
+		logger.Error("write output", "error", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// benchEndpoints samples every endpoint until ctx is done, then summarises the samples.
+func benchEndpoints(ctx context.Context, endpoints []config.Endpoint, interval, timeout time.Duration) []endpointBench {
+	latencies := make([][]time.Duration, len(endpoints))
+	errorsSeen := make([]int, len(endpoints))
+	heads := make([]uint64, len(endpoints))
+	maxLag := make([]uint64, len(endpoints))
+
+	clients := make([]*rpc.Client, len(endpoints))
+	for i, endpoint := range endpoints {
+		clients[i], _ = rpc.NewClient([]config.Endpoint{endpoint}, nil)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		round := make([]uint64, len(endpoints))
+		var wg sync.WaitGroup
+		for i := range endpoints {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sampleCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+				defer cancel()
+				started := time.Now()
+				head, _, err := clients[i].BlockNumber(sampleCtx)
+				if err != nil {
+					errorsSeen[i]++
+					return
+				}
+				latencies[i] = append(latencies[i], time.Since(started))
+				round[i] = head
+			}()
+		}
+		wg.Wait()
+
+		var best uint64
+		for _, head := range round {
+			best = max(best, head)
+		}
+		for i, head := range round {
+			if head == 0 {
+				continue
+			}
+			heads[i] = head
+			maxLag[i] = max(maxLag[i], best-head)
+		}
+
+		select {
+		case <-ctx.Done():
+			results := make([]endpointBench, len(endpoints))
+			for i, endpoint := range endpoints {
+				samples := len(latencies[i]) + errorsSeen[i]
+				r := endpointBench{
+					Name:    endpoint.Name,
+					Samples: samples,
+					Errors:  errorsSeen[i],
+					P50MS:   percentileMS(latencies[i], 0.50),
+					P95MS:   percentileMS(latencies[i], 0.95),
+					Head:    heads[i],
+					MaxLag:  maxLag[i],
+				}
+				if samples > 0 {
+					r.ErrorRate = float64(errorsSeen[i]) / float64(samples)
+				}
+				results[i] = r
+			}
+			return results
+		case <-ticker.C:
+		}
+	}
+}
+
+// percentileMS returns the nearest-rank percentile of samples in milliseconds.
+func percentileMS(samples []time.Duration, p float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	rank = max(0, min(rank, len(sorted)-1))
+	return float64(sorted[rank]) / float64(time.Millisecond)
+}
+
+func writeBench(w io.Writer, format string, results []endpointBench) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSAMPLES\tERROR_RATE\tP50\tP95\tHEAD\tMAX_LAG")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%d\t%.0f%%\t%.0fms\t%.0fms\t%d\t%d\n", r.Name, r.Samples, r.ErrorRate*100, r.P50MS, r.P95MS, r.Head, r.MaxLag)
+	}
+	return tw.Flush()
+}
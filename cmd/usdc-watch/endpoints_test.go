@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"usdc-watch/internal/config"
+)
+
+func TestPercentileMS(t *testing.T) {
+	var samples []time.Duration
+	for i := 1; i <= 20; i++ {
+		samples = append(samples, time.Duration(i)*time.Millisecond)
+	}
+	if got := percentileMS(samples, 0.50); got != 10 {
+		t.Fatalf("p50 = %v, expected 10", got)
+	}
+	if got := percentileMS(samples, 0.95); got != 19 {
+		t.Fatalf("p95 = %v, expected 19", got)
+	}
+	if got := percentileMS(nil, 0.95); got != 0 {
+		t.Fatalf("p95 of no samples = %v, expected 0", got)
+	}
+}
+
+func TestBenchEndpoints(t *testing.T) {
+	serve := func(head int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":"0x%x"}`, head)
+		}))
+	}
+	fresh, stale := serve(100), serve(95)
+	defer fresh.Close()
+	defer stale.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer broken.Close()
+
+	endpoints := []config.Endpoint{{Name: "fresh", URL: fresh.URL}, {Name: "stale", URL: stale.URL}, {Name: "broken", URL: broken.URL}}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	results := benchEndpoints(ctx, endpoints, 10*time.Millisecond, time.Second)
+
+	if results[0].MaxLag != 0 || results[0].Errors != 0 || results[0].Samples == 0 {
+		t.Fatalf("unexpected fresh result: %+v", results[0])
+	}
+	if results[1].MaxLag != 5 || results[1].Head != 95 {
+		t.Fatalf("unexpected stale result: %+v", results[1])
+	}
+	if results[2].ErrorRate != 1 {
+		t.Fatalf("unexpected broken result: %+v", results[2])
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"usdc-watch/internal/alert"
+)
+
+// maxEnrichedTransactions bounds the transactions looked up for one alert;
+// the newest are kept.
+const maxEnrichedTransactions = 5
+
+// lookupTransactions fetches the transaction and receipt behind each hash,
+// skipping duplicates and any that cannot be read, so an alert can tell who
+// caused it: the sender, the contract called, gas used and status.
+func (w *Watcher) lookupTransactions(ctx context.Context, logger *slog.Logger, hashes []string) []alert.Transaction {
+	seen := make(map[string]bool, len(hashes))
+	unique := make([]string, 0, len(hashes))
+	for _, hash := range hashes {
+		if hash != "" && !seen[hash] {
+			seen[hash] = true
+			unique = append(unique, hash)
+		}
+	}
+	if len(unique) > maxEnrichedTransactions {
+		unique = unique[len(unique)-maxEnrichedTransactions:]
+	}
+	lookupCtx, cancel := context.WithTimeout(ctx, w.cfg.PollTimeout)
+	defer cancel()
+	var out []alert.Transaction
+	for _, hash := range unique {
+		tx, endpoint, err := w.cfg.Client.TransactionByHash(lookupCtx, hash)
+		if err != nil {
+			logger.Warn("transaction lookup failed", "tx", hash, "endpoint", endpoint.Name, "error", err)
+			continue
+		}
+		receipt, endpoint, err := w.cfg.Client.TransactionReceipt(lookupCtx, hash)
+		if err != nil {
+			logger.Warn("receipt lookup failed", "tx", hash, "endpoint", endpoint.Name, "error", err)
+			continue
+		}
+		contract := tx.To
+		if contract == "" {
+			contract = receipt.ContractAddress
+		}
+		out = append(out, alert.Transaction{
+			Hash:     tx.Hash,
+			Sender:   tx.From,
+			Contract: contract,
+			Method:   tx.Selector(),
+			GasUsed:  receipt.GasUsed,
+			Success:  receipt.Success,
+		})
+	}
+	return out
+}
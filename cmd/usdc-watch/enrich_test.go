@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/rpctest"
+	"usdc-watch/internal/usdc"
+)
+
+func TestWatcherEnrichesAlertsWithTransactions(t *testing.T) {
+	const (
+		alice  = "0x00000000000000000000000000000000000000e1"
+		bob    = "0x00000000000000000000000000000000000000e2"
+		router = "0x00000000000000000000000000000000000000f1"
+	)
+	deposit := "0x" + strings.Repeat("c1", 32)
+	whale := "0x" + strings.Repeat("c2", 32)
+	node := rpctest.NewNode(t)
+	node.SetHead(100)
+
+	messages := make(chan string, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		messages <- r.URL.Query().Get("message")
+	}))
+	defer webhook.Close()
+
+	client, err := rpc.NewClient([]config.Endpoint{node.Endpoint("node")}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	watcher, err := NewWatcher(WatcherConfig{
+		Logger:   discardLogger(),
+		Client:   client,
+		Watches:  []Watch{{Address: watchedAddress, Threshold: big.NewInt(5_000_000)}},
+		Interval: time.Minute,
+		AlertURL: webhook.URL,
+		Whales:   &WhaleWatch{MinAmount: big.NewInt(1_000_000_000)},
+		Enrich:   true,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher error: %v", err)
+	}
+	ctx := context.Background()
+	watcher.Poll(ctx)
+	watcher.checkWhales(ctx)
+
+	node.SetBalance(watchedAddress, 102, big.NewInt(5_000_000))
+	node.AddTransfer(102, alice, watchedAddress, big.NewInt(5_000_000), deposit)
+	node.AddTransaction(rpctest.Transaction{Hash: deposit, Block: 102, From: alice, To: usdc.ContractAddress, GasUsed: 51000})
+	node.AddTransfer(102, alice, bob, big.NewInt(2_000_000_000), whale)
+	node.AddTransaction(rpctest.Transaction{Hash: whale, Block: 102, From: bob, To: router, GasUsed: 120000, Failed: true})
+	node.SetHead(103)
+	watcher.Poll(ctx)
+	watcher.checkWhales(ctx)
+
+	var got []string
+	for len(messages) > 0 {
+		got = append(got, <-messages)
+	}
+	contract := strings.ToLower(usdc.ContractAddress)
+	want := []string{
+		"USDC balance 5.000000 >= threshold 5.000000 [tx 0xc1c1…c1c1 by " + alice + " via " + contract + ", gas 51000]",
+		"USDC transfer of 2000.000000 from " + alice + " to " + bob + " at block 102 [tx 0xc2c2…c2c2 by " + bob + " via " + router + ", gas 120000, failed]",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("alerts = %q, want %q", got, want)
+	}
+}
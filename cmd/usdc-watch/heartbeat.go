@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HeartbeatConfig configures dead-man's-switch pings to an external monitor
+// such as healthchecks.io.
+type HeartbeatConfig struct {
+	// URL is pinged after successful poll cycles; URL + "/fail" after failed ones.
+	URL string
+	// Interval is the minimum time between pings while the outcome is
+	// unchanged. Zero pings after every cycle.
+	Interval time.Duration
+	// Payload, when set, is sent as the body of a POST instead of a plain GET.
+	Payload string
+}
+
+// heartbeatState remembers the last ping so pings can be throttled.
+type heartbeatState struct {
+	sent   bool
+	at     time.Time
+	failed bool
+}
+
+// heartbeat pings the monitor for a finished poll cycle. Pings are throttled to
+// the configured interval unless the cycle outcome changed.
+func (w *Watcher) heartbeat(ctx context.Context, failed bool) {
+	cfg, last := w.cfg.Heartbeat, &w.beat
+	if cfg.URL == "" {
+		return
+	}
+	now := w.clock.Now()
+	if last.sent && last.failed == failed && now.Sub(last.at) < cfg.Interval {
+		return
+	}
+	target := cfg.URL
+	if failed {
+		target = strings.TrimSuffix(cfg.URL, "/") + "/fail"
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, w.cfg.AlertTimeout)
+	defer cancel()
+	if err := sendHeartbeat(pingCtx, w.cfg.AlertClient, target, cfg.Payload); err != nil {
+		w.cfg.Logger.Warn("heartbeat failed", "url", target, "error", err)
+		return
+	}
+	*last = heartbeatState{sent: true, at: now, failed: failed}
+	w.cfg.Logger.Debug("heartbeat sent", "url", target, "failed", failed)
+}
+
+func sendHeartbeat(ctx context.Context, client *http.Client, target, payload string) error {
+	method, body := http.MethodGet, io.Reader(nil)
+	if payload != "" {
+		method, body = http.MethodPost, strings.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return fmt.Errorf("build heartbeat request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send heartbeat request: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("heartbeat request failed with HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
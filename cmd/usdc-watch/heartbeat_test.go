@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"usdc-watch/internal/clock"
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/rpctest"
+)
+
+func TestWatcherHeartbeat(t *testing.T) {
+	node := rpctest.NewNode(t)
+	node.SetHead(100)
+	node.SetBalance(watchedAddress, 0, big.NewInt(500_000))
+
+	pings := make(chan string, 10)
+	monitor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		pings <- r.Method + " " + r.URL.Path + " " + string(body)
+	}))
+	defer monitor.Close()
+
+	client, err := rpc.NewClient([]config.Endpoint{node.Endpoint("node")}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	watcher, err := NewWatcher(WatcherConfig{
+		Logger:    discardLogger(),
+		Client:    client,
+		Watches:   []Watch{{Address: watchedAddress, Threshold: big.NewInt(1_000_000)}},
+		Interval:  time.Minute,
+		Heartbeat: HeartbeatConfig{URL: monitor.URL + "/ping/abc", Interval: 3 * time.Minute, Payload: "usdc-watch"},
+		Clock:     fake,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- watcher.Run(ctx) }()
+
+	// Polls at 0m..3m succeed, 4m fails, 5m succeeds again.
+	for minute := 1; minute <= 5; minute++ {
+		fake.BlockUntil(1)
+		switch minute {
+		case 4:
+			node.InjectFault(rpctest.Fault{HTTPStatus: http.StatusServiceUnavailable})
+		case 5:
+			node.ClearFaults()
+		}
+		fake.Advance(time.Minute)
+	}
+	fake.BlockUntil(1)
+	cancel()
+	<-done
+	close(pings)
+
+	var got []string
+	for ping := range pings {
+		got = append(got, ping)
+	}
+	want := []string{
+		"POST /ping/abc usdc-watch",
+		"POST /ping/abc usdc-watch",
+		"POST /ping/abc/fail usdc-watch",
+		"POST /ping/abc usdc-watch",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("pings = %q, want %q", got, want)
+	}
+}
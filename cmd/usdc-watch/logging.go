@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"strings"
+	"time"
+
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/usdc"
+)
+
+// newLogger builds a slog logger writing to w in the requested format ("text" or "json").
+func newLogger(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q (expected json or text)", format)
+	}
+}
+
+// Structured field helpers keep attribute names consistent across log events.
+
+func addressAttr(address string) slog.Attr {
+	return slog.String("address", address)
+}
+
+// labelAttr names an address from the address book; it is omitted when empty.
+func labelAttr(label string) slog.Attr {
+	if label == "" {
+		return slog.Attr{}
+	}
+	return slog.String("label", label)
+}
+
+func balanceAttrs(balance *big.Int) []any {
+	return []any{
+		slog.String("balance", usdc.FormatAmount(balance)),
+		slog.String("balance_raw", balance.String()),
+	}
+}
+
+func latencyAttr(d time.Duration) slog.Attr {
+	return slog.Float64("latency_ms", float64(d)/float64(time.Millisecond))
+}
+
+// requestLogger logs failed endpoint requests as structured events.
+type requestLogger struct {
+	logger *slog.Logger
+}
+
+// ObserveRequest implements rpc.Observer.
+func (l requestLogger) ObserveRequest(endpoint config.Endpoint, method string, latency time.Duration, err error) {
+	if err == nil {
+		l.logger.Debug("rpc request", "endpoint", endpoint.Name, "method", method, latencyAttr(latency))
+		return
+	}
+	l.logger.Warn("rpc request failed", "endpoint", endpoint.Name, "method", method, latencyAttr(latency), "error", err)
+}
+
+// requestObservers fans request outcomes out to several observers.
+type requestObservers []rpc.Observer
+
+// ObserveRequest implements rpc.Observer.
+func (o requestObservers) ObserveRequest(endpoint config.Endpoint, method string, latency time.Duration, err error) {
+	for _, observer := range o {
+		observer.ObserveRequest(endpoint, method, latency, err)
+	}
+}
@@ -3,17 +3,16 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"math/big"
 	"net/http"
 	"net/url"
 	"os"
-	"os/signal"
 	"strings"
-	"syscall"
-	"time"
 
 	"usdc-watch/internal/config"
 	"usdc-watch/internal/eth"
@@ -21,143 +20,186 @@ import (
 	"usdc-watch/internal/usdc"
 )
 
-func main() {
-	cfgPath := flag.String("config", "config/rpc_endpoints.toml", "Path to RPC endpoints configuration")
-	addressFlag := flag.String("address", "", "Ethereum wallet address to monitor (hex)")
-	thresholdFlag := flag.String("threshold", "", "Alert threshold in USDC (supports up to 6 decimals)")
-	intervalFlag := flag.Duration("interval", time.Minute, "Polling interval (e.g. 30s, 1m)")
-	onceFlag := flag.Bool("once", false, "Run a single balance check and exit")
-	exitAfterAlertFlag := flag.Bool("alert-exit", true, "Exit after the first balance >= threshold alert")
-	alertURLFlag := flag.String("alert-url", "", "Optional alert webhook base URL (expects GET with message query param)")
+// Process exit codes shared by every subcommand.
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
 
-	flag.Parse()
+const usageText = `Usage: usdc-watch <command> [flags]
 
-	if *addressFlag == "" {
-		log.Fatalf("--address is required")
-	}
-	if *thresholdFlag == "" {
-		log.Fatalf("--threshold is required")
+Commands:
+  watch             Poll balances and raise alerts (default when only flags are given)
+  balance           Query the current balance of one or more addresses
+  endpoints check   Probe every configured endpoint
+  config validate   Check the configuration file
+  backfill          Fill the balance history from past blocks
+  report            Summarise the balance history for a period
+
+Run "usdc-watch <command> -h" for command flags.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// run dispatches to a subcommand and returns the process exit code. Invocations
+// that start with a flag are treated as "watch" for compatibility with the
+// original flat command line.
+func run(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usageText)
+		return exitUsage
+	}
+	if strings.HasPrefix(args[0], "-") && args[0] != "-h" && args[0] != "--help" {
+		return runWatch(args)
+	}
+	switch args[0] {
+	case "watch":
+		return runWatch(args[1:])
+	case "balance":
+		return runBalance(args[1:])
+	case "endpoints":
+		return runEndpoints(args[1:])
+	case "config":
+		return runConfig(args[1:])
+	case "backfill":
+		return runBackfill(args[1:])
+	case "report":
+		return runReport(args[1:])
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, usageText)
+		return exitOK
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usageText)
+		return exitUsage
 	}
-	if *intervalFlag <= 0 {
-		log.Fatalf("--interval must be positive")
+}
+
+// newFlagSet creates a flag set whose help output names the subcommand.
+func newFlagSet(name, synopsis, description string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: usdc-watch %s\n\n%s\n\nFlags:\n", synopsis, description)
+		fs.PrintDefaults()
 	}
+	return fs
+}
 
-	normalizedAddress, err := eth.NormalizeAddress(*addressFlag)
-	if err != nil {
-		log.Fatalf("invalid address: %v", err)
+// parseFlags parses args into fs. When parsing stops early it returns false and
+// the exit code to use: exitOK for -h, exitUsage otherwise.
+func parseFlags(fs *flag.FlagSet, args []string) (bool, int) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return false, exitOK
+		}
+		return false, exitUsage
 	}
+	return true, exitOK
+}
 
-	thresholdAmount, err := usdc.ParseAmount(*thresholdFlag)
-	if err != nil {
-		log.Fatalf("invalid threshold: %v", err)
+// usageError prints a usage problem for a subcommand and returns exitUsage.
+func usageError(fs *flag.FlagSet, format string, args ...interface{}) int {
+	fmt.Fprintf(fs.Output(), format+"\n", args...)
+	return exitUsage
+}
+
+// logOptions holds the logging flags shared by every subcommand.
+type logOptions struct {
+	format string
+	level  string
+}
+
+func addLogFlags(fs *flag.FlagSet) *logOptions {
+	opts := &logOptions{}
+	fs.StringVar(&opts.format, "log-format", "text", "Log output format: text or json")
+	fs.StringVar(&opts.level, "log-level", "info", "Minimum log level: debug, info, warn or error")
+	return opts
+}
+
+func (o *logOptions) logger(w io.Writer) (*slog.Logger, error) {
+	return newLogger(w, o.format, o.level)
+}
+
+// parseAddressList normalizes a comma-separated address list together with any
+// positional addresses, dropping duplicates while keeping their order.
+func parseAddressList(list string, positional []string) ([]string, error) {
+	var raw []string
+	if list != "" {
+		raw = append(raw, strings.Split(list, ",")...)
+	}
+	raw = append(raw, positional...)
+	seen := make(map[string]bool)
+	var out []string
+	for _, value := range raw {
+		address, err := eth.NormalizeAddress(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %w", value, err)
+		}
+		if seen[address] {
+			continue
+		}
+		seen[address] = true
+		out = append(out, address)
 	}
+	return out, nil
+}
+
+// balanceReading is a balance observed at a specific block.
+type balanceReading struct {
+	Balance  *big.Int
+	Block    uint64
+	Endpoint string
+}
 
-	endpoints, err := config.LoadEndpoints(*cfgPath)
+// fetchBalance resolves the head block and reads the balance at that block so the
+// reported block number matches the state that was queried. The read goes to
+// the endpoint that reported the head, as another may not have the block yet,
+// and only fails over when that endpoint fails.
+func fetchBalance(ctx context.Context, client *rpc.Client, callData string) (balanceReading, error) {
+	block, endpoint, err := client.BlockNumber(ctx)
 	if err != nil {
-		log.Fatalf("load endpoints: %v", err)
+		return balanceReading{Endpoint: endpoint.Name}, fmt.Errorf("resolve head block: %w", err)
 	}
-
-	rpcClient, err := rpc.NewClient(endpoints, nil)
+	raw, err := client.CallEndpoint(ctx, endpoint, "eth_call", balanceCallParams(callData, block))
 	if err != nil {
-		log.Fatalf("build rpc client: %v", err)
+		return fetchBalanceAt(ctx, client, callData, block)
 	}
+	return decodeBalance(raw, block, endpoint)
+}
 
-	callData, err := usdc.EncodeBalanceOfCall(normalizedAddress)
+// fetchBalanceAt reads the balance encoded by callData at a specific block.
+func fetchBalanceAt(ctx context.Context, client *rpc.Client, callData string, block uint64) (balanceReading, error) {
+	raw, endpoint, err := client.Call(ctx, "eth_call", balanceCallParams(callData, block))
 	if err != nil {
-		log.Fatalf("encode call data: %v", err)
+		return balanceReading{Block: block, Endpoint: endpoint.Name}, err
 	}
+	return decodeBalance(raw, block, endpoint)
+}
 
-	params := []interface{}{
+// balanceCallParams builds the eth_call parameters reading callData at block.
+func balanceCallParams(callData string, block uint64) []interface{} {
+	return []interface{}{
 		map[string]string{
 			"to":   usdc.ContractAddress,
 			"data": callData,
 		},
-		"latest",
-	}
-
-	pollInterval := *intervalFlag
-
-	logger := log.New(os.Stdout, "", log.LstdFlags)
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer stop()
-
-	logger.Printf("Monitoring USDC balance for %s, threshold %s USDC, interval %s", normalizedAddress, usdc.FormatAmount(thresholdAmount), pollInterval.String())
-
-	runLoop(ctx, logger, rpcClient, params, thresholdAmount, *onceFlag, *exitAfterAlertFlag, pollInterval, *alertURLFlag)
-}
-
-func runLoop(
-	ctx context.Context,
-	logger *log.Logger,
-	client *rpc.Client,
-	params []interface{},
-	threshold *big.Int,
-	once bool,
-	exitAfterAlert bool,
-	interval time.Duration,
-	alertURL string,
-) {
-	for iteration := 0; ; iteration++ {
-		if iteration > 0 {
-			if once {
-				return
-			}
-			select {
-			case <-ctx.Done():
-				logger.Printf("Stopping watcher: %v", ctx.Err())
-				return
-			case <-time.After(interval):
-			}
-		}
-
-		if err := ctx.Err(); err != nil {
-			logger.Printf("Stopping watcher: %v", err)
-			return
-		}
-
-		iterationCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
-		balance, endpointName, err := fetchBalance(iterationCtx, client, params)
-		cancel()
-		if err != nil {
-			logger.Printf("Failed to fetch balance: %v", err)
-		} else {
-			logger.Printf("Balance %s USDC (raw %s) via %s", usdc.FormatAmount(balance), balance.String(), endpointName)
-			if balance.Cmp(threshold) >= 0 {
-				logger.Printf("ALERT: Balance %s USDC >= threshold %s USDC", usdc.FormatAmount(balance), usdc.FormatAmount(threshold))
-				if alertURL != "" {
-					alertCtx, cancelAlert := context.WithTimeout(ctx, 5*time.Second)
-					if err := sendAlert(alertCtx, http.DefaultClient, alertURL, buildAlertMessage(balance, threshold)); err != nil {
-						logger.Printf("Alert webhook failed: %v", err)
-					} else {
-						logger.Printf("Alert webhook notified")
-					}
-					cancelAlert()
-				}
-				if exitAfterAlert {
-					return
-				}
-			}
-		}
-		if once {
-			return
-		}
+		eth.FormatQuantity(block),
 	}
 }
 
-func fetchBalance(ctx context.Context, client *rpc.Client, params []interface{}) (*big.Int, string, error) {
-	raw, endpoint, err := client.Call(ctx, "eth_call", params)
-	if err != nil {
-		return nil, endpoint.Name, err
-	}
+// decodeBalance decodes the result of a balanceOf eth_call made at block.
+func decodeBalance(raw json.RawMessage, block uint64, endpoint config.Endpoint) (balanceReading, error) {
 	var hexValue string
 	if err := json.Unmarshal(raw, &hexValue); err != nil {
-		return nil, endpoint.Name, fmt.Errorf("decode result: %w", err)
+		return balanceReading{Block: block, Endpoint: endpoint.Name}, fmt.Errorf("decode result: %w", err)
 	}
 	balance, err := hexToBigInt(hexValue)
 	if err != nil {
-		return nil, endpoint.Name, err
+		return balanceReading{Block: block, Endpoint: endpoint.Name}, err
 	}
-	return balance, endpoint.Name, nil
+	return balanceReading{Balance: balance, Block: block, Endpoint: endpoint.Name}, nil
 }
 
 func hexToBigInt(value string) (*big.Int, error) {
@@ -203,19 +245,3 @@ func sendAlert(ctx context.Context, client *http.Client, baseURL, message string
 	}
 	return nil
 }
-
-func buildAlertMessage(balance, threshold *big.Int) string {
-	return fmt.Sprintf(
-		"USDC balance %s >= threshold %s",
-		formatAmountFixed(balance),
-		formatAmountFixed(threshold),
-	)
-}
-
-func formatAmountFixed(amount *big.Int) string {
-	formatted := usdc.FormatAmount(amount)
-	if strings.Contains(formatted, ".") {
-		return formatted
-	}
-	return formatted + ".000000"
-}
@@ -2,12 +2,21 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"io"
+	"log/slog"
 	"math/big"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
+
+	"usdc-watch/internal/alert"
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/metrics"
 )
 
 type roundTripperFunc func(*http.Request) (*http.Response, error)
@@ -55,12 +64,113 @@ func TestSendAlertHTTPError(t *testing.T) {
 	}
 }
 
-func TestBuildAlertMessage(t *testing.T) {
-	balance := big.NewInt(1_500_000)
-	threshold := big.NewInt(1_000_000)
-	msg := buildAlertMessage(balance, threshold)
+func TestDefaultAlertMessage(t *testing.T) {
+	templates, err := alert.New(nil, alert.Options{})
+	if err != nil {
+		t.Fatalf("alert.New error: %v", err)
+	}
+	msg, err := templates.Render(alert.RuleThreshold, notifierWebhook, alert.Data{Balance: big.NewInt(1_500_000), Threshold: big.NewInt(1_000_000)})
 	expected := "USDC balance 1.500000 >= threshold 1.000000"
-	if msg != expected {
-		t.Fatalf("buildAlertMessage mismatch: got %q, expected %q", msg, expected)
+	if err != nil || msg != expected {
+		t.Fatalf("default alert message mismatch: got %q (%v), expected %q", msg, err, expected)
+	}
+}
+
+func TestWatchMetricsExport(t *testing.T) {
+	reg := metrics.NewRegistry()
+	stats := newWatchMetrics(reg)
+	stats.observePoll("0xabc", time.Unix(1_700_000_000, 0), 200*time.Millisecond, big.NewInt(2_500_000), nil)
+	stats.observeAlert("0xabc", "threshold")
+	stats.ObserveRequest(config.Endpoint{Name: "primary"}, "eth_call", 10*time.Millisecond, errors.New("boom"))
+
+	var out strings.Builder
+	if err := reg.WriteText(&out); err != nil {
+		t.Fatalf("WriteText error: %v", err)
+	}
+	for _, want := range []string{
+		`usdc_watch_balance_usdc{address="0xabc"} 2.5`,
+		`usdc_watch_last_success_timestamp_seconds{address="0xabc"} 1.7e+09`,
+		`usdc_watch_alerts_total{address="0xabc",rule="threshold"} 1`,
+		`usdc_watch_rpc_requests_total{endpoint="primary",method="eth_call"} 1`,
+		`usdc_watch_rpc_errors_total{endpoint="primary",method="eth_call"} 1`,
+		`usdc_watch_poll_duration_seconds_count{address="0xabc"} 1`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("metrics output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	var disabled *watchMetrics
+	disabled.observePoll("0xabc", time.Unix(0, 0), time.Second, nil, errors.New("ignored"))
+}
+
+func TestNewLoggerJSON(t *testing.T) {
+	var out strings.Builder
+	logger, err := newLogger(&out, "json", "info")
+	if err != nil {
+		t.Fatalf("newLogger error: %v", err)
+	}
+	logger.Debug("hidden")
+	logger.Info("balance polled", addressAttr("0xabc"), slog.Group("", balanceAttrs(big.NewInt(1_500_000))...), latencyAttr(1500*time.Microsecond))
+
+	var event map[string]interface{}
+	if err := json.Unmarshal([]byte(out.String()), &event); err != nil {
+		t.Fatalf("expected a single JSON event, got %q: %v", out.String(), err)
+	}
+	if event["address"] != "0xabc" || event["balance"] != "1.500000" || event["balance_raw"] != "1500000" || event["latency_ms"] != 1.5 {
+		t.Fatalf("unexpected event fields: %v", event)
+	}
+
+	if _, err := newLogger(&out, "xml", "info"); err == nil {
+		t.Fatalf("expected error for unknown log format")
+	}
+	if _, err := newLogger(&out, "text", "loud"); err == nil {
+		t.Fatalf("expected error for unknown log level")
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	cfg := filepath.Join(t.TempDir(), "endpoints.toml")
+	if err := os.WriteFile(cfg, []byte("[[rpc.endpoints]]\nname = \"a\"\nurl = \"https://a.example\"\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+	bad := filepath.Join(t.TempDir(), "bad.toml")
+	if err := os.WriteFile(bad, []byte("[[rpc.endpoints]]\nurl = \"ftp://a.example\"\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+
+	cases := []struct {
+		args []string
+		want int
+	}{
+		{nil, exitUsage},
+		{[]string{"help"}, exitOK},
+		{[]string{"frobnicate"}, exitUsage},
+		{[]string{"config", "validate", "--config", cfg}, exitOK},
+		{[]string{"config", "validate", "--config", bad}, exitFailure},
+		{[]string{"balance", "--config", cfg}, exitUsage},
+		{[]string{"balance", "-h"}, exitOK},
+		{[]string{"watch", "--threshold", "1"}, exitUsage},
+		{[]string{"--address", "0x01"}, exitUsage},
+		{[]string{"watch", "--config", cfg}, exitUsage},
+		{[]string{"endpoints"}, exitUsage},
+	}
+	for _, tc := range cases {
+		if got := run(tc.args); got != tc.want {
+			t.Fatalf("run(%q) = %d, expected %d", tc.args, got, tc.want)
+		}
+	}
+}
+
+func TestParseAddressList(t *testing.T) {
+	got, err := parseAddressList("0x0000000000000000000000000000000000000001,0x0000000000000000000000000000000000000002", []string{"0x0000000000000000000000000000000000000001"})
+	if err != nil {
+		t.Fatalf("parseAddressList error: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected duplicates to be dropped, got %v", got)
+	}
+	if _, err := parseAddressList("nothex", nil); err == nil {
+		t.Fatalf("expected error for invalid address")
 	}
 }
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"usdc-watch/internal/alert"
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/eth"
+	"usdc-watch/internal/history"
+	"usdc-watch/internal/usdc"
+)
+
+// Meta alert rules, reported through the same path as balance alerts.
+const (
+	ruleStale         = "meta_stale"
+	ruleEndpointsDown = "meta_endpoints_down"
+	ruleHeadStalled   = "meta_head_stalled"
+	ruleDisagreement  = "meta_disagreement"
+)
+
+// MetaConfig enables alerts about the watcher's own health. A zero duration
+// disables the corresponding check.
+type MetaConfig struct {
+	// StaleAfter fires when no poll has succeeded for this long.
+	StaleAfter time.Duration
+	// HeadStallAfter fires when the chain head has not advanced for this long.
+	HeadStallAfter time.Duration
+	// EndpointsDown fires while every endpoint's latest request failed.
+	EndpointsDown bool
+	// DisagreeAfter fires when the endpoints have disagreed for this long:
+	// their heads are more than HeadTolerance blocks apart, or they report
+	// different balances for a watched address at the same block.
+	DisagreeAfter time.Duration
+	// HeadTolerance is how many blocks endpoint heads may differ by, as
+	// endpoints import a new block at slightly different times.
+	HeadTolerance uint64
+}
+
+// metaState tracks what the meta checks need between polls.
+type metaState struct {
+	started       time.Time
+	lastSuccess   time.Time
+	head          uint64
+	headChangedAt time.Time
+	// disagreement describes how the endpoints disagreed at the latest
+	// comparison, empty when they agreed; disagreeSince is when they started.
+	disagreement  string
+	disagreeSince time.Time
+	active        map[string]bool
+}
+
+// metaCheck is the outcome of evaluating one meta rule.
+type metaCheck struct {
+	rule    string
+	firing  bool
+	message string
+}
+
+// checkMeta evaluates the meta rules and notifies on every rule that started
+// firing or has recovered since the previous evaluation.
+func (w *Watcher) checkMeta(ctx context.Context) {
+	if w.cfg.Meta.DisagreeAfter > 0 {
+		w.compareEndpoints(ctx)
+	}
+	for _, check := range w.evaluateMeta(w.clock.Now()) {
+		if check.firing == w.meta.active[check.rule] {
+			continue
+		}
+		w.meta.active[check.rule] = check.firing
+		w.raiseMetaAlert(ctx, check)
+	}
+}
+
+func (w *Watcher) evaluateMeta(now time.Time) []metaCheck {
+	cfg, m := w.cfg.Meta, &w.meta
+	var checks []metaCheck
+
+	if cfg.StaleAfter > 0 {
+		since := m.lastSuccess
+		if since.IsZero() {
+			since = m.started
+		}
+		age := now.Sub(since)
+		checks = append(checks, metaCheck{
+			rule:    ruleStale,
+			firing:  age >= cfg.StaleAfter,
+			message: fmt.Sprintf("no successful balance poll for %s", age.Truncate(time.Second)),
+		})
+	}
+
+	if cfg.EndpointsDown {
+		health := w.cfg.Client.Health()
+		down := len(health) > 0
+		for _, h := range health {
+			if h.Healthy() {
+				down = false
+				break
+			}
+		}
+		checks = append(checks, metaCheck{
+			rule:    ruleEndpointsDown,
+			firing:  down,
+			message: fmt.Sprintf("all %d RPC endpoints are failing", len(health)),
+		})
+	}
+
+	if cfg.HeadStallAfter > 0 {
+		if head := w.cfg.Client.Head(); head != m.head || m.headChangedAt.IsZero() {
+			m.head, m.headChangedAt = head, now
+		}
+		stalled := now.Sub(m.headChangedAt)
+		checks = append(checks, metaCheck{
+			rule:    ruleHeadStalled,
+			firing:  stalled >= cfg.HeadStallAfter,
+			message: fmt.Sprintf("chain head stuck at block %d for %s", m.head, stalled.Truncate(time.Second)),
+		})
+	}
+
+	if cfg.DisagreeAfter > 0 {
+		lasted := now.Sub(m.disagreeSince)
+		checks = append(checks, metaCheck{
+			rule:    ruleDisagreement,
+			firing:  m.disagreement != "" && lasted >= cfg.DisagreeAfter,
+			message: fmt.Sprintf("%s for %s", m.disagreement, lasted.Truncate(time.Second)),
+		})
+	}
+	return checks
+}
+
+// compareEndpoints reads the head from every endpoint, then, when the heads
+// are within tolerance, each watched balance at the lowest of them, and
+// records whether and since when the endpoints disagree. Endpoints that fail
+// are left out; the endpoints-down check covers them.
+func (w *Watcher) compareEndpoints(ctx context.Context) {
+	m := &w.meta
+	disagreement := w.endpointDisagreement(ctx)
+	switch {
+	case disagreement == "":
+		m.disagreeSince = time.Time{}
+	case m.disagreement == "":
+		m.disagreeSince = w.clock.Now()
+	}
+	if disagreement != m.disagreement {
+		w.cfg.Logger.Debug("endpoint comparison", "disagreement", disagreement)
+	}
+	m.disagreement = disagreement
+}
+
+// endpointDisagreement describes the first disagreement found between the
+// endpoints, or returns "" when they agree.
+func (w *Watcher) endpointDisagreement(ctx context.Context) string {
+	compareCtx, cancel := context.WithTimeout(ctx, w.cfg.PollTimeout)
+	defer cancel()
+	client := w.cfg.Client
+	type endpointHead struct {
+		endpoint config.Endpoint
+		head     uint64
+	}
+	var heads []endpointHead
+	for _, endpoint := range client.Endpoints() {
+		raw, err := client.CallEndpoint(compareCtx, endpoint, "eth_blockNumber", []interface{}{})
+		if err != nil {
+			continue
+		}
+		var hexValue string
+		if err := json.Unmarshal(raw, &hexValue); err != nil {
+			continue
+		}
+		head, err := eth.ParseQuantity(hexValue)
+		if err != nil {
+			continue
+		}
+		heads = append(heads, endpointHead{endpoint: endpoint, head: head})
+	}
+	if len(heads) < 2 {
+		return ""
+	}
+	low, high := heads[0], heads[0]
+	for _, h := range heads[1:] {
+		if h.head < low.head {
+			low = h
+		}
+		if h.head > high.head {
+			high = h
+		}
+	}
+	if high.head-low.head > w.cfg.Meta.HeadTolerance {
+		return fmt.Sprintf("endpoint heads differ by %d blocks (%s at %d, %s at %d)",
+			high.head-low.head, low.endpoint.Name, low.head, high.endpoint.Name, high.head)
+	}
+
+	for _, t := range w.snapshot() {
+		var first *balanceReading
+		for _, h := range heads {
+			raw, err := client.CallEndpoint(compareCtx, h.endpoint, "eth_call", balanceCallParams(t.callData, low.head))
+			if err != nil {
+				continue
+			}
+			reading, err := decodeBalance(raw, low.head, h.endpoint)
+			if err != nil {
+				continue
+			}
+			if first == nil {
+				first = &reading
+				continue
+			}
+			if reading.Balance.Cmp(first.Balance) != 0 {
+				return fmt.Sprintf("endpoints disagree on the balance of %s at block %d (%s reports %s, %s reports %s)",
+					t.Address, low.head, first.Endpoint, usdc.FormatAmount(first.Balance), reading.Endpoint, usdc.FormatAmount(reading.Balance))
+			}
+		}
+	}
+	return ""
+}
+
+// raiseMetaAlert reports a meta rule change. Meta alerts concern the whole
+// watcher, so they are recorded without an address.
+func (w *Watcher) raiseMetaAlert(ctx context.Context, check metaCheck) {
+	data := alert.Data{Rule: check.rule, Chain: w.cfg.Chain, Time: w.clock.Now(), Message: check.message}
+	if !check.firing {
+		data.Message, data.Recovered = recoveryText(check.rule), true
+	}
+	alertLogger := w.cfg.Logger.With("rule", check.rule)
+	message := w.render(alertLogger, "", data)
+	if check.firing {
+		alertLogger.Error("meta alert", "message", message)
+		w.cfg.Metrics.observeAlert("", check.rule)
+		if series := w.cfg.Series; series != nil {
+			occurrence := history.Alert{Time: w.clock.Now(), Rule: check.rule, Message: message}
+			if err := series.AppendAlert(occurrence); err != nil {
+				alertLogger.Error("append alert history failed", "error", err)
+			}
+		}
+	} else {
+		alertLogger.Info("meta alert recovered", "message", message)
+	}
+	w.notify(ctx, alertLogger, data)
+}
+
+func recoveryText(rule string) string {
+	switch rule {
+	case ruleStale:
+		return "balance polls are succeeding again"
+	case ruleEndpointsDown:
+		return "at least one RPC endpoint is responding"
+	case ruleHeadStalled:
+		return "chain head is advancing again"
+	case ruleDisagreement:
+		return "RPC endpoints agree again"
+	}
+	return rule + " cleared"
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"usdc-watch/internal/clock"
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/rpctest"
+)
+
+func TestWatcherMetaAlertsAndRecovery(t *testing.T) {
+	node := rpctest.NewNode(t)
+	node.SetHead(100)
+	node.SetBalance(watchedAddress, 0, big.NewInt(500_000))
+	node.InjectFault(rpctest.Fault{HTTPStatus: http.StatusBadGateway})
+
+	messages := make(chan string, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		messages <- r.URL.Query().Get("message")
+	}))
+	defer webhook.Close()
+
+	client, err := rpc.NewClient([]config.Endpoint{node.Endpoint("node")}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	watcher, err := NewWatcher(WatcherConfig{
+		Logger:   discardLogger(),
+		Client:   client,
+		Watches:  []Watch{{Address: watchedAddress, Threshold: big.NewInt(1_000_000)}},
+		Interval: time.Minute,
+		AlertURL: webhook.URL,
+		Meta:     MetaConfig{StaleAfter: 3 * time.Minute, EndpointsDown: true},
+		Clock:    fake,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- watcher.Run(ctx) }()
+
+	drain := func() []string {
+		var got []string
+		for {
+			select {
+			case msg := <-messages:
+				got = append(got, msg)
+			default:
+				return got
+			}
+		}
+	}
+	step := func() {
+		fake.BlockUntil(1)
+		fake.Advance(time.Minute)
+	}
+
+	fake.BlockUntil(1)
+	if got, want := drain(), []string{"USDC watcher unhealthy: all 1 RPC endpoints are failing"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("first poll alerts = %q, want %q", got, want)
+	}
+	step()
+	step()
+	fake.BlockUntil(1)
+	if got := drain(); len(got) != 0 {
+		t.Fatalf("unexpected alerts before the stale deadline: %q", got)
+	}
+	step()
+	fake.BlockUntil(1)
+	if got, want := drain(), []string{"USDC watcher unhealthy: no successful balance poll for 3m0s"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("stale alerts = %q, want %q", got, want)
+	}
+
+	node.ClearFaults()
+	step()
+	fake.BlockUntil(1)
+	want := []string{
+		"USDC watcher recovered: balance polls are succeeding again",
+		"USDC watcher recovered: at least one RPC endpoint is responding",
+	}
+	if got := drain(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("recovery notices = %q, want %q", got, want)
+	}
+	cancel()
+	<-done
+}
+
+func TestWatcherDisagreementAlert(t *testing.T) {
+	primary, backup := rpctest.NewNode(t), rpctest.NewNode(t)
+	for _, node := range []*rpctest.Node{primary, backup} {
+		node.SetHead(100)
+		node.SetBalance(watchedAddress, 0, big.NewInt(500_000))
+	}
+	// The backup serves a different balance from block 95.
+	backup.SetBalance(watchedAddress, 95, big.NewInt(700_000))
+
+	messages := make(chan string, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		messages <- r.URL.Query().Get("message")
+	}))
+	defer webhook.Close()
+
+	client, err := rpc.NewClient([]config.Endpoint{primary.Endpoint("primary"), backup.Endpoint("backup")}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	watcher, err := NewWatcher(WatcherConfig{
+		Logger:   discardLogger(),
+		Client:   client,
+		Watches:  []Watch{{Address: watchedAddress, Threshold: big.NewInt(1_000_000)}},
+		Interval: time.Minute,
+		AlertURL: webhook.URL,
+		Meta:     MetaConfig{DisagreeAfter: 2 * time.Minute, HeadTolerance: 3},
+		Clock:    fake,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher error: %v", err)
+	}
+	ctx := context.Background()
+	check := func() []string {
+		t.Helper()
+		watcher.checkMeta(ctx)
+		var got []string
+		for len(messages) > 0 {
+			got = append(got, <-messages)
+		}
+		return got
+	}
+
+	if got := check(); len(got) != 0 {
+		t.Fatalf("alerted before the disagreement persisted: %q", got)
+	}
+	fake.Advance(2 * time.Minute)
+	want := []string{"USDC watcher unhealthy: endpoints disagree on the balance of " + watchedAddress +
+		" at block 100 (primary reports 0.500000, backup reports 0.700000) for 2m0s"}
+	if got := check(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("disagreement alerts = %q, want %q", got, want)
+	}
+
+	// The balances agree from block 101, but the backup has fallen behind.
+	primary.SetBalance(watchedAddress, 101, big.NewInt(700_000))
+	primary.SetHead(110)
+	backup.SetHead(101)
+	fake.Advance(time.Minute)
+	if got := check(); len(got) != 0 {
+		t.Fatalf("expected the alert to stay active while heads differ, got %q", got)
+	}
+	if m := watcher.meta.disagreement; m != "endpoint heads differ by 9 blocks (backup at 101, primary at 110)" {
+		t.Fatalf("disagreement = %q", m)
+	}
+
+	backup.SetHead(110)
+	fake.Advance(time.Minute)
+	if got, want := check(), []string{"USDC watcher recovered: RPC endpoints agree again"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("recovery notices = %q, want %q", got, want)
+	}
+}
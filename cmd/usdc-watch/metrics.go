@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"time"
+
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/metrics"
+)
+
+// watchMetrics groups the metric families exported by the watcher.
+// A nil *watchMetrics is valid and records nothing.
+type watchMetrics struct {
+	balance      *metrics.GaugeVec
+	pollDuration *metrics.HistogramVec
+	lastSuccess  *metrics.GaugeVec
+	totalSupply  *metrics.GaugeVec
+	alerts       *metrics.CounterVec
+	rpcRequests  *metrics.CounterVec
+	rpcErrors    *metrics.CounterVec
+	rpcLatency   *metrics.HistogramVec
+}
+
+func newWatchMetrics(reg *metrics.Registry) *watchMetrics {
+	return &watchMetrics{
+		balance: reg.NewGaugeVec(
+			"usdc_watch_balance_usdc",
+			"Last observed USDC balance per watched address.",
+			"address",
+		),
+		pollDuration: reg.NewHistogramVec(
+			"usdc_watch_poll_duration_seconds",
+			"Time taken by a balance poll, including endpoint failover.",
+			nil,
+			"address",
+		),
+		lastSuccess: reg.NewGaugeVec(
+			"usdc_watch_last_success_timestamp_seconds",
+			"Unix time of the last successful balance poll per address.",
+			"address",
+		),
+		totalSupply: reg.NewGaugeVec(
+			"usdc_watch_total_supply_usdc",
+			"Last observed USDC total supply.",
+		),
+		alerts: reg.NewCounterVec(
+			"usdc_watch_alerts_total",
+			"Alerts raised per address and rule.",
+			"address", "rule",
+		),
+		rpcRequests: reg.NewCounterVec(
+			"usdc_watch_rpc_requests_total",
+			"JSON-RPC requests sent per endpoint and method.",
+			"endpoint", "method",
+		),
+		rpcErrors: reg.NewCounterVec(
+			"usdc_watch_rpc_errors_total",
+			"Failed JSON-RPC requests per endpoint and method.",
+			"endpoint", "method",
+		),
+		rpcLatency: reg.NewHistogramVec(
+			"usdc_watch_rpc_request_duration_seconds",
+			"JSON-RPC request latency per endpoint and method.",
+			nil,
+			"endpoint", "method",
+		),
+	}
+}
+
+// ObserveRequest implements rpc.Observer.
+func (m *watchMetrics) ObserveRequest(endpoint config.Endpoint, method string, latency time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.rpcRequests.Inc(endpoint.Name, method)
+	m.rpcLatency.Observe(latency.Seconds(), endpoint.Name, method)
+	if err != nil {
+		m.rpcErrors.Inc(endpoint.Name, method)
+	}
+}
+
+// observePoll records a poll of address that finished at, on the watcher's
+// clock, after duration.
+func (m *watchMetrics) observePoll(address string, at time.Time, duration time.Duration, balance *big.Int, err error) {
+	if m == nil {
+		return
+	}
+	m.pollDuration.Observe(duration.Seconds(), address)
+	if err != nil {
+		return
+	}
+	m.balance.Set(amountFloat(balance), address)
+	m.lastSuccess.Set(float64(at.Unix()), address)
+}
+
+func (m *watchMetrics) observeSupply(total *big.Int) {
+	if m == nil {
+		return
+	}
+	m.totalSupply.Set(amountFloat(total))
+}
+
+func (m *watchMetrics) observeAlert(address, rule string) {
+	if m == nil {
+		return
+	}
+	m.alerts.Inc(address, rule)
+}
+
+// amountFloat converts a base-unit amount to whole USDC for gauge export.
+func amountFloat(amount *big.Int) float64 {
+	value, _ := new(big.Float).Quo(new(big.Float).SetInt(amount), big.NewFloat(1_000_000)).Float64()
+	return value
+}
+
+// serveHTTP listens on addr and serves handler until ctx is cancelled.
+func serveHTTP(ctx context.Context, logger *slog.Logger, addr string, handler http.Handler) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("http server stopped", "addr", addr, "error", err)
+		}
+	}()
+	logger.Info("http server listening", "addr", listener.Addr().String())
+	return nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"usdc-watch/internal/alert"
+	"usdc-watch/internal/eth"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/usdc"
+)
+
+// Sources of pending transactions for WatcherConfig.Pending.
+const (
+	// pendingFilter polls an eth_newPendingTransactionFilter, which most
+	// nodes and some providers support.
+	pendingFilter = "filter"
+	// pendingTxPool reads txpool_content, usually only exposed by a local node.
+	pendingTxPool = "txpool"
+)
+
+// maxPendingTracked bounds the noticed transfers awaiting their outcome;
+// pendingExpiry is how long one may go unmined and unseen before it is
+// reported dropped.
+const (
+	maxPendingTracked = 100
+	pendingExpiry     = time.Hour
+)
+
+// pendingKey identifies a noticed transfer as seen by one watched address;
+// a transfer between two watched addresses is reported to each.
+type pendingKey struct {
+	hash    string
+	address string
+}
+
+// pendingTransfer is a noticed transfer awaiting its outcome.
+type pendingTransfer struct {
+	data alert.Data
+	// lastSeen is when the transaction was last reported pending.
+	lastSeen time.Time
+}
+
+// pendingState is what the pending watch knows between polls. Only
+// WatchPending touches it.
+type pendingState struct {
+	filter  *rpc.PendingFilter
+	tracked map[pendingKey]*pendingTransfer
+}
+
+// WatchPending checks for pending transfers every interval until ctx is
+// cancelled, then uninstalls its pending-transaction filter. It runs beside
+// Run, whose interval is usually much longer than the time a transaction
+// waits to be mined.
+func (w *Watcher) WatchPending(ctx context.Context, interval time.Duration) {
+	for {
+		w.checkPending(ctx)
+		select {
+		case <-ctx.Done():
+			w.uninstallPendingFilter(ctx)
+			return
+		case <-w.clock.After(interval):
+		}
+	}
+}
+
+// checkPending reads the pending transactions from the configured source and
+// sends a notice for each USDC transfer or transferFrom call moving funds to
+// or from a watched address before it is mined. The noticed transfers are then
+// reconciled with their receipts.
+func (w *Watcher) checkPending(ctx context.Context) {
+	if w.cfg.Pending == "" {
+		return
+	}
+	logger := w.cfg.Logger
+	pollCtx, cancel := context.WithTimeout(ctx, w.cfg.PollTimeout)
+	txs, err := w.pendingTransactions(pollCtx)
+	cancel()
+	if err != nil {
+		logger.Warn("pending transaction poll failed", "source", w.cfg.Pending, "error", err)
+	}
+	watched := make(map[string]*target)
+	for _, t := range w.snapshot() {
+		watched[t.Address] = t
+	}
+	now := w.clock.Now()
+	for _, tx := range txs {
+		// A transaction mined before it could be read is reported by the
+		// balance and event checks instead.
+		if tx.Block != 0 {
+			continue
+		}
+		transfer, err := usdc.DecodeTransferCall(tx)
+		if err != nil {
+			continue
+		}
+		for _, address := range []string{transfer.From, transfer.To} {
+			t, ok := watched[address]
+			if !ok {
+				continue
+			}
+			key := pendingKey{hash: transfer.TxHash, address: address}
+			if p := w.pending.tracked[key]; p != nil {
+				p.lastSeen = now
+				continue
+			}
+			if len(w.pending.tracked) >= maxPendingTracked {
+				logger.Warn("pending transfer not tracked, too many awaiting their outcome", "tx", transfer.TxHash, addressAttr(address))
+				continue
+			}
+			data := alert.Data{
+				Rule:    alert.RulePending,
+				Address: address,
+				Label:   t.Label,
+				Chain:   w.cfg.Chain,
+				Amount:  transfer.Value,
+				From:    transfer.From,
+				To:      transfer.To,
+				TxHash:  transfer.TxHash,
+				Time:    now,
+			}
+			w.pending.tracked[key] = &pendingTransfer{data: data, lastSeen: now}
+			w.raiseChangeAlert(ctx, data, w.cfg.Pending)
+		}
+	}
+	w.reconcilePending(ctx)
+}
+
+// pendingTransactions returns the transactions pending on the configured
+// source: the whole pool for txpool, those new since the previous poll for
+// filter. A failed filter is replaced on the next poll, as nodes expire
+// filters that are not polled for a while.
+func (w *Watcher) pendingTransactions(ctx context.Context) ([]eth.Transaction, error) {
+	if w.cfg.Pending == pendingTxPool {
+		txs, _, err := w.cfg.Client.TxPoolContent(ctx)
+		return txs, err
+	}
+	if w.pending.filter == nil {
+		filter, err := w.cfg.Client.NewPendingFilter(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("install pending transaction filter: %w", err)
+		}
+		w.pending.filter = filter
+	}
+	filter := w.pending.filter
+	txs, err := filter.Changes(ctx)
+	if err != nil {
+		w.uninstallPendingFilter(ctx)
+		return nil, fmt.Errorf("poll pending transaction filter on %s: %w", filter.Endpoint().Name, err)
+	}
+	return txs, nil
+}
+
+// uninstallPendingFilter removes the pending-transaction filter from its
+// endpoint, so a filter no longer polled does not linger on the node until it
+// expires. It also runs once ctx is cancelled, on a context of its own.
+func (w *Watcher) uninstallPendingFilter(ctx context.Context) {
+	filter := w.pending.filter
+	if filter == nil {
+		return
+	}
+	w.pending.filter = nil
+	uninstallCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), w.cfg.PollTimeout)
+	defer cancel()
+	if err := filter.Uninstall(uninstallCtx); err != nil {
+		w.cfg.Logger.Debug("uninstall pending transaction filter failed", "endpoint", filter.Endpoint().Name, "error", err)
+	}
+}
+
+// reconcilePending looks up the receipts of the noticed transfers, reporting
+// each as confirmed or failed once mined, or as dropped when it has neither
+// been mined nor seen pending for pendingExpiry.
+func (w *Watcher) reconcilePending(ctx context.Context) {
+	keys := make([]pendingKey, 0, len(w.pending.tracked))
+	for key := range w.pending.tracked {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := w.pending.tracked[keys[i]], w.pending.tracked[keys[j]]
+		if !a.data.Time.Equal(b.data.Time) {
+			return a.data.Time.Before(b.data.Time)
+		}
+		if keys[i].hash != keys[j].hash {
+			return keys[i].hash < keys[j].hash
+		}
+		return keys[i].address < keys[j].address
+	})
+	lookupCtx, cancel := context.WithTimeout(ctx, w.cfg.PollTimeout)
+	defer cancel()
+	for _, key := range keys {
+		p := w.pending.tracked[key]
+		data := p.data
+		receipt, endpoint, err := w.cfg.Client.TransactionReceipt(lookupCtx, key.hash)
+		switch {
+		case err == nil:
+			data.Block, data.Message = receipt.Block, "confirmed"
+			if !receipt.Success {
+				data.Message = "failed"
+			}
+		case !errors.Is(err, rpc.ErrNotFound):
+			w.cfg.Logger.Warn("pending transfer receipt lookup failed", "tx", key.hash, "endpoint", endpoint.Name, "error", err)
+			continue
+		case w.clock.Now().Sub(p.lastSeen) >= pendingExpiry:
+			data.Message = "dropped"
+		default:
+			continue
+		}
+		delete(w.pending.tracked, key)
+		data.Time, data.Recovered = w.clock.Now(), true
+		w.raiseChangeAlert(ctx, data, "receipt")
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"usdc-watch/internal/clock"
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/rpctest"
+	"usdc-watch/internal/usdc"
+)
+
+func TestWatcherReportsPendingTransfers(t *testing.T) {
+	const (
+		alice   = "0x00000000000000000000000000000000000000e1"
+		spender = "0x00000000000000000000000000000000000000e2"
+	)
+	word := func(hex string) string { return strings.Repeat("0", 64-len(hex)) + hex }
+	incoming := "0x" + strings.Repeat("c1", 32)
+	outgoing := "0x" + strings.Repeat("c2", 32)
+	stuck := "0x" + strings.Repeat("c3", 32)
+
+	for _, source := range []string{pendingFilter, pendingTxPool} {
+		t.Run(source, func(t *testing.T) {
+			node := rpctest.NewNode(t)
+			node.SetHead(100)
+			messages := make(chan string, 10)
+			webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				messages <- r.URL.Query().Get("message")
+			}))
+			defer webhook.Close()
+			client, err := rpc.NewClient([]config.Endpoint{node.Endpoint("node")}, nil)
+			if err != nil {
+				t.Fatalf("NewClient error: %v", err)
+			}
+			fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			watcher, err := NewWatcher(WatcherConfig{
+				Logger:   discardLogger(),
+				Client:   client,
+				Watches:  []Watch{{Address: watchedAddress, Label: "treasury", Threshold: big.NewInt(5_000_000_000)}},
+				Interval: time.Minute,
+				AlertURL: webhook.URL,
+				Pending:  source,
+				Enrich:   true,
+				Clock:    fake,
+			})
+			if err != nil {
+				t.Fatalf("NewWatcher error: %v", err)
+			}
+			ctx := context.Background()
+			check := func() []string {
+				watcher.checkPending(ctx)
+				var got []string
+				for len(messages) > 0 {
+					got = append(got, <-messages)
+				}
+				return got
+			}
+			if got := check(); len(got) != 0 {
+				t.Fatalf("unexpected notices before any transaction: %q", got)
+			}
+
+			node.AddTransaction(rpctest.Transaction{Hash: incoming, From: alice, To: usdc.ContractAddress, Input: "0xa9059cbb" + word(watchedAddress[2:]) + word("4c4b40")})
+			node.AddTransaction(rpctest.Transaction{Hash: outgoing, From: spender, To: usdc.ContractAddress, Input: "0x23b872dd" + word(watchedAddress[2:]) + word(alice[2:]) + word("0f4240")})
+			node.AddTransaction(rpctest.Transaction{Hash: stuck, From: alice, To: usdc.ContractAddress, Nonce: 1, Input: "0xa9059cbb" + word(watchedAddress[2:]) + word("01")})
+			node.AddTransaction(rpctest.Transaction{Hash: "0x" + strings.Repeat("c4", 32), From: alice, To: usdc.ContractAddress, Nonce: 2, Input: "0xa9059cbb" + word(spender[2:]) + word("01")})
+			want := []string{
+				"treasury: USDC transfer pending: incoming 5.000000 from " + alice + " to " + watchedAddress + " (tx 0xc1c1…c1c1)",
+				"treasury: USDC transfer pending: incoming 0.000001 from " + alice + " to " + watchedAddress + " (tx 0xc3c3…c3c3)",
+				"treasury: USDC transfer pending: outgoing 1.000000 from " + watchedAddress + " to " + alice + " (tx 0xc2c2…c2c2)",
+			}
+			if source == pendingFilter {
+				want[1], want[2] = want[2], want[1]
+			}
+			if got := check(); !reflect.DeepEqual(got, want) {
+				t.Fatalf("pending notices = %q, want %q", got, want)
+			}
+			if got := check(); len(got) != 0 {
+				t.Fatalf("unexpected notices for transfers already reported: %q", got)
+			}
+
+			node.AddTransaction(rpctest.Transaction{Hash: incoming, Block: 101, From: alice, To: usdc.ContractAddress, GasUsed: 51000})
+			node.AddTransaction(rpctest.Transaction{Hash: outgoing, Block: 101, From: spender, To: usdc.ContractAddress, GasUsed: 30000, Failed: true})
+			node.RemoveTransaction(stuck)
+			want = []string{
+				"treasury: USDC transfer confirmed: incoming 5.000000 from " + alice + " to " + watchedAddress + " at block 101 (tx 0xc1c1…c1c1)",
+				"treasury: USDC transfer failed: outgoing 1.000000 from " + watchedAddress + " to " + alice + " at block 101 (tx 0xc2c2…c2c2)",
+			}
+			if got := check(); !reflect.DeepEqual(got, want) {
+				t.Fatalf("reconciled notices = %q, want %q", got, want)
+			}
+
+			fake.Advance(pendingExpiry)
+			want = []string{"treasury: USDC transfer dropped: incoming 0.000001 from " + alice + " to " + watchedAddress + " (tx 0xc3c3…c3c3)"}
+			if got := check(); !reflect.DeepEqual(got, want) {
+				t.Fatalf("expired notices = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWatcherUninstallsPendingFilter(t *testing.T) {
+	node := rpctest.NewNode(t)
+	node.SetHead(100)
+	client, err := rpc.NewClient([]config.Endpoint{node.Endpoint("node")}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	watcher, err := NewWatcher(WatcherConfig{
+		Logger:   discardLogger(),
+		Client:   client,
+		Watches:  []Watch{{Address: watchedAddress, Threshold: big.NewInt(1)}},
+		Interval: time.Minute,
+		Pending:  pendingFilter,
+		Clock:    fake,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher error: %v", err)
+	}
+
+	// A failed poll replaces the filter, uninstalling the old one.
+	ctx := context.Background()
+	watcher.checkPending(ctx)
+	node.InjectFault(rpctest.Fault{Methods: []string{"eth_getFilterChanges"}, Times: 1, RPCError: &rpctest.RPCError{Code: -32000, Message: "filter not found"}})
+	watcher.checkPending(ctx)
+	if got := node.Requests("eth_uninstallFilter"); got != 1 {
+		t.Fatalf("eth_uninstallFilter requests after a failed poll = %d, want 1", got)
+	}
+
+	// Stopping the watch uninstalls the filter in use.
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		watcher.WatchPending(runCtx, time.Minute)
+		close(done)
+	}()
+	fake.BlockUntil(1)
+	cancel()
+	<-done
+	if got := node.Requests("eth_uninstallFilter"); got != 2 {
+		t.Fatalf("eth_uninstallFilter requests after stopping = %d, want 2", got)
+	}
+	if got := node.Requests("eth_newPendingTransactionFilter"); got != 2 {
+		t.Fatalf("eth_newPendingTransactionFilter requests = %d, want 2", got)
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"usdc-watch/internal/addressbook"
+	"usdc-watch/internal/alert"
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/eth"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/usdc"
+)
+
+// watchConfig is a validated configuration file ready to apply.
+type watchConfig struct {
+	Endpoints  []config.Endpoint
+	Watches    []Watch
+	Allowances []Allowance
+	Supply     *SupplyWatch
+	Whales     *WhaleWatch
+	Templates  *alert.Templates
+	Book       *addressbook.Book
+}
+
+// loadConfig reads and validates the configuration file, parsing its address
+// book, message templates, [[allowance]] pairs, [[supply]] and [[whales]]
+// rules and [[watch]] entries, whose selectors, like the whale watchlist tags,
+// are expanded against the book. explorerURL is the base for explorer links.
+func loadConfig(path, explorerURL string) (*watchConfig, error) {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.Validate(cfg); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	entries := make([]addressbook.Entry, 0, len(cfg.Addresses))
+	for _, entry := range cfg.Addresses {
+		entries = append(entries, addressbook.Entry(entry))
+	}
+	book, err := addressbook.New(entries)
+	if err != nil {
+		return nil, err
+	}
+	watches, err := expandWatches(cfg.Watches, book)
+	if err != nil {
+		return nil, err
+	}
+	allowances := make([]Allowance, 0, len(cfg.Allowances))
+	for i, entry := range cfg.Allowances {
+		allowance := Allowance{}
+		allowance.Owner, _ = eth.NormalizeAddress(entry.Owner)
+		allowance.Spender, _ = eth.NormalizeAddress(entry.Spender)
+		if entry.Limit != "" {
+			if allowance.Limit, err = usdc.ParseAmount(entry.Limit); err != nil {
+				return nil, fmt.Errorf("allowance %d: invalid limit: %w", i+1, err)
+			}
+		}
+		allowances = append(allowances, allowance)
+	}
+	var supply *SupplyWatch
+	for _, entry := range cfg.Supply {
+		supply = &SupplyWatch{}
+		for _, amount := range []struct {
+			name  string
+			value string
+			dest  **big.Int
+		}{
+			{"mint_threshold", entry.MintThreshold, &supply.MintThreshold},
+			{"burn_threshold", entry.BurnThreshold, &supply.BurnThreshold},
+			{"net_change", entry.NetChange, &supply.NetChange},
+		} {
+			if amount.value == "" {
+				continue
+			}
+			if *amount.dest, err = usdc.ParseAmount(amount.value); err != nil {
+				return nil, fmt.Errorf("supply: invalid %s: %w", amount.name, err)
+			}
+		}
+		if entry.Window != "" {
+			supply.Window, _ = time.ParseDuration(entry.Window)
+		}
+	}
+	var whales *WhaleWatch
+	for _, entry := range cfg.Whales {
+		whales = &WhaleWatch{Watchlist: make(map[string]bool)}
+		if entry.MinAmount != "" {
+			if whales.MinAmount, err = usdc.ParseAmount(entry.MinAmount); err != nil {
+				return nil, fmt.Errorf("whales: invalid min_amount: %w", err)
+			}
+		}
+		for _, tag := range entry.Watchlist {
+			matched, err := book.Select("tag:" + tag)
+			if err != nil {
+				return nil, fmt.Errorf("whales: watchlist: %w", err)
+			}
+			for _, m := range matched {
+				whales.Watchlist[m.Address] = true
+			}
+		}
+	}
+	defs := make([]alert.Definition, 0, len(cfg.Templates))
+	for i, tmpl := range cfg.Templates {
+		text := tmpl.Text
+		if tmpl.File != "" {
+			content, err := os.ReadFile(tmpl.File)
+			if err != nil {
+				return nil, fmt.Errorf("template %d: %w", i+1, err)
+			}
+			text = string(content)
+		}
+		defs = append(defs, alert.Definition{Rule: tmpl.Rule, Notifier: tmpl.Notifier, Text: text})
+	}
+	templates, err := alert.New(defs, alert.Options{ExplorerURL: explorerURL, Label: book.Label})
+	if err != nil {
+		return nil, err
+	}
+	return &watchConfig{Endpoints: cfg.Endpoints, Watches: watches, Allowances: allowances, Supply: supply, Whales: whales, Templates: templates, Book: book}, nil
+}
+
+// expandWatches resolves [[watch]] entries into labelled watches. An address
+// named explicitly keeps its own threshold; otherwise the first selector
+// matching it applies.
+func expandWatches(entries []config.Watch, book *addressbook.Book) ([]Watch, error) {
+	var watches []Watch
+	seen := make(map[string]bool)
+	add := func(address, amount string) error {
+		if seen[address] {
+			return nil
+		}
+		threshold, err := usdc.ParseAmount(amount)
+		if err != nil {
+			return fmt.Errorf("watch %s: invalid threshold: %w", address, err)
+		}
+		seen[address] = true
+		watches = append(watches, Watch{Address: address, Label: book.Label(address), Threshold: threshold})
+		return nil
+	}
+	for _, entry := range entries {
+		if entry.Selector != "" {
+			continue
+		}
+		address, err := eth.NormalizeAddress(entry.Address)
+		if err != nil {
+			return nil, fmt.Errorf("watch %q: invalid address: %w", entry.Address, err)
+		}
+		if err := add(address, entry.Threshold); err != nil {
+			return nil, err
+		}
+	}
+	for _, entry := range entries {
+		if entry.Selector == "" {
+			continue
+		}
+		matched, err := book.Select(entry.Selector)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matched {
+			if err := add(m.Address, entry.Threshold); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return watches, nil
+}
+
+// mergeWatches combines watches given on the command line with those from the
+// configuration file, labelling them from its address book. Command-line
+// watches take precedence for the same address.
+func mergeWatches(flags []Watch, cfg *watchConfig) []Watch {
+	seen := make(map[string]bool, len(flags))
+	merged := make([]Watch, 0, len(flags)+len(cfg.Watches))
+	for _, w := range flags {
+		w.Label = cfg.Book.Label(w.Address)
+		merged = append(merged, w)
+		seen[w.Address] = true
+	}
+	for _, w := range cfg.Watches {
+		if !seen[w.Address] {
+			seen[w.Address] = true
+			merged = append(merged, w)
+		}
+	}
+	return merged
+}
+
+// reloader re-reads the configuration file and swaps the endpoints, watched
+// addresses and rules of a running watcher.
+type reloader struct {
+	path     string
+	explorer string
+	flags    []Watch
+	logger   *slog.Logger
+	client   *rpc.Client
+	watcher  *Watcher
+	modTime  time.Time
+}
+
+func newReloader(path, explorerURL string, flags []Watch, logger *slog.Logger, client *rpc.Client, watcher *Watcher) *reloader {
+	r := &reloader{path: path, explorer: explorerURL, flags: flags, logger: logger, client: client, watcher: watcher}
+	if info, err := os.Stat(path); err == nil {
+		r.modTime = info.ModTime()
+	}
+	return r
+}
+
+// Run reloads on SIGHUP and, when interval is positive, whenever the file's
+// modification time changes, until ctx is cancelled.
+func (r *reloader) Run(ctx context.Context, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.logger.Info("reloading configuration", "path", r.path, "trigger", "SIGHUP")
+			r.Reload()
+		case <-tick:
+			info, err := os.Stat(r.path)
+			if err != nil || info.ModTime().Equal(r.modTime) {
+				continue
+			}
+			r.logger.Info("reloading configuration", "path", r.path, "trigger", "file change")
+			r.Reload()
+		}
+	}
+}
+
+// Reload applies the configuration file if it is valid and keeps the current
+// configuration otherwise. Everything is checked before anything is applied,
+// so a rejected file changes neither the watcher nor the endpoint pool.
+func (r *reloader) Reload() error {
+	if info, err := os.Stat(r.path); err == nil {
+		r.modTime = info.ModTime()
+	}
+	cfg, err := loadConfig(r.path, r.explorer)
+	var watches []Watch
+	if err == nil {
+		watches = mergeWatches(r.flags, cfg)
+		err = r.watcher.CheckConfig(watches, cfg.Allowances)
+	}
+	if err != nil {
+		r.logger.Error("configuration reload rejected", "path", r.path, "error", err)
+		return err
+	}
+	// Neither can fail now: loadConfig validated the endpoints and the
+	// watcher accepted the rest.
+	if err := r.watcher.Reconfigure(watches, cfg.Allowances, cfg.Supply, cfg.Whales, cfg.Templates, cfg.Book); err != nil {
+		r.logger.Error("configuration reload rejected", "path", r.path, "error", err)
+		return err
+	}
+	if err := r.client.SetEndpoints(cfg.Endpoints); err != nil {
+		r.logger.Error("configuration reload applied without its endpoints", "path", r.path, "error", err)
+		return err
+	}
+	r.logger.Info("configuration reloaded", "path", r.path, "endpoints", len(cfg.Endpoints), "addresses", len(watches))
+	return nil
+}
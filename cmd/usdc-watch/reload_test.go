@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"usdc-watch/internal/clock"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/rpctest"
+	"usdc-watch/internal/state"
+)
+
+func TestReloaderSwapsEndpointsAndWatches(t *testing.T) {
+	const other = "0x00000000000000000000000000000000000000a2"
+	first, second := rpctest.NewNode(t), rpctest.NewNode(t)
+	for _, node := range []*rpctest.Node{first, second} {
+		node.SetHead(100)
+		node.SetBalance(watchedAddress, 0, big.NewInt(500_000))
+		node.SetBalance(other, 0, big.NewInt(2_000_000))
+	}
+
+	path := filepath.Join(t.TempDir(), "watcher.toml")
+	writeConfig := func(endpoint string, watches ...string) {
+		content := fmt.Sprintf("[[rpc.endpoints]]\nname = \"node\"\nurl = %q\n", endpoint)
+		for _, watch := range watches {
+			content += "\n[[watch]]\n" + watch + "\n"
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile error: %v", err)
+		}
+	}
+	writeConfig(first.URL(), "address = \""+watchedAddress+"\"\nthreshold = \"1\"")
+
+	cfg, err := loadConfig(path, "")
+	if err != nil {
+		t.Fatalf("loadConfig error: %v", err)
+	}
+	client, err := rpc.NewClient(cfg.Endpoints, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	store := state.NewStore(10)
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	watcher, err := NewWatcher(WatcherConfig{
+		Logger:   discardLogger(),
+		Client:   client,
+		Watches:  cfg.Watches,
+		Interval: time.Minute,
+		Clock:    fake,
+		Store:    store,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher error: %v", err)
+	}
+	reloader := newReloader(path, "", nil, discardLogger(), client, watcher)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- watcher.Run(ctx) }()
+	fake.BlockUntil(1)
+
+	// An invalid file is rejected and the running configuration is kept.
+	writeConfig(first.URL(), "address = \"0x1234\"\nthreshold = \"1\"")
+	if err := reloader.Reload(); err == nil {
+		t.Fatalf("expected invalid configuration to be rejected")
+	}
+
+	writeConfig(second.URL(),
+		"address = \""+watchedAddress+"\"\nthreshold = \"1\"",
+		"address = \""+other+"\"\nthreshold = \"5\"")
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("Reload error: %v", err)
+	}
+	fake.Advance(time.Minute)
+
+	// Both addresses are now read from the new endpoint.
+	fake.BlockUntil(1)
+	if got := second.Requests("eth_call"); got != 2 {
+		t.Fatalf("second node eth_call requests = %d, want 2", got)
+	}
+	if got := first.Requests("eth_call"); got != 1 {
+		t.Fatalf("first node eth_call requests = %d, want 1", got)
+	}
+	states := store.Addresses()
+	if len(states) != 2 || states[1].Address != other || states[1].Balance == nil || states[1].Balance.Int64() != 2_000_000 {
+		t.Fatalf("unexpected states after reload: %+v", states)
+	}
+	cancel()
+	<-done
+}
+
+func TestReloaderRejectsWholeConfiguration(t *testing.T) {
+	const owner, spender = "0x00000000000000000000000000000000000000b1", "0x00000000000000000000000000000000000000b2"
+	first, second := rpctest.NewNode(t), rpctest.NewNode(t)
+	path := filepath.Join(t.TempDir(), "watcher.toml")
+	writeConfig := func(endpoint string, allowances int) {
+		content := fmt.Sprintf("[[rpc.endpoints]]\nname = \"node\"\nurl = %q\n\n[[watch]]\naddress = %q\nthreshold = \"1\"\n", endpoint, watchedAddress)
+		for i := 0; i < allowances; i++ {
+			content += fmt.Sprintf("\n[[allowance]]\nowner = %q\nspender = %q\n", owner, spender)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile error: %v", err)
+		}
+	}
+	writeConfig(first.URL(), 1)
+	cfg, err := loadConfig(path, "")
+	if err != nil {
+		t.Fatalf("loadConfig error: %v", err)
+	}
+	client, err := rpc.NewClient(cfg.Endpoints, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	watcher, err := NewWatcher(WatcherConfig{
+		Logger:     discardLogger(),
+		Client:     client,
+		Watches:    cfg.Watches,
+		Allowances: cfg.Allowances,
+		Interval:   time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher error: %v", err)
+	}
+	reloader := newReloader(path, "", nil, discardLogger(), client, watcher)
+
+	// The file parses, but the watcher rejects the repeated pair, so the new
+	// endpoint must not be applied either.
+	writeConfig(second.URL(), 2)
+	if err := reloader.Reload(); err == nil || !strings.Contains(err.Error(), "duplicate pair") {
+		t.Fatalf("Reload error = %v, want a duplicate pair", err)
+	}
+	if endpoints := client.Endpoints(); len(endpoints) != 1 || endpoints[0].URL != first.URL() {
+		t.Fatalf("endpoints after a rejected reload = %+v", endpoints)
+	}
+}
+
+func TestLoadConfigExpandsSelectors(t *testing.T) {
+	const (
+		hotOps     = "0x00000000000000000000000000000000000000a1"
+		hotFinance = "0x00000000000000000000000000000000000000a2"
+		cold       = "0x00000000000000000000000000000000000000a3"
+	)
+	content := `[[rpc.endpoints]]
+url = "https://a.example"
+
+[[address]]
+address = "` + hotOps + `"
+label = "ops hot"
+tags = ["hot-wallet"]
+
+[[address]]
+address = "` + hotFinance + `"
+label = "finance hot"
+team = "finance"
+tags = ["hot-wallet"]
+
+[[address]]
+address = "` + cold + `"
+label = "cold storage"
+tags = ["cold"]
+
+[[watch]]
+selector = "tag:hot-wallet"
+threshold = "10"
+
+[[watch]]
+address = "` + hotFinance + `"
+threshold = "99"
+`
+	path := filepath.Join(t.TempDir(), "watcher.toml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+	cfg, err := loadConfig(path, "")
+	if err != nil {
+		t.Fatalf("loadConfig error: %v", err)
+	}
+	watches := mergeWatches([]Watch{{Address: cold, Threshold: big.NewInt(1)}}, cfg)
+	got := make([]string, 0, len(watches))
+	for _, w := range watches {
+		got = append(got, fmt.Sprintf("%s=%s", w.Label, w.Threshold))
+	}
+	want := "cold storage=1 finance hot=99000000 ops hot=10000000"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("watches = %q, want %q", strings.Join(got, " "), want)
+	}
+
+	bad := strings.Replace(content, "tag:hot-wallet", "colour:red", 1)
+	if err := os.WriteFile(path, []byte(bad), 0o644); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+	if _, err := loadConfig(path, ""); err == nil || !strings.Contains(err.Error(), "unknown key") {
+		t.Fatalf("expected a selector error, got %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"usdc-watch/internal/alert"
+	"usdc-watch/internal/cursor"
+	"usdc-watch/internal/eth"
+	"usdc-watch/internal/history"
+)
+
+// maxRetractable bounds the recent alerts kept so they can be retracted
+// when a reorganisation orphans their block.
+const maxRetractable = 256
+
+// firedAlert is a recent alert tied to a block.
+type firedAlert struct {
+	data    alert.Data
+	message string
+}
+
+// recordFired remembers an alert raised for a block so a reorganisation
+// orphaning that block can retract it.
+func (w *Watcher) recordFired(data alert.Data, message string) {
+	if data.Block == 0 || data.Rule == alert.RuleReverted {
+		return
+	}
+	w.firedMu.Lock()
+	defer w.firedMu.Unlock()
+	w.fired = append(w.fired, firedAlert{data: data, message: message})
+	if len(w.fired) > maxRetractable {
+		w.fired = w.fired[len(w.fired)-maxRetractable:]
+	}
+}
+
+// checkReorg verifies that the head block of the previous cycle is still
+// canonical, rolling back what the watcher learnt from orphaned blocks when
+// it is not, and then records the current head.
+func (w *Watcher) checkReorg(ctx context.Context) {
+	if !w.cfg.Reorgs {
+		return
+	}
+	logger := w.cfg.Logger
+	checkCtx, cancel := context.WithTimeout(ctx, w.cfg.PollTimeout)
+	defer cancel()
+	// The head's number and hash come from one request, as an endpoint
+	// behind the one that reported the number may not have its block yet.
+	header, endpoint, err := w.cfg.Client.LatestHeader(checkCtx)
+	if err != nil {
+		logger.Warn("reorg check failed", "endpoint", endpoint.Name, "error", err)
+		return
+	}
+	head, err := eth.ParseQuantity(header.Number)
+	if err != nil {
+		logger.Warn("reorg check failed", "endpoint", endpoint.Name, "error", fmt.Errorf("decode head block number: %w", err))
+		return
+	}
+	reorg, err := w.chain.Verify(checkCtx, header, w.headerAt)
+	if err != nil {
+		logger.Warn("reorg check failed", "block", head, "error", err)
+		return
+	}
+	if reorg != nil {
+		w.revert(ctx, *reorg, "head")
+	}
+	if head >= w.chain.Block() {
+		if err := w.chain.Advance(head, header.Hash); err != nil {
+			logger.Error("advance head cursor failed", "block", head, "error", err)
+		}
+	}
+}
+
+// headerAt is a cursor.HeaderFunc over the watcher's client.
+func (w *Watcher) headerAt(ctx context.Context, block uint64) (eth.Header, error) {
+	header, _, err := w.cfg.Client.HeaderByNumber(ctx, block)
+	return header, err
+}
+
+// revert rolls back what the watcher learnt from the blocks a
+// reorganisation orphaned: balance observations, recorded transfers and the
+// positions of the event scans, which rescan from the fork. Alerts raised
+// for orphaned blocks are retracted with a reverted alert each.
+func (w *Watcher) revert(ctx context.Context, reorg cursor.Reorg, source string) {
+	logger := w.cfg.Logger
+	logger.Warn("chain reorganisation detected", "fork_block", reorg.Fork, "orphaned_block", reorg.Orphaned, "source", source)
+
+	w.cfg.Store.Revert(reorg.Fork)
+	w.mu.Lock()
+	for _, t := range w.targets {
+		if t.previous == nil || t.previous.Block <= reorg.Fork {
+			continue
+		}
+		t.previous = nil
+		if observations, _ := w.cfg.Store.History(t.Address); len(observations) > 0 {
+			last := observations[len(observations)-1]
+			t.previous = &balanceReading{Balance: last.Balance, Block: last.Block, Endpoint: last.Endpoint}
+		}
+	}
+	w.mu.Unlock()
+	if series := w.cfg.Series; series != nil {
+		if err := series.AppendRevert(history.Revert{Time: w.clock.Now(), Block: reorg.Fork + 1}); err != nil {
+			logger.Error("append revert history failed", "error", err)
+		}
+	}
+	for _, block := range []*uint64{&w.status.block, &w.approvals.block, &w.supplyState.block} {
+		*block = min(*block, reorg.Fork)
+	}
+	for name, c := range map[string]*cursor.Cursor{"head": w.chain, "whale": w.whaleState.cursor} {
+		if err := c.Rewind(reorg.Fork); err != nil {
+			logger.Error("rewind cursor failed", "cursor", name, "error", err)
+		}
+	}
+
+	w.firedMu.Lock()
+	kept := w.fired[:0]
+	var retracted []firedAlert
+	for _, fired := range w.fired {
+		if fired.data.Block > reorg.Fork {
+			retracted = append(retracted, fired)
+		} else {
+			kept = append(kept, fired)
+		}
+	}
+	w.fired = kept
+	w.firedMu.Unlock()
+	for _, fired := range retracted {
+		data := fired.data
+		w.raiseChangeAlert(ctx, alert.Data{
+			Rule:    alert.RuleReverted,
+			Address: data.Address,
+			Label:   data.Label,
+			Chain:   data.Chain,
+			Block:   data.Block,
+			TxHash:  data.TxHash,
+			Time:    w.clock.Now(),
+			Message: fired.message,
+			// A retraction clears the alert it refers to.
+			Recovered: true,
+		}, "reorg")
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/history"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/rpctest"
+)
+
+func TestWatcherRevertsOrphanedBlocks(t *testing.T) {
+	const (
+		alice = "0x00000000000000000000000000000000000000e1"
+		bob   = "0x00000000000000000000000000000000000000e2"
+	)
+	node := rpctest.NewNode(t)
+	node.SetHead(100)
+
+	messages := make(chan string, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		messages <- r.URL.Query().Get("message")
+	}))
+	defer webhook.Close()
+
+	client, err := rpc.NewClient([]config.Endpoint{node.Endpoint("node")}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	series, err := history.Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("history.Open error: %v", err)
+	}
+	defer series.Close()
+	watcher, err := NewWatcher(WatcherConfig{
+		Logger:   discardLogger(),
+		Client:   client,
+		Watches:  []Watch{{Address: watchedAddress, Threshold: big.NewInt(5_000_000)}},
+		Interval: time.Minute,
+		AlertURL: webhook.URL,
+		Whales:   &WhaleWatch{MinAmount: big.NewInt(1_000_000_000)},
+		Reorgs:   true,
+		Series:   series,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher error: %v", err)
+	}
+	drain := func() []string {
+		var got []string
+		for {
+			select {
+			case msg := <-messages:
+				got = append(got, msg)
+			default:
+				return got
+			}
+		}
+	}
+	ctx := context.Background()
+	cycle := func() []string {
+		watcher.checkReorg(ctx)
+		watcher.Poll(ctx)
+		watcher.checkWhales(ctx)
+		return drain()
+	}
+
+	if got := cycle(); len(got) != 0 {
+		t.Fatalf("unexpected alerts on the first cycle: %q", got)
+	}
+
+	node.SetBalance(watchedAddress, 102, big.NewInt(5_000_000))
+	node.AddTransfer(102, alice, watchedAddress, big.NewInt(5_000_000), "0xc1")
+	node.AddTransfer(102, alice, bob, big.NewInt(2_000_000_000), "0xc2")
+	node.SetHead(103)
+	thresholdAlert := "USDC balance 5.000000 >= threshold 5.000000"
+	whaleAlert := "USDC transfer of 2000.000000 from " + alice + " to " + bob + " at block 102"
+	if got, want := cycle(), []string{thresholdAlert, whaleAlert}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("alerts before the reorg = %q, want %q", got, want)
+	}
+
+	node.Reorg(100)
+	node.SetHead(104)
+	want := []string{
+		"Retracted, block 103 was orphaned by a chain reorganisation: " + thresholdAlert,
+		"Retracted, block 102 was orphaned by a chain reorganisation: " + whaleAlert,
+	}
+	if got := cycle(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("alerts after the reorg = %q, want %q", got, want)
+	}
+	if got := cycle(); len(got) != 0 {
+		t.Fatalf("unexpected alerts after the rollback: %q", got)
+	}
+
+	span := [2]time.Time{time.Now().Add(-time.Hour), time.Now().Add(time.Hour)}
+	if transfers, err := series.Transfers(watchedAddress, span[0], span[1]); err != nil || len(transfers) != 0 {
+		t.Fatalf("Transfers = %+v, %v; want the orphaned transfer reverted", transfers, err)
+	}
+	points, err := series.Query(watchedAddress, span[0], span[1])
+	if err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	for _, p := range points {
+		if p.Balance.Sign() != 0 {
+			t.Fatalf("history still holds the orphaned balance: %+v", points)
+		}
+	}
+	if got := watcher.whaleState.cursor.Block(); got != 104 {
+		t.Fatalf("whale cursor at block %d, want 104", got)
+	}
+}
+
+func TestReorgCheckWithLaggingEndpoint(t *testing.T) {
+	ahead, lagging := rpctest.NewNode(t), rpctest.NewNode(t)
+	ahead.SetHead(100)
+	lagging.SetHead(90)
+	client, err := rpc.NewClient([]config.Endpoint{ahead.Endpoint("ahead"), lagging.Endpoint("lagging")}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	var out strings.Builder
+	logger, err := newLogger(&out, "json", "info")
+	if err != nil {
+		t.Fatalf("newLogger error: %v", err)
+	}
+	watcher, err := NewWatcher(WatcherConfig{
+		Logger:   logger,
+		Client:   client,
+		Watches:  []Watch{{Address: watchedAddress, Threshold: big.NewInt(1)}},
+		Interval: time.Minute,
+		Reorgs:   true,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher error: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		watcher.checkReorg(context.Background())
+	}
+	if strings.Contains(out.String(), "reorg check failed") {
+		t.Fatalf("reorg check failed with a lagging endpoint:\n%s", out.String())
+	}
+	if got := watcher.chain.Block(); got != 100 {
+		t.Fatalf("head cursor at block %d, want 100", got)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/rpctest"
+)
+
+func TestWatcherReplaysRecordedCassette(t *testing.T) {
+	messages := make(chan string, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		messages <- r.URL.Query().Get("message")
+	}))
+	defer webhook.Close()
+	watch := func(client *rpc.Client) string {
+		t.Helper()
+		watcher, err := NewWatcher(WatcherConfig{
+			Logger:   discardLogger(),
+			Client:   client,
+			Watches:  []Watch{{Address: watchedAddress, Threshold: big.NewInt(5_000_000)}},
+			Interval: time.Minute,
+			Once:     true,
+			AlertURL: webhook.URL,
+			Status:   StatusConfig{Blacklist: true, Paused: true},
+			Reorgs:   true,
+		})
+		if err != nil {
+			t.Fatalf("NewWatcher error: %v", err)
+		}
+		if err := watcher.Run(context.Background()); err != nil {
+			t.Fatalf("Run error: %v", err)
+		}
+		select {
+		case msg := <-messages:
+			return msg
+		default:
+			return ""
+		}
+	}
+
+	node := rpctest.NewNode(t)
+	node.SetHead(100)
+	node.SetBalance(watchedAddress, 90, big.NewInt(7_000_000))
+	node.InjectFault(rpctest.Fault{Methods: []string{"eth_call"}, HTTPStatus: http.StatusBadGateway, Times: 1})
+	endpoints := []config.Endpoint{node.Endpoint("primary"), node.Endpoint("backup")}
+	path := filepath.Join(t.TempDir(), "incident.jsonl")
+	cassette, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create error: %v", err)
+	}
+	live, err := rpc.NewClient(endpoints, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	recorder := rpc.NewRecorder(cassette)
+	live.SetRecorder(recorder)
+	recorded := watch(live)
+	if err := cassette.Close(); err != nil || recorder.Err() != nil {
+		t.Fatalf("closing cassette: %v, %v", err, recorder.Err())
+	}
+	if recorded != "USDC balance 7.000000 >= threshold 5.000000" {
+		t.Fatalf("recorded alert %q", recorded)
+	}
+
+	replayer, err := loadReplayer(path)
+	if err != nil {
+		t.Fatalf("loadReplayer error: %v", err)
+	}
+	offline, err := rpc.NewClient([]config.Endpoint{
+		{Name: "primary", URL: "http://primary.invalid"},
+		{Name: "backup", URL: "http://backup.invalid"},
+	}, &http.Client{Transport: replayer})
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	if replayed := watch(offline); replayed != recorded {
+		t.Fatalf("replayed alert %q, recorded %q", replayed, recorded)
+	}
+	if n := replayer.Unplayed(); n != 0 {
+		t.Fatalf("%d recorded interactions were not replayed", n)
+	}
+	for i, health := range offline.Health() {
+		if want := live.Health()[i]; health.Requests != want.Requests || health.Failures != want.Failures {
+			t.Fatalf("replayed %s: %d requests, %d failures; recorded %d, %d", health.Name, health.Requests, health.Failures, want.Requests, want.Failures)
+		}
+	}
+}
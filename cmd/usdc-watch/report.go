@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"usdc-watch/internal/history"
+	"usdc-watch/internal/report"
+)
+
+// runReport implements the report subcommand and returns the process exit code.
+func runReport(args []string) int {
+	fs := newFlagSet("report", "report --history-dir DIR [flags]",
+		"Summarise stored balances, transfers and alerts per address for a daily or weekly period.")
+	historyDir := fs.String("history-dir", "", "Directory holding the balance history (required)")
+	addresses := fs.String("address", "", "Comma-separated addresses to include (default: all with data)")
+	period := fs.String("period", "daily", "Report period: daily or weekly")
+	endFlag := fs.String("end", "", "End of the period in RFC 3339 (default: now)")
+	format := fs.String("format", "markdown", "Output format: markdown, csv or json")
+	top := fs.Int("top", report.DefaultTopTransfers, "Number of largest transfers listed per address")
+	schedule := fs.String("schedule", "", "POST the report to --alert-url at HH:MM local time, daily or on Mondays for a weekly period")
+	alertURL := fs.String("alert-url", "", "Alert webhook base URL used by --schedule")
+	logOpts := addLogFlags(fs)
+	if ok, code := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	logger, err := logOpts.logger(os.Stderr)
+	if err != nil {
+		return usageError(fs, "configure logging: %v", err)
+	}
+	if *historyDir == "" {
+		return usageError(fs, "--history-dir is required")
+	}
+	selected, err := parseAddressList(*addresses, fs.Args())
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+	if _, err := report.PeriodEnding(*period, time.Now()); err != nil {
+		return usageError(fs, "%v", err)
+	}
+	if err := report.ValidFormat(*format); err != nil {
+		return usageError(fs, "%v", err)
+	}
+
+	series, err := history.Open(*historyDir, 0)
+	if err != nil {
+		logger.Error("open balance history", "error", err)
+		return exitFailure
+	}
+	defer series.Close()
+
+	build := func(end time.Time) (report.Report, error) {
+		p, err := report.PeriodEnding(*period, end)
+		if err != nil {
+			return report.Report{}, err
+		}
+		return report.Build(series, selected, p, *top)
+	}
+
+	if *schedule == "" {
+		end := time.Now()
+		if *endFlag != "" {
+			end, err = time.Parse(time.RFC3339, *endFlag)
+			if err != nil {
+				return usageError(fs, "invalid --end: %v", err)
+			}
+		}
+		rep, err := build(end)
+		if err == nil {
+			err = report.Render(os.Stdout, *format, rep)
+		}
+		if err != nil {
+			logger.Error("generate report", "error", err)
+			return exitFailure
+		}
+		return exitOK
+	}
+
+	hour, minute, err := parseClock(*schedule)
+	if err != nil {
+		return usageError(fs, "invalid --schedule: %v", err)
+	}
+	if *alertURL == "" {
+		return usageError(fs, "--alert-url is required with --schedule")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	runReportSchedule(ctx, logger, *period, hour, minute, func(end time.Time) error {
+		rep, err := build(end)
+		if err != nil {
+			return err
+		}
+		var b strings.Builder
+		if err := report.Render(&b, *format, rep); err != nil {
+			return err
+		}
+		sendCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		return sendReport(sendCtx, http.DefaultClient, *alertURL, report.Summary(rep), report.ContentType(*format), b.String())
+	})
+	return exitOK
+}
+
+// runReportSchedule calls send for the period ending at each run of the
+// schedule for period until ctx is done.
+func runReportSchedule(ctx context.Context, logger *slog.Logger, period string, hour, minute int, send func(end time.Time) error) {
+	for {
+		next := nextReportRun(time.Now(), period, hour, minute)
+		logger.Info("next report scheduled", "at", next.Format(time.RFC3339))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+		if err := send(next); err != nil {
+			logger.Error("scheduled report failed", "error", err)
+			continue
+		}
+		logger.Info("scheduled report sent", "period_end", next.Format(time.RFC3339))
+	}
+}
+
+// nextReportRun returns when the report for period is next due: the next
+// hour:minute for a daily report, the next Monday at hour:minute for a weekly
+// one, so each report covers the time since the previous.
+func nextReportRun(now time.Time, period string, hour, minute int) time.Time {
+	next := nextDailyRun(now, hour, minute)
+	if period == "weekly" {
+		next = next.AddDate(0, 0, (int(time.Monday)-int(next.Weekday())+7)%7)
+	}
+	return next
+}
+
+// nextDailyRun returns the first time strictly after now at hour:minute in now's location.
+func nextDailyRun(now time.Time, hour, minute int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// parseClock parses an HH:MM time of day.
+func parseClock(value string) (int, int, error) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, 0, fmt.Errorf("expected HH:MM, got %q", value)
+	}
+	return parsed.Hour(), parsed.Minute(), nil
+}
+
+// sendReport POSTs a rendered report to the alert webhook, with its one-line
+// summary in the message parameter for receivers that only read that; a full
+// report is too long for a query string.
+func sendReport(ctx context.Context, client *http.Client, baseURL, summary, contentType, body string) error {
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return fmt.Errorf("parse alert url: %w", err)
+	}
+	query := parsed.Query()
+	query.Set("message", summary)
+	parsed.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, parsed.String(), strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build report request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send report request: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("report request failed with HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
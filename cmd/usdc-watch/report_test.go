@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNextDailyRun(t *testing.T) {
+	loc := time.FixedZone("test", 2*60*60)
+	now := time.Date(2026, 10, 18, 8, 30, 0, 0, loc)
+
+	if got := nextDailyRun(now, 9, 0); !got.Equal(time.Date(2026, 10, 18, 9, 0, 0, 0, loc)) {
+		t.Fatalf("expected same-day run, got %s", got)
+	}
+	if got := nextDailyRun(now, 8, 30); !got.Equal(time.Date(2026, 10, 19, 8, 30, 0, 0, loc)) {
+		t.Fatalf("expected next-day run at the current minute, got %s", got)
+	}
+}
+
+func TestNextReportRunWeekly(t *testing.T) {
+	loc := time.FixedZone("test", 2*60*60)
+	// A Sunday.
+	now := time.Date(2026, 10, 18, 8, 30, 0, 0, loc)
+
+	if got := nextReportRun(now, "daily", 9, 0); !got.Equal(time.Date(2026, 10, 18, 9, 0, 0, 0, loc)) {
+		t.Fatalf("daily run at %s", got)
+	}
+	if got := nextReportRun(now, "weekly", 9, 0); !got.Equal(time.Date(2026, 10, 19, 9, 0, 0, 0, loc)) {
+		t.Fatalf("expected the weekly run on Monday, got %s", got)
+	}
+	monday := time.Date(2026, 10, 19, 9, 0, 0, 0, loc)
+	if got := nextReportRun(monday, "weekly", 9, 0); !got.Equal(monday.AddDate(0, 0, 7)) {
+		t.Fatalf("expected the following Monday, got %s", got)
+	}
+}
+
+func TestSendReportPostsBody(t *testing.T) {
+	var method, message, contentType, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, message, contentType = r.Method, r.URL.Query().Get("message"), r.Header.Get("Content-Type")
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer srv.Close()
+
+	if err := sendReport(context.Background(), srv.Client(), srv.URL+"/hook?channel=ops", "summary", "text/csv", "a,b\n"); err != nil {
+		t.Fatalf("sendReport error: %v", err)
+	}
+	if method != http.MethodPost || message != "summary" || contentType != "text/csv" || body != "a,b\n" {
+		t.Fatalf("got %s message %q type %q body %q", method, message, contentType, body)
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	hour, minute, err := parseClock("07:45")
+	if err != nil || hour != 7 || minute != 45 {
+		t.Fatalf("parseClock = %d, %d, %v", hour, minute, err)
+	}
+	if _, _, err := parseClock("25:00"); err == nil {
+		t.Fatalf("expected error for invalid clock")
+	}
+}
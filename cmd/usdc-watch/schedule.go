@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"time"
+
+	"usdc-watch/internal/eth"
+)
+
+// blockAlignDelay is how long after an expected block the aligned poll fires,
+// giving endpoints time to import the block.
+const blockAlignDelay = 2 * time.Second
+
+// Schedule adapts the polling interval to the watcher's situation. The zero
+// value polls at a fixed interval.
+type Schedule struct {
+	// MinInterval is the fastest polling interval, reached as the balance
+	// approaches the threshold. Zero disables the speed-up.
+	MinInterval time.Duration
+	// Approach is the distance from the threshold, as a fraction of it, within
+	// which polling speeds up.
+	Approach float64
+	// MaxBackoff caps the exponential backoff applied after consecutive poll
+	// failures. Zero disables backoff.
+	MaxBackoff time.Duration
+	// Jitter randomises each delay by up to this fraction in either direction.
+	Jitter float64
+	// BlockTime aligns polls to just after the next expected block, counted
+	// from the timestamp of the latest head block. Zero disables alignment.
+	BlockTime time.Duration
+	// Rand returns values in [0, 1); rand.Float64 when nil.
+	Rand func() float64
+}
+
+// scheduleState is what the watcher knows when choosing its next delay.
+type scheduleState struct {
+	now       time.Time
+	failures  int
+	balance   *big.Int
+	threshold *big.Int
+	// headTime is the timestamp of the latest head block; zero when unknown.
+	headTime time.Time
+}
+
+// next returns the delay before the poll that follows a poll in state st.
+func (s Schedule) next(interval time.Duration, st scheduleState) time.Duration {
+	if st.failures > 0 && s.MaxBackoff > 0 {
+		return s.jitter(s.backoff(interval, st.failures))
+	}
+	delay := s.approach(interval, st.balance, st.threshold)
+	if s.BlockTime > 0 && !st.headTime.IsZero() {
+		elapsed := st.now.Add(delay).Sub(st.headTime)
+		blocks := (elapsed + s.BlockTime - 1) / s.BlockTime
+		delay = st.headTime.Add(blocks*s.BlockTime + blockAlignDelay).Sub(st.now)
+	}
+	return s.jitter(delay)
+}
+
+// refreshHeadTime reads the timestamp of the head block once it advanced, so
+// polls align to the chain's block grid rather than to when a poll happened
+// to notice the block. A failed read keeps the previous timestamp.
+func (w *Watcher) refreshHeadTime(ctx context.Context) {
+	if w.cfg.Schedule.BlockTime <= 0 || w.head == 0 || w.head == w.headTimeBlock {
+		return
+	}
+	headerCtx, cancel := context.WithTimeout(ctx, w.cfg.PollTimeout)
+	header, endpoint, err := w.cfg.Client.HeaderByNumber(headerCtx, w.head)
+	cancel()
+	if err != nil {
+		w.cfg.Logger.Warn("head block timestamp lookup failed", "block", w.head, "endpoint", endpoint.Name, "error", err)
+		return
+	}
+	seconds, err := eth.ParseQuantity(header.Timestamp)
+	if err != nil {
+		w.cfg.Logger.Warn("invalid head block timestamp", "block", w.head, "endpoint", endpoint.Name, "error", err)
+		return
+	}
+	w.headTime, w.headTimeBlock = time.Unix(int64(seconds), 0).UTC(), w.head
+}
+
+// backoff doubles interval for every consecutive failure after the first,
+// capped at MaxBackoff. An interval already above MaxBackoff is kept as is, so
+// a failing endpoint is never polled more often than a healthy one.
+func (s Schedule) backoff(interval time.Duration, failures int) time.Duration {
+	limit := max(interval, s.MaxBackoff)
+	delay := interval
+	for i := 1; i < failures && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
+
+// approach shortens interval linearly towards MinInterval as balance closes in
+// on threshold.
+func (s Schedule) approach(interval time.Duration, balance, threshold *big.Int) time.Duration {
+	if s.MinInterval <= 0 || s.MinInterval >= interval || s.Approach <= 0 {
+		return interval
+	}
+	if balance == nil || threshold == nil || threshold.Sign() <= 0 || balance.Cmp(threshold) >= 0 {
+		return interval
+	}
+	gap := new(big.Float).SetInt(new(big.Int).Sub(threshold, balance))
+	ratio, _ := gap.Quo(gap, new(big.Float).SetInt(threshold)).Float64()
+	if ratio >= s.Approach {
+		return interval
+	}
+	span := float64(interval - s.MinInterval)
+	return s.MinInterval + time.Duration(span*ratio/s.Approach)
+}
+
+func (s Schedule) jitter(delay time.Duration) time.Duration {
+	if s.Jitter <= 0 {
+		return delay
+	}
+	random := s.Rand
+	if random == nil {
+		random = rand.Float64
+	}
+	return delay + time.Duration(float64(delay)*s.Jitter*(2*random()-1))
+}
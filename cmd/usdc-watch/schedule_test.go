@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"usdc-watch/internal/clock"
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/rpctest"
+)
+
+func TestScheduleNext(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	threshold := big.NewInt(1_000_000)
+	tests := []struct {
+		name     string
+		schedule Schedule
+		state    scheduleState
+		want     time.Duration
+	}{
+		{
+			name:  "fixed",
+			state: scheduleState{now: now, balance: big.NewInt(999_999), threshold: threshold},
+			want:  time.Minute,
+		},
+		{
+			name:     "first failure keeps interval",
+			schedule: Schedule{MaxBackoff: 10 * time.Minute},
+			state:    scheduleState{now: now, failures: 1},
+			want:     time.Minute,
+		},
+		{
+			name:     "backoff doubles",
+			schedule: Schedule{MaxBackoff: 10 * time.Minute},
+			state:    scheduleState{now: now, failures: 3},
+			want:     4 * time.Minute,
+		},
+		{
+			name:     "backoff capped",
+			schedule: Schedule{MaxBackoff: 10 * time.Minute},
+			state:    scheduleState{now: now, failures: 50},
+			want:     10 * time.Minute,
+		},
+		{
+			name:     "backoff never shortens interval",
+			schedule: Schedule{MaxBackoff: 30 * time.Second},
+			state:    scheduleState{now: now, failures: 3},
+			want:     time.Minute,
+		},
+		{
+			name:     "far from threshold",
+			schedule: Schedule{MinInterval: 10 * time.Second, Approach: 0.2},
+			state:    scheduleState{now: now, balance: big.NewInt(500_000), threshold: threshold},
+			want:     time.Minute,
+		},
+		{
+			name:     "halfway into approach",
+			schedule: Schedule{MinInterval: 10 * time.Second, Approach: 0.2},
+			state:    scheduleState{now: now, balance: big.NewInt(900_000), threshold: threshold},
+			want:     35 * time.Second,
+		},
+		{
+			name:     "at threshold edge",
+			schedule: Schedule{MinInterval: 10 * time.Second, Approach: 0.2},
+			state:    scheduleState{now: now, balance: big.NewInt(999_999), threshold: threshold},
+			want:     10*time.Second + 250*time.Microsecond,
+		},
+		{
+			name:     "jitter",
+			schedule: Schedule{Jitter: 0.1, Rand: func() float64 { return 1 }},
+			state:    scheduleState{now: now},
+			want:     66 * time.Second,
+		},
+		{
+			name:     "aligned to next block",
+			schedule: Schedule{BlockTime: 12 * time.Second},
+			state:    scheduleState{now: now, headTime: now.Add(-5 * time.Second)},
+			want:     67*time.Second + blockAlignDelay,
+		},
+		{
+			name:     "jitter after alignment",
+			schedule: Schedule{BlockTime: 12 * time.Second, Jitter: 0.1, Rand: func() float64 { return 0 }},
+			state:    scheduleState{now: now, headTime: now.Add(-5 * time.Second)},
+			want:     (67*time.Second + blockAlignDelay) * 9 / 10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schedule.next(time.Minute, tt.state); got != tt.want {
+				t.Fatalf("next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWatcherBacksOffWhileFailing(t *testing.T) {
+	node := rpctest.NewNode(t)
+	node.SetHead(100)
+	node.SetBalance(watchedAddress, 0, big.NewInt(500_000))
+	node.InjectFault(rpctest.Fault{RPCError: &rpctest.RPCError{Code: -32000, Message: "node is syncing"}})
+	client, err := rpc.NewClient([]config.Endpoint{node.Endpoint("node")}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	watcher, err := NewWatcher(WatcherConfig{
+		Logger:   discardLogger(),
+		Client:   client,
+		Watches:  []Watch{{Address: watchedAddress, Threshold: big.NewInt(1_000_000)}},
+		Interval: time.Minute,
+		Schedule: Schedule{MaxBackoff: 8 * time.Minute},
+		Clock:    fake,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- watcher.Run(ctx) }()
+
+	want := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 8 * time.Minute}
+	for i, delay := range want {
+		fake.BlockUntil(1)
+		if got, _ := fake.NextDeadline(); got != delay {
+			t.Fatalf("delay %d = %v, want %v", i, got, delay)
+		}
+		if i == len(want)-1 {
+			node.ClearFaults()
+		}
+		fake.Advance(delay)
+	}
+	fake.BlockUntil(1)
+	if got, _ := fake.NextDeadline(); got != time.Minute {
+		t.Fatalf("delay after recovery = %v, want 1m", got)
+	}
+	cancel()
+	<-done
+}
+
+func TestWatcherAlignsToHeadTimestamp(t *testing.T) {
+	node := rpctest.NewNode(t)
+	node.SetHead(100)
+	client, err := rpc.NewClient([]config.Endpoint{node.Endpoint("node")}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	headTime := rpctest.GenesisTime.Add(100 * rpctest.BlockTime)
+	// The poll notices block 100 five seconds after it was produced.
+	fake := clock.NewFake(headTime.Add(5 * time.Second))
+	watcher, err := NewWatcher(WatcherConfig{
+		Logger:   discardLogger(),
+		Client:   client,
+		Watches:  []Watch{{Address: watchedAddress, Threshold: big.NewInt(1_000_000)}},
+		Interval: time.Minute,
+		Schedule: Schedule{BlockTime: rpctest.BlockTime},
+		Clock:    fake,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher error: %v", err)
+	}
+	watcher.Poll(context.Background())
+
+	// The next grid slot after a minute is block 106, 72s after block 100.
+	if got, want := watcher.nextDelay(), 72*time.Second+blockAlignDelay-5*time.Second; got != want {
+		t.Fatalf("nextDelay = %v, want %v", got, want)
+	}
+}
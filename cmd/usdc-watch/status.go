@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"usdc-watch/internal/alert"
+	"usdc-watch/internal/eth"
+	"usdc-watch/internal/history"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/usdc"
+)
+
+// StatusConfig enables monitoring of the USDC contract's administrative
+// controls. The zero value disables both checks.
+type StatusConfig struct {
+	// Blacklist alerts when a watched address is blacklisted or unblacklisted.
+	Blacklist bool
+	// Paused alerts when the contract is paused or unpaused.
+	Paused bool
+}
+
+// statusState is what the status checks know between poll cycles.
+type statusState struct {
+	// block is the last block checked; status events after it are scanned on
+	// the next check. Zero before the first successful check.
+	block uint64
+	// paused is the last known paused state; nil until checked.
+	paused *bool
+}
+
+// statusChange is a blacklist or pause change to report.
+type statusChange struct {
+	target *target // nil for pause changes
+	set    bool
+	block  uint64
+	txHash string
+	source string
+}
+
+// checkStatus scans the status events emitted since the previous check, then
+// reads the current blacklist and paused state at the head block. Every
+// change of a known state is reported once, whichever of the two reveals it
+// first; a state found set on the first check is reported too.
+func (w *Watcher) checkStatus(ctx context.Context) {
+	cfg := w.cfg.Status
+	if !cfg.Blacklist && !cfg.Paused {
+		return
+	}
+	logger := w.cfg.Logger
+	checkCtx, cancel := context.WithTimeout(ctx, w.cfg.PollTimeout)
+	defer cancel()
+	head, endpoint, err := w.cfg.Client.BlockNumber(checkCtx)
+	if err != nil {
+		logger.Warn("status check failed", "endpoint", endpoint.Name, "error", err)
+		return
+	}
+	if head < w.status.block {
+		return
+	}
+	targets := w.snapshot()
+	byAddress := make(map[string]*target, len(targets))
+	for _, t := range targets {
+		byAddress[t.Address] = t
+	}
+
+	// complete stays true when every lookup succeeded, so the next check can
+	// start after head.
+	complete := true
+	if from := w.status.block + 1; w.status.block > 0 && from <= head {
+		if head-from >= maxTransferLookback {
+			from = head - maxTransferLookback + 1
+		}
+		events, err := fetchStatusEvents(checkCtx, w.cfg.Client, from, head)
+		if err != nil {
+			logger.Warn("status event lookup failed", "from_block", from, "to_block", head, "error", err)
+			complete = false
+		}
+		for _, event := range events {
+			change := statusChange{set: event.Paused, block: event.Block, txHash: event.TxHash, source: "event"}
+			if !event.IsPause() {
+				t, watched := byAddress[event.Account]
+				if !watched || !cfg.Blacklist {
+					logger.Debug("blacklist event for unwatched address", addressAttr(event.Account), "blacklisted", event.Blacklisted, "tx", event.TxHash)
+					continue
+				}
+				change.target, change.set = t, event.Blacklisted
+			} else if !cfg.Paused {
+				continue
+			}
+			w.applyStatus(ctx, change)
+		}
+	}
+
+	if cfg.Paused {
+		paused, endpoint, err := fetchFlag(checkCtx, w.cfg.Client, usdc.EncodePausedCall(), head)
+		if err != nil {
+			logger.Warn("paused check failed", "endpoint", endpoint, "error", err)
+			complete = false
+		} else {
+			w.applyStatus(ctx, statusChange{set: paused, block: head, source: "call"})
+		}
+	}
+	if cfg.Blacklist {
+		for _, t := range targets {
+			callData, err := usdc.EncodeIsBlacklistedCall(t.Address)
+			if err != nil {
+				logger.Error("encode blacklist call failed", addressAttr(t.Address), "error", err)
+				continue
+			}
+			blacklisted, endpoint, err := fetchFlag(checkCtx, w.cfg.Client, callData, head)
+			if err != nil {
+				logger.Warn("blacklist check failed", addressAttr(t.Address), labelAttr(t.Label), "endpoint", endpoint, "error", err)
+				complete = false
+				continue
+			}
+			w.applyStatus(ctx, statusChange{target: t, set: blacklisted, block: head, source: "call"})
+		}
+	}
+	if complete {
+		w.status.block = head
+	}
+}
+
+// applyStatus records change and alerts when it differs from the known state.
+func (w *Watcher) applyStatus(ctx context.Context, change statusChange) {
+	w.mu.Lock()
+	known := &w.status.paused
+	if change.target != nil {
+		known = &change.target.blacklisted
+	}
+	previous := *known
+	set := change.set
+	*known = &set
+	w.mu.Unlock()
+	if previous == nil && !set || previous != nil && *previous == set {
+		return
+	}
+	data := alert.Data{
+		Rule:      alert.RulePaused,
+		Chain:     w.cfg.Chain,
+		Block:     change.block,
+		Time:      w.clock.Now(),
+		TxHash:    change.txHash,
+		Recovered: !change.set,
+	}
+	if t := change.target; t != nil {
+		data.Rule, data.Address, data.Label = alert.RuleBlacklisted, t.Address, t.Label
+	}
+	w.raiseChangeAlert(ctx, data, change.source)
+}
+
+// raiseChangeAlert reports a change of contract state, such as a blacklisting
+// or an allowance, revealed by an event or a call as source says. Both
+// directions are recorded, the clearing one as recovered.
+func (w *Watcher) raiseChangeAlert(ctx context.Context, data alert.Data, source string) {
+	attrs := []any{"rule", data.Rule, "block", data.Block, "source", source}
+	if data.Address != "" {
+		attrs = append(attrs, addressAttr(data.Address), labelAttr(data.Label))
+	}
+	if data.Spender != "" {
+		attrs = append(attrs, "spender", data.Spender)
+	}
+	if data.TxHash != "" {
+		attrs = append(attrs, "tx", data.TxHash)
+	}
+	alertLogger := w.cfg.Logger.With(attrs...)
+	if w.cfg.Enrich && data.TxHash != "" && !data.Recovered && data.Rule != alert.RulePending && data.Transactions == nil {
+		data.Transactions = w.lookupTransactions(ctx, alertLogger, []string{data.TxHash})
+	}
+	message := w.render(alertLogger, "", data)
+	if data.Recovered {
+		alertLogger.Warn("contract alert cleared", "message", message)
+	} else {
+		alertLogger.Error("contract alert", "message", message)
+	}
+	w.recordFired(data, message)
+	w.cfg.Metrics.observeAlert(data.Address, data.Rule)
+	if series := w.cfg.Series; series != nil {
+		occurrence := history.Alert{Time: data.Time, Address: data.Address, Rule: data.Rule, Block: data.Block, Message: message}
+		if err := series.AppendAlert(occurrence); err != nil {
+			alertLogger.Error("append alert history failed", "error", err)
+		}
+	}
+	w.notify(ctx, alertLogger, data)
+}
+
+// fetchStatusEvents returns the USDC status events in [fromBlock, toBlock],
+// in chain order.
+func fetchStatusEvents(ctx context.Context, client *rpc.Client, fromBlock, toBlock uint64) ([]usdc.StatusEvent, error) {
+	logs, _, err := client.GetLogs(ctx, rpc.LogFilter{
+		FromBlock: fromBlock,
+		ToBlock:   toBlock,
+		Address:   usdc.ContractAddress,
+		Topics:    []interface{}{usdc.StatusTopics},
+	})
+	if err != nil {
+		return nil, err
+	}
+	events := make([]usdc.StatusEvent, 0, len(logs))
+	for _, log := range logs {
+		if log.Removed {
+			continue
+		}
+		event, err := usdc.DecodeStatusEvent(log)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Block != events[j].Block {
+			return events[i].Block < events[j].Block
+		}
+		return events[i].LogIndex < events[j].LogIndex
+	})
+	return events, nil
+}
+
+// fetchFlag reads a bool-returning USDC view call at block and returns the
+// name of the endpoint that answered.
+func fetchFlag(ctx context.Context, client *rpc.Client, callData string, block uint64) (bool, string, error) {
+	result, endpoint, err := callUSDC(ctx, client, callData, block)
+	if err != nil {
+		return false, endpoint, err
+	}
+	value, err := usdc.DecodeBool(result)
+	return value, endpoint, err
+}
+
+// callUSDC runs an eth_call against the USDC contract at block and returns
+// the hex result and the name of the endpoint that answered.
+func callUSDC(ctx context.Context, client *rpc.Client, callData string, block uint64) (string, string, error) {
+	params := []interface{}{
+		map[string]string{"to": usdc.ContractAddress, "data": callData},
+		eth.FormatQuantity(block),
+	}
+	raw, endpoint, err := client.Call(ctx, "eth_call", params)
+	if err != nil {
+		return "", endpoint.Name, err
+	}
+	var result string
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return "", endpoint.Name, fmt.Errorf("decode result: %w", err)
+	}
+	return result, endpoint.Name, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/rpctest"
+)
+
+func TestWatcherReportsBlacklistAndPauseChanges(t *testing.T) {
+	const treasury = "0x00000000000000000000000000000000000000a2"
+	node := rpctest.NewNode(t)
+	node.SetHead(100)
+	node.SetBlacklisted(50, treasury, true, "0xb0")
+
+	messages := make(chan string, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		messages <- r.URL.Query().Get("message")
+	}))
+	defer webhook.Close()
+
+	client, err := rpc.NewClient([]config.Endpoint{node.Endpoint("node")}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	watcher, err := NewWatcher(WatcherConfig{
+		Logger: discardLogger(),
+		Client: client,
+		Watches: []Watch{
+			{Address: watchedAddress, Threshold: big.NewInt(1)},
+			{Address: treasury, Label: "treasury", Threshold: big.NewInt(1)},
+		},
+		Interval: time.Minute,
+		AlertURL: webhook.URL,
+		Status:   StatusConfig{Blacklist: true, Paused: true},
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher error: %v", err)
+	}
+	drain := func() []string {
+		var got []string
+		for {
+			select {
+			case msg := <-messages:
+				got = append(got, msg)
+			default:
+				return got
+			}
+		}
+	}
+	ctx := context.Background()
+
+	watcher.checkStatus(ctx)
+	if got, want := drain(), []string{"treasury: " + treasury + " added to the USDC blacklist at block 100"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("first check alerts = %q, want %q", got, want)
+	}
+
+	// A blacklisting reverted before the next check is only visible in events.
+	node.SetBlacklisted(102, watchedAddress, true, "0xb1")
+	node.SetBlacklisted(103, watchedAddress, false, "0xb2")
+	node.SetBlacklisted(103, "0x00000000000000000000000000000000000000c0", true, "0xb3")
+	node.SetPaused(104, true, "0xe1")
+	node.SetHead(105)
+	watcher.checkStatus(ctx)
+	want := []string{
+		watchedAddress + " added to the USDC blacklist at block 102",
+		watchedAddress + " removed from the USDC blacklist at block 103",
+		"USDC contract paused at block 104",
+	}
+	if got := drain(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("event alerts = %q, want %q", got, want)
+	}
+
+	// With the event scan failing, the state call still reveals the change,
+	// and replaying the event on the next check does not repeat the alert.
+	node.SetPaused(106, false, "0xe2")
+	node.SetHead(106)
+	node.InjectFault(rpctest.Fault{Methods: []string{"eth_getLogs"}, Times: 1, HTTPStatus: http.StatusBadGateway})
+	watcher.checkStatus(ctx)
+	if got, want := drain(), []string{"USDC contract unpaused at block 106"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("state call alerts = %q, want %q", got, want)
+	}
+	node.SetHead(107)
+	watcher.checkStatus(ctx)
+	if got := drain(); len(got) != 0 {
+		t.Fatalf("unexpected repeated alerts: %q", got)
+	}
+	if node.Requests("eth_getLogs") != 3 {
+		t.Fatalf("expected the failed event scan to be retried, got %d eth_getLogs requests", node.Requests("eth_getLogs"))
+	}
+}
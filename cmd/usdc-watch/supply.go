@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"usdc-watch/internal/alert"
+	"usdc-watch/internal/eth"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/usdc"
+)
+
+// SupplyWatch is the aggregate watch on USDC's total supply. A nil amount
+// disables its rule.
+type SupplyWatch struct {
+	// MintThreshold and BurnThreshold alert on single mints or burns of at
+	// least this many base units.
+	MintThreshold *big.Int
+	BurnThreshold *big.Int
+	// NetChange alerts while the supply has moved by at least this many base
+	// units, in either direction, over Window.
+	NetChange *big.Int
+	Window    time.Duration
+}
+
+// supplySample is one total supply reading.
+type supplySample struct {
+	at     time.Time
+	block  uint64
+	supply *big.Int
+}
+
+// supplyState is what the supply watch knows between poll cycles.
+type supplyState struct {
+	// block is the last block scanned for mints and burns; zero before the
+	// first check.
+	block uint64
+	// samples holds the readings within the window plus the newest one
+	// before it, which is the baseline for the net change.
+	samples  []supplySample
+	changing bool
+}
+
+// SetSupply replaces the supply watch; nil disables it.
+func (w *Watcher) SetSupply(supply *SupplyWatch) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.supply = supply
+}
+
+// checkSupply scans the mints and burns since the previous check for large
+// ones, then reads the total supply at the head block and evaluates its net
+// change over the window.
+func (w *Watcher) checkSupply(ctx context.Context) {
+	w.mu.Lock()
+	rules, book := w.supply, w.book
+	w.mu.Unlock()
+	if rules == nil {
+		return
+	}
+	logger, s := w.cfg.Logger, &w.supplyState
+	checkCtx, cancel := context.WithTimeout(ctx, w.cfg.PollTimeout)
+	defer cancel()
+	head, endpoint, err := w.cfg.Client.BlockNumber(checkCtx)
+	if err != nil {
+		logger.Warn("supply check failed", "endpoint", endpoint.Name, "error", err)
+		return
+	}
+
+	if rules.MintThreshold != nil || rules.BurnThreshold != nil {
+		if from := s.block + 1; s.block > 0 && from <= head {
+			if head-from >= maxTransferLookback {
+				from = head - maxTransferLookback + 1
+			}
+			changes, err := fetchSupplyChanges(checkCtx, w.cfg.Client, from, head)
+			if err != nil {
+				logger.Warn("supply change lookup failed", "from_block", from, "to_block", head, "error", err)
+			} else {
+				s.block = head
+			}
+			for _, change := range changes {
+				rule, threshold := alert.RuleBurn, rules.BurnThreshold
+				if change.Mint {
+					rule, threshold = alert.RuleMint, rules.MintThreshold
+				}
+				if threshold == nil || change.Amount.Cmp(threshold) < 0 {
+					continue
+				}
+				w.raiseChangeAlert(ctx, alert.Data{
+					Rule:      rule,
+					Address:   change.Account,
+					Label:     book.Label(change.Account),
+					Chain:     w.cfg.Chain,
+					Amount:    change.Amount,
+					Threshold: threshold,
+					Block:     change.Block,
+					TxHash:    change.TxHash,
+					Time:      w.clock.Now(),
+				}, "event")
+			}
+		} else if s.block == 0 {
+			s.block = head
+		}
+	}
+
+	result, endpointName, err := callUSDC(checkCtx, w.cfg.Client, usdc.EncodeTotalSupplyCall(), head)
+	var total *big.Int
+	if err == nil {
+		total, err = usdc.DecodeAmount(result)
+	}
+	if err != nil {
+		logger.Warn("total supply read failed", "endpoint", endpointName, "error", err)
+		return
+	}
+	w.cfg.Metrics.observeSupply(total)
+	logger.Debug("total supply read", "supply", usdc.FormatAmount(total), "block", head, "endpoint", endpointName)
+	if rules.NetChange == nil || rules.Window <= 0 {
+		return
+	}
+
+	now := w.clock.Now()
+	s.samples = append(s.samples, supplySample{at: now, block: head, supply: total})
+	start := now.Add(-rules.Window)
+	baseline := 0
+	for i, sample := range s.samples {
+		if !sample.at.After(start) {
+			baseline = i
+		}
+	}
+	s.samples = s.samples[baseline:]
+	delta := new(big.Int).Sub(total, s.samples[0].supply)
+	changing := new(big.Int).Abs(delta).Cmp(rules.NetChange) >= 0
+	if changing == s.changing {
+		return
+	}
+	s.changing = changing
+	w.raiseChangeAlert(ctx, alert.Data{
+		Rule:      alert.RuleSupplyChange,
+		Chain:     w.cfg.Chain,
+		Supply:    total,
+		Delta:     delta,
+		Threshold: rules.NetChange,
+		Window:    rules.Window,
+		Block:     head,
+		Endpoint:  endpointName,
+		Time:      now,
+		Recovered: !changing,
+	}, "call")
+}
+
+// fetchSupplyChanges returns the mints and burns in [fromBlock, toBlock] from
+// Mint and Burn events and zero-address Transfers, each counted once, in
+// chain order.
+func fetchSupplyChanges(ctx context.Context, client *rpc.Client, fromBlock, toBlock uint64) ([]usdc.SupplyChange, error) {
+	zero, err := eth.AddressTopic(usdc.ZeroAddress)
+	if err != nil {
+		return nil, err
+	}
+	var changes []usdc.SupplyChange
+	for _, topics := range [][]interface{}{
+		{[]string{usdc.MintTopic, usdc.BurnTopic}},
+		{usdc.TransferTopic, zero},
+		{usdc.TransferTopic, nil, zero},
+	} {
+		logs, _, err := client.GetLogs(ctx, rpc.LogFilter{
+			FromBlock: fromBlock,
+			ToBlock:   toBlock,
+			Address:   usdc.ContractAddress,
+			Topics:    topics,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, log := range logs {
+			if log.Removed {
+				continue
+			}
+			change, err := usdc.DecodeSupplyChange(log)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, change)
+		}
+	}
+	return usdc.MergeSupplyChanges(changes), nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"usdc-watch/internal/clock"
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/rpctest"
+	"usdc-watch/internal/usdc"
+)
+
+func TestWatcherReportsSupplyChanges(t *testing.T) {
+	const (
+		minter = "0x00000000000000000000000000000000000000d1"
+		alice  = "0x00000000000000000000000000000000000000e1"
+		bob    = "0x00000000000000000000000000000000000000e2"
+		carol  = "0x00000000000000000000000000000000000000e3"
+	)
+	node := rpctest.NewNode(t)
+	node.SetHead(100)
+	node.SetTotalSupply(big.NewInt(1_000_000_000_000))
+
+	messages := make(chan string, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		messages <- r.URL.Query().Get("message")
+	}))
+	defer webhook.Close()
+
+	client, err := rpc.NewClient([]config.Endpoint{node.Endpoint("node")}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	watcher, err := NewWatcher(WatcherConfig{
+		Logger:   discardLogger(),
+		Client:   client,
+		Watches:  []Watch{{Address: watchedAddress, Threshold: big.NewInt(1)}},
+		Interval: time.Minute,
+		AlertURL: webhook.URL,
+		Supply: &SupplyWatch{
+			MintThreshold: big.NewInt(1_000_000_000),
+			BurnThreshold: big.NewInt(1_000_000_000),
+			NetChange:     big.NewInt(2_000_000_000),
+			Window:        time.Hour,
+		},
+		Clock: fake,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher error: %v", err)
+	}
+	drain := func() []string {
+		var got []string
+		for {
+			select {
+			case msg := <-messages:
+				got = append(got, msg)
+			default:
+				return got
+			}
+		}
+	}
+	ctx := context.Background()
+
+	watcher.checkSupply(ctx)
+	if got := drain(); len(got) != 0 {
+		t.Fatalf("unexpected alerts on the first check: %q", got)
+	}
+
+	// Mint and Burn events come with zero-address Transfers that must not be
+	// counted twice; a bare Transfer from the zero address still counts.
+	node.Mint(101, minter, alice, big.NewInt(2_000_000_000), "0xf1")
+	node.Mint(101, minter, bob, big.NewInt(500_000_000), "0xf2")
+	node.Burn(102, minter, big.NewInt(1_500_000_000), "0xf3")
+	node.AddTransfer(102, usdc.ZeroAddress, carol, big.NewInt(3_000_000_000), "0xf4")
+	node.SetHead(103)
+	fake.Advance(10 * time.Minute)
+	watcher.checkSupply(ctx)
+	want := []string{
+		"USDC mint of 2000.000000 to " + alice + " at block 101",
+		"USDC burn of 1500.000000 by " + minter + " at block 102",
+		"USDC mint of 3000.000000 to " + carol + " at block 102",
+	}
+	if got := drain(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("mint and burn alerts = %q, want %q", got, want)
+	}
+
+	node.Mint(104, minter, alice, big.NewInt(1_500_000_000), "0xf5")
+	node.SetHead(104)
+	fake.Advance(10 * time.Minute)
+	watcher.checkSupply(ctx)
+	want = []string{
+		"USDC mint of 1500.000000 to " + alice + " at block 104",
+		"USDC supply changed by 2500.000000 over 1h0m0s: total 1002500.000000",
+	}
+	if got := drain(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("net change alerts = %q, want %q", got, want)
+	}
+
+	node.SetHead(105)
+	fake.Advance(time.Hour)
+	watcher.checkSupply(ctx)
+	if got, want := drain(), []string{"USDC supply change back under 2000.000000 over 1h0m0s: total 1002500.000000"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("recovery alerts = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"usdc-watch/internal/alert"
+	"usdc-watch/internal/cursor"
+	"usdc-watch/internal/eth"
+	"usdc-watch/internal/history"
+	"usdc-watch/internal/metrics"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/state"
+	"usdc-watch/internal/usdc"
+)
+
+// runWatch implements the watch subcommand: poll a balance until stopped or alerted.
+func runWatch(args []string) int {
+	fs := newFlagSet("watch", "watch [--address ADDR --threshold AMOUNT] [flags]",
+		"Poll USDC balances and alert once one reaches its threshold. Addresses come from\n"+
+			"--address and from [[watch]] blocks in the configuration file, which is reloaded\n"+
+			"on SIGHUP.")
+	cfgPath := fs.String("config", "config/rpc_endpoints.toml", "Path to RPC endpoints configuration")
+	configPollFlag := fs.Duration("config-poll", 0, "Reload the configuration when its modification time changes, checked at this interval (0 disables)")
+	addressFlag := fs.String("address", "", "Ethereum wallet address to monitor (hex), in addition to the configuration file")
+	thresholdFlag := fs.String("threshold", "", "Alert threshold in USDC for --address (supports up to 6 decimals)")
+	intervalFlag := fs.Duration("interval", time.Minute, "Polling interval (e.g. 30s, 1m)")
+	minIntervalFlag := fs.Duration("min-interval", 0, "Fastest polling interval as the balance nears the threshold (0 disables)")
+	approachFlag := fs.Float64("approach", 0.2, "Distance from the threshold, as a fraction of it, within which polling speeds up")
+	maxBackoffFlag := fs.Duration("max-backoff", 10*time.Minute, "Cap for exponential backoff after consecutive poll failures (0 disables)")
+	jitterFlag := fs.Float64("jitter", 0, "Randomise each polling delay by up to this fraction (0 disables)")
+	blockTimeFlag := fs.Duration("block-time", 0, "Align polls to just after expected blocks, counted from the head block's timestamp (0 disables)")
+	staleAfterFlag := fs.Duration("stale-after", 0, "Meta alert when no poll has succeeded for this long (0 disables)")
+	headStallFlag := fs.Duration("head-stall-after", 0, "Meta alert when the chain head has not advanced for this long (0 disables)")
+	endpointsDownFlag := fs.Bool("endpoints-down-alert", false, "Meta alert while every RPC endpoint is failing")
+	disagreeAfterFlag := fs.Duration("disagreement-after", 0, "Meta alert when RPC endpoints have disagreed on the head or a watched balance for this long (0 disables)")
+	headToleranceFlag := fs.Uint64("disagreement-head-tolerance", 3, "Blocks by which endpoint heads may differ before they count as disagreeing")
+	blacklistFlag := fs.Bool("watch-blacklist", false, "Alert when a watched address is added to or removed from the USDC blacklist")
+	pausedFlag := fs.Bool("watch-paused", false, "Alert when the USDC contract is paused or unpaused")
+	enrichFlag := fs.Bool("enrich-alerts", false, "Look up the transactions behind alerts to name their sender, called contract, gas used and status")
+	pendingFlag := fs.String("watch-pending", "", "Notify of USDC transfers of watched addresses before they are mined, reading pending transactions from a pending-transaction \"filter\" or a local node's \"txpool\" (empty disables)")
+	pendingIntervalFlag := fs.Duration("pending-interval", 2*time.Second, "How often to check for pending transfers with --watch-pending")
+	reorgsFlag := fs.Bool("watch-reorgs", false, "Follow head block hashes and, after a chain reorganisation, roll back observations and retract alerts of orphaned blocks")
+	approvalsFlag := fs.Bool("watch-approvals", false, "Alert when a watched address approves a spender without an [[allowance]] entry")
+	heartbeatURLFlag := fs.String("heartbeat-url", "", "Optional URL pinged after each poll cycle (URL/fail after failed cycles)")
+	heartbeatIntervalFlag := fs.Duration("heartbeat-interval", time.Minute, "Minimum time between heartbeat pings with the same outcome (0 pings every cycle)")
+	heartbeatPayloadFlag := fs.String("heartbeat-payload", "", "Optional body to POST with each heartbeat instead of a GET")
+	pollTimeoutFlag := fs.Duration("poll-timeout", defaultPollTimeout, "Timeout for a single balance poll across all endpoints")
+	alertTimeoutFlag := fs.Duration("alert-timeout", defaultAlertTimeout, "Timeout for delivering an alert webhook")
+	onceFlag := fs.Bool("once", false, "Run a single balance check and exit")
+	exitAfterAlertFlag := fs.Bool("alert-exit", true, "Exit after the first balance >= threshold alert")
+	chainFlag := fs.String("chain", "ethereum", "Chain name available to alert message templates")
+	explorerFlag := fs.String("explorer-url", alert.DefaultExplorerURL, "Block explorer base URL for links in alert message templates")
+	alertURLFlag := fs.String("alert-url", "", "Optional alert webhook base URL (expects GET with message query param)")
+	metricsAddrFlag := fs.String("metrics-addr", "", "Optional listen address for Prometheus metrics (e.g. :9102)")
+	apiAddrFlag := fs.String("api-addr", "", "Optional listen address for the JSON status API (e.g. :8080)")
+	historyDirFlag := fs.String("history-dir", "", "Optional directory for the persistent balance history")
+	historyRetentionFlag := fs.Duration("history-retention", 30*24*time.Hour, "How long to keep balance history segments (0 keeps forever)")
+	whaleCursorFlag := fs.String("whale-cursor", "", "Optional file persisting the last block scanned for [[whales]] alerts, resumed from after a restart")
+	headIntervalFlag := fs.Duration("head-interval", 0, "How often to poll every endpoint's head block for lag detection (0 disables)")
+	maxLagFlag := fs.Uint64("max-head-lag", 0, "Exclude endpoints trailing the highest head by more than this many blocks, with --head-interval (0 disables)")
+	recordFlag := fs.String("record-rpc", "", "Optional JSONL cassette to append every RPC request and response to, with endpoint, latency and timestamp")
+	replayFlag := fs.String("replay-rpc", "", "Serve RPC requests from a cassette written by --record-rpc instead of the network, to reproduce an incident offline")
+	cacheSizeFlag := fs.Int("rpc-cache-size", 0, "Cache up to this many block-pinned RPC responses (0 disables)")
+	logOpts := addLogFlags(fs)
+	if ok, code := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	logger, err := logOpts.logger(os.Stdout)
+	if err != nil {
+		return usageError(fs, "configure logging: %v", err)
+	}
+
+	if (*addressFlag == "") != (*thresholdFlag == "") {
+		return usageError(fs, "--address and --threshold must be given together")
+	}
+	if *intervalFlag <= 0 {
+		return usageError(fs, "--interval must be positive")
+	}
+	if *jitterFlag < 0 || *jitterFlag >= 1 {
+		return usageError(fs, "--jitter must be in [0, 1)")
+	}
+	if *approachFlag < 0 || *approachFlag > 1 {
+		return usageError(fs, "--approach must be in [0, 1]")
+	}
+	if *pendingFlag != "" && *pendingFlag != pendingFilter && *pendingFlag != pendingTxPool {
+		return usageError(fs, "--watch-pending must be %q or %q", pendingFilter, pendingTxPool)
+	}
+	if *pendingIntervalFlag <= 0 {
+		return usageError(fs, "--pending-interval must be positive")
+	}
+	if *recordFlag != "" && *replayFlag != "" {
+		return usageError(fs, "--record-rpc and --replay-rpc cannot be combined")
+	}
+
+	var flagWatches []Watch
+	if *addressFlag != "" {
+		normalizedAddress, err := eth.NormalizeAddress(*addressFlag)
+		if err != nil {
+			return usageError(fs, "invalid address: %v", err)
+		}
+		thresholdAmount, err := usdc.ParseAmount(*thresholdFlag)
+		if err != nil {
+			return usageError(fs, "invalid threshold: %v", err)
+		}
+		flagWatches = append(flagWatches, Watch{Address: normalizedAddress, Threshold: thresholdAmount})
+	}
+
+	cfg, err := loadConfig(*cfgPath, *explorerFlag)
+	if err != nil {
+		logger.Error("load configuration", "path", *cfgPath, "error", err)
+		return exitFailure
+	}
+	watches := mergeWatches(flagWatches, cfg)
+	if len(watches) == 0 {
+		return usageError(fs, "no addresses to watch: pass --address and --threshold or add [[watch]] blocks to %s", *cfgPath)
+	}
+	endpoints := cfg.Endpoints
+
+	var httpClient *http.Client
+	if *replayFlag != "" {
+		replayer, err := loadReplayer(*replayFlag)
+		if err != nil {
+			logger.Error("load rpc cassette", "error", err)
+			return exitFailure
+		}
+		httpClient = &http.Client{Transport: replayer}
+	}
+	rpcClient, err := rpc.NewClient(endpoints, httpClient)
+	if err != nil {
+		logger.Error("build rpc client", "error", err)
+		return exitFailure
+	}
+	var recorder *rpc.Recorder
+	if *recordFlag != "" {
+		cassette, err := os.OpenFile(*recordFlag, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			logger.Error("open rpc cassette", "error", err)
+			return exitFailure
+		}
+		defer cassette.Close()
+		recorder = rpc.NewRecorder(cassette)
+		rpcClient.SetRecorder(recorder)
+	}
+
+	pollInterval := *intervalFlag
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	store := state.NewStore(state.DefaultHistorySize)
+	checks := make(chan struct{}, 1)
+
+	var series *history.Store
+	if *historyDirFlag != "" {
+		series, err = history.Open(*historyDirFlag, *historyRetentionFlag)
+		if err != nil {
+			logger.Error("open balance history", "error", err)
+			return exitFailure
+		}
+		defer series.Close()
+		go maintainHistory(ctx, logger, series, time.Hour)
+	}
+
+	var whaleCursor *cursor.Cursor
+	if *whaleCursorFlag != "" {
+		whaleCursor, err = cursor.Open(*whaleCursorFlag)
+		if err != nil {
+			logger.Error("open whale cursor", "error", err)
+			return exitFailure
+		}
+	}
+
+	// Metrics and API share one listener when configured with the same address.
+	muxes := make(map[string]*http.ServeMux)
+	muxFor := func(addr string) *http.ServeMux {
+		if mux, ok := muxes[addr]; ok {
+			return mux
+		}
+		mux := http.NewServeMux()
+		muxes[addr] = mux
+		return mux
+	}
+
+	observers := requestObservers{requestLogger{logger: logger}}
+	var stats *watchMetrics
+	if *metricsAddrFlag != "" {
+		reg := metrics.NewRegistry()
+		stats = newWatchMetrics(reg)
+		observers = append(observers, stats)
+		muxFor(*metricsAddrFlag).Handle("GET /metrics", reg.Handler())
+	}
+	rpcClient.SetObserver(observers)
+	rpcClient.SetMaxLag(*maxLagFlag)
+	rpcClient.EnableCache(*cacheSizeFlag)
+	if *headIntervalFlag > 0 {
+		go rpcClient.MonitorHeads(ctx, *headIntervalFlag)
+	}
+	if *apiAddrFlag != "" {
+		registerAPI(muxFor(*apiAddrFlag), store, series, rpcClient, checks)
+	}
+	for addr, mux := range muxes {
+		if err := serveHTTP(ctx, logger, addr, mux); err != nil {
+			logger.Error("start http server", "error", err)
+			return exitFailure
+		}
+	}
+
+	for _, watch := range watches {
+		logger.Info("monitoring started",
+			addressAttr(watch.Address),
+			labelAttr(watch.Label),
+			"threshold", usdc.FormatAmount(watch.Threshold),
+			"interval", pollInterval.String(),
+		)
+	}
+
+	watcher, err := NewWatcher(WatcherConfig{
+		Logger:         logger,
+		Client:         rpcClient,
+		Watches:        watches,
+		Interval:       pollInterval,
+		Once:           *onceFlag,
+		ExitAfterAlert: *exitAfterAlertFlag,
+		AlertURL:       *alertURLFlag,
+		Templates:      cfg.Templates,
+		Book:           cfg.Book,
+		Chain:          *chainFlag,
+		Schedule: Schedule{
+			MinInterval: *minIntervalFlag,
+			Approach:    *approachFlag,
+			MaxBackoff:  *maxBackoffFlag,
+			Jitter:      *jitterFlag,
+			BlockTime:   *blockTimeFlag,
+		},
+		Heartbeat: HeartbeatConfig{
+			URL:      *heartbeatURLFlag,
+			Interval: *heartbeatIntervalFlag,
+			Payload:  *heartbeatPayloadFlag,
+		},
+		Meta: MetaConfig{
+			StaleAfter:     *staleAfterFlag,
+			HeadStallAfter: *headStallFlag,
+			EndpointsDown:  *endpointsDownFlag,
+			DisagreeAfter:  *disagreeAfterFlag,
+			HeadTolerance:  *headToleranceFlag,
+		},
+		Status: StatusConfig{
+			Blacklist: *blacklistFlag,
+			Paused:    *pausedFlag,
+		},
+		Allowances:   cfg.Allowances,
+		Approvals:    *approvalsFlag,
+		Supply:       cfg.Supply,
+		Whales:       cfg.Whales,
+		WhaleCursor:  whaleCursor,
+		Reorgs:       *reorgsFlag,
+		Enrich:       *enrichFlag,
+		Pending:      *pendingFlag,
+		PollTimeout:  *pollTimeoutFlag,
+		AlertTimeout: *alertTimeoutFlag,
+		Metrics:      stats,
+		Store:        store,
+		Series:       series,
+		Checks:       checks,
+	})
+	if err != nil {
+		logger.Error("build watcher", "error", err)
+		return exitFailure
+	}
+	if *pendingFlag != "" {
+		go watcher.WatchPending(ctx, *pendingIntervalFlag)
+	}
+	go newReloader(*cfgPath, *explorerFlag, flagWatches, logger, rpcClient, watcher).Run(ctx, *configPollFlag)
+	err = watcher.Run(ctx)
+	if recorder != nil && recorder.Err() != nil {
+		logger.Error("record rpc traffic", "error", recorder.Err())
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		logger.Error("watcher stopped", "error", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// loadReplayer reads a cassette recorded with --record-rpc.
+func loadReplayer(path string) (*rpc.Replayer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	interactions, err := rpc.ReadCassette(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rpc.NewReplayer(interactions)
+}
+
+// maintainHistory compacts and prunes the history store at startup and then every interval.
+func maintainHistory(ctx context.Context, logger *slog.Logger, series *history.Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := series.Maintain(time.Now()); err != nil {
+			logger.Error("balance history maintenance failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"usdc-watch/internal/alert"
+	"usdc-watch/internal/clock"
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/history"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/rpctest"
+	"usdc-watch/internal/state"
+)
+
+const watchedAddress = "0x00000000000000000000000000000000000000a1"
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestWatcherAlertsEndToEnd(t *testing.T) {
+	node := rpctest.NewNode(t)
+	node.SetHead(100)
+	node.SetBalance(watchedAddress, 0, big.NewInt(500_000))
+	node.SetBalance(watchedAddress, 102, big.NewInt(2_500_000))
+	node.AddTransfer(102, "0x00000000000000000000000000000000000000b0", watchedAddress, big.NewInt(2_000_000), "0xfeed")
+
+	messages := make(chan string, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		messages <- r.URL.Query().Get("message")
+	}))
+	defer webhook.Close()
+
+	client, err := rpc.NewClient([]config.Endpoint{node.Endpoint("node")}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	series, err := history.Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("history.Open error: %v", err)
+	}
+	defer series.Close()
+	store := state.NewStore(10)
+	fake := clock.NewFake(time.Now())
+	watcher, err := NewWatcher(WatcherConfig{
+		Logger:         discardLogger(),
+		Client:         client,
+		Watches:        []Watch{{Address: watchedAddress, Threshold: big.NewInt(1_000_000)}},
+		Interval:       time.Minute,
+		ExitAfterAlert: true,
+		AlertURL:       webhook.URL,
+		Clock:          fake,
+		Store:          store,
+		Series:         series,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher error: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- watcher.Run(context.Background()) }()
+
+	// Mine past the incoming transfer while the watcher waits for its next poll.
+	fake.BlockUntil(1)
+	node.SetHead(103)
+	fake.Advance(time.Minute)
+	if err := <-done; err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+
+	select {
+	case msg := <-messages:
+		if msg != "USDC balance 2.500000 >= threshold 1.000000" {
+			t.Fatalf("unexpected alert message %q", msg)
+		}
+	default:
+		t.Fatalf("expected the webhook to be notified")
+	}
+
+	from, to := fake.Now().Add(-time.Hour), fake.Now().Add(time.Hour)
+	points, err := series.Query(watchedAddress, from, to)
+	if err != nil || len(points) < 2 || points[len(points)-1].Block != 103 {
+		t.Fatalf("unexpected balance history %+v, %v", points, err)
+	}
+	transfers, err := series.Transfers(watchedAddress, from, to)
+	if err != nil || len(transfers) != 1 || transfers[0].TxHash != "0xfeed" || !transfers[0].Incoming() {
+		t.Fatalf("unexpected transfers %+v, %v", transfers, err)
+	}
+	alerts, err := series.Alerts(watchedAddress, from, to)
+	if err != nil || len(alerts) != 1 {
+		t.Fatalf("unexpected alerts %+v, %v", alerts, err)
+	}
+}
+
+func TestWatcherRecordsFailures(t *testing.T) {
+	node := rpctest.NewNode(t)
+	node.InjectFault(rpctest.Fault{RPCError: &rpctest.RPCError{Code: -32000, Message: "node is syncing"}})
+	client, err := rpc.NewClient([]config.Endpoint{node.Endpoint("node")}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	store := state.NewStore(10)
+	watcher, err := NewWatcher(WatcherConfig{
+		Logger:   discardLogger(),
+		Client:   client,
+		Watches:  []Watch{{Address: watchedAddress, Threshold: big.NewInt(1)}},
+		Interval: time.Minute,
+		Once:     true,
+		Store:    store,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher error: %v", err)
+	}
+	if err := watcher.Run(context.Background()); err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+
+	states := store.Addresses()
+	if len(states) != 1 || states[0].LastError == "" || states[0].Balance != nil {
+		t.Fatalf("expected a recorded failure, got %+v", states)
+	}
+}
+
+func TestWatcherSimulatesHoursOfPolling(t *testing.T) {
+	node := rpctest.NewNode(t)
+	node.SetHead(100)
+	node.SetBalance(watchedAddress, 0, big.NewInt(500_000))
+	client, err := rpc.NewClient([]config.Endpoint{node.Endpoint("node")}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	watcher, err := NewWatcher(WatcherConfig{
+		Logger:   discardLogger(),
+		Client:   client,
+		Watches:  []Watch{{Address: watchedAddress, Threshold: big.NewInt(1_000_000)}},
+		Interval: time.Minute,
+		Clock:    fake,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- watcher.Run(ctx) }()
+
+	const polls = 6 * 60
+	for i := 1; i < polls; i++ {
+		fake.BlockUntil(1)
+		if got, ok := fake.NextDeadline(); !ok || got != time.Minute {
+			t.Fatalf("poll %d: next deadline %v, %v; want 1m", i, got, ok)
+		}
+		fake.Advance(time.Minute)
+	}
+	fake.BlockUntil(1)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("Run error = %v, want context.Canceled", err)
+	}
+	if got := node.Requests("eth_call"); got != polls {
+		t.Fatalf("eth_call requests = %d, want %d", got, polls)
+	}
+}
+
+func TestWatcherRendersTemplates(t *testing.T) {
+	node := rpctest.NewNode(t)
+	node.SetHead(100)
+	node.SetBalance(watchedAddress, 0, big.NewInt(500_000))
+	node.SetBalance(watchedAddress, 101, big.NewInt(2_500_000))
+	node.AddTransfer(101, "0x00000000000000000000000000000000000000b0", watchedAddress, big.NewInt(2_000_000), "0xfeed")
+
+	messages := make(chan string, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		messages <- r.URL.Query().Get("message")
+	}))
+	defer webhook.Close()
+
+	client, err := rpc.NewClient([]config.Endpoint{node.Endpoint("node")}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	series, err := history.Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("history.Open error: %v", err)
+	}
+	defer series.Close()
+	templates, err := alert.New([]alert.Definition{{
+		Rule:     alert.RuleThreshold,
+		Notifier: notifierWebhook,
+		Text:     `{{.Chain}} {{abbrev .Address}} {{short .PreviousBalance}} -> {{short .Balance}} ({{short .Delta}}){{range .Transfers}} {{txURL .TxHash}}{{end}}`,
+	}}, alert.Options{ExplorerURL: "https://explorer.example"})
+	if err != nil {
+		t.Fatalf("alert.New error: %v", err)
+	}
+	fake := clock.NewFake(time.Now())
+	watcher, err := NewWatcher(WatcherConfig{
+		Logger:         discardLogger(),
+		Client:         client,
+		Watches:        []Watch{{Address: watchedAddress, Threshold: big.NewInt(1_000_000)}},
+		Interval:       time.Minute,
+		ExitAfterAlert: true,
+		AlertURL:       webhook.URL,
+		Templates:      templates,
+		Chain:          "ethereum",
+		Clock:          fake,
+		Series:         series,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher error: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- watcher.Run(context.Background()) }()
+	fake.BlockUntil(1)
+	node.SetHead(101)
+	fake.Advance(time.Minute)
+	if err := <-done; err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+
+	want := "ethereum 0x0000…00a1 0.5 -> 2.5 (2) https://explorer.example/tx/0xfeed"
+	if msg := <-messages; msg != want {
+		t.Fatalf("webhook message %q, want %q", msg, want)
+	}
+	alerts, _ := series.Alerts(watchedAddress, fake.Now().Add(-time.Hour), fake.Now().Add(time.Hour))
+	if len(alerts) != 1 || alerts[0].Message != "USDC balance 2.500000 >= threshold 1.000000" {
+		t.Fatalf("recorded alerts should use the general template: %+v", alerts)
+	}
+}
+
+func TestWatcherLogsSkippedTransferRange(t *testing.T) {
+	node := rpctest.NewNode(t)
+	node.SetHead(100)
+	client, err := rpc.NewClient([]config.Endpoint{node.Endpoint("node")}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	series, err := history.Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("history.Open error: %v", err)
+	}
+	defer series.Close()
+	var out strings.Builder
+	logger, err := newLogger(&out, "json", "info")
+	if err != nil {
+		t.Fatalf("newLogger error: %v", err)
+	}
+	watcher, err := NewWatcher(WatcherConfig{
+		Logger:   logger,
+		Client:   client,
+		Watches:  []Watch{{Address: watchedAddress, Threshold: big.NewInt(1_000_000_000)}},
+		Interval: time.Minute,
+		Series:   series,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher error: %v", err)
+	}
+	ctx := context.Background()
+	watcher.Poll(ctx)
+	node.SetBalance(watchedAddress, 2500, big.NewInt(1_000_000))
+	node.SetHead(3000)
+	watcher.Poll(ctx)
+
+	if !strings.Contains(out.String(), `"skipped_from_block":101,"skipped_to_block":1000`) {
+		t.Fatalf("expected the skipped range 101-1000 to be logged:\n%s", out.String())
+	}
+}
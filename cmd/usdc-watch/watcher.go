@@ -0,0 +1,649 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"usdc-watch/internal/addressbook"
+	"usdc-watch/internal/alert"
+	"usdc-watch/internal/clock"
+	"usdc-watch/internal/cursor"
+	"usdc-watch/internal/eth"
+	"usdc-watch/internal/history"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/state"
+	"usdc-watch/internal/usdc"
+)
+
+const (
+	defaultPollTimeout  = 10 * time.Second
+	defaultAlertTimeout = 5 * time.Second
+)
+
+// Watch is a watched address and the rules applied to it.
+type Watch struct {
+	Address   string
+	Label     string
+	Threshold *big.Int
+}
+
+// WatcherConfig configures a Watcher. Logger, Client, Watches and Interval are
+// required; the rest are optional.
+type WatcherConfig struct {
+	Logger   *slog.Logger
+	Client   *rpc.Client
+	Watches  []Watch
+	Interval time.Duration
+
+	// Once stops after the first poll; ExitAfterAlert stops after the first alert.
+	Once           bool
+	ExitAfterAlert bool
+
+	// AlertURL is the webhook notified on alerts, sent with AlertClient
+	// (http.DefaultClient when nil).
+	AlertURL    string
+	AlertClient *http.Client
+
+	// Templates renders alert messages; the built-in defaults when nil. Chain
+	// names the network in messages.
+	Templates *alert.Templates
+	Chain     string
+
+	// Book labels transfer counterparties in logs; optional.
+	Book *addressbook.Book
+
+	// PollTimeout and AlertTimeout bound each poll and webhook delivery.
+	PollTimeout  time.Duration
+	AlertTimeout time.Duration
+
+	// Heartbeat configures dead-man's-switch pings after each poll cycle.
+	Heartbeat HeartbeatConfig
+
+	// Meta configures alerts about the watcher's own health.
+	Meta MetaConfig
+
+	// Status configures blacklist and pause monitoring of the USDC contract.
+	Status StatusConfig
+
+	// Allowances are the monitored owner/spender pairs. Approvals scans the
+	// Approval events of watched addresses and allowance owners for spenders
+	// without a pair.
+	Allowances []Allowance
+	Approvals  bool
+
+	// Supply is the aggregate watch on USDC's total supply; nil disables it.
+	Supply *SupplyWatch
+
+	// Whales is the watch on arbitrary USDC transfers; nil disables it.
+	// WhaleCursor, when set, persists its scanned block across restarts.
+	Whales      *WhaleWatch
+	WhaleCursor *cursor.Cursor
+
+	// Enrich looks up the transactions behind alerts with transfers or a
+	// transaction hash, so messages can name the sender, the contract called,
+	// gas used and status.
+	Enrich bool
+
+	// Pending names the source of pending transactions, pendingFilter or
+	// pendingTxPool, for notices of transfers of watched addresses before
+	// they are mined; empty disables them. WatchPending polls it.
+	Pending string
+
+	// Reorgs checks each cycle that the previous head is still canonical and
+	// rolls back observations and retracts alerts of orphaned blocks.
+	Reorgs bool
+
+	// Schedule adapts Interval to failures, threshold proximity and block times.
+	Schedule Schedule
+
+	// Clock drives the polling schedule; clock.Real when nil.
+	Clock clock.Clock
+
+	Metrics *watchMetrics
+	Store   *state.Store
+	Series  *history.Store
+
+	// Checks requests an immediate poll when it receives a value.
+	Checks <-chan struct{}
+}
+
+// Watcher polls the balances of its watched addresses on a schedule and
+// raises threshold alerts.
+type Watcher struct {
+	cfg   WatcherConfig
+	clock clock.Clock
+
+	// mu guards targets and allowances, which SetWatches and SetAllowances
+	// replace between poll cycles, writes to their readings, templates and book.
+	mu         sync.Mutex
+	targets    []*target
+	allowances []*allowanceTarget
+	supply     *SupplyWatch
+	whales     *WhaleWatch
+	templates  *alert.Templates
+	book       *addressbook.Book
+
+	// failures counts consecutive failed poll cycles; head is the highest
+	// block polled and headTime the timestamp of headTimeBlock, the latest
+	// head whose header was read.
+	failures      int
+	head          uint64
+	headTime      time.Time
+	headTimeBlock uint64
+
+	meta      metaState
+	beat      heartbeatState
+	status    statusState
+	approvals approvalState
+
+	supplyState supplyState
+	whaleState  whaleState
+	pending     pendingState
+
+	// chain follows the head block hashes to detect reorganisations; fired
+	// holds the recent alerts they may retract, guarded by firedMu as
+	// WatchPending raises alerts beside Run.
+	chain   *cursor.Cursor
+	firedMu sync.Mutex
+	fired   []firedAlert
+}
+
+// target is the polling state of one watched address.
+type target struct {
+	Watch
+	callData string
+	previous *balanceReading
+	// blacklisted is the last known blacklist status; nil until checked.
+	blacklisted *bool
+}
+
+// NewWatcher validates cfg and fills in defaults.
+func NewWatcher(cfg WatcherConfig) (*Watcher, error) {
+	if cfg.Logger == nil || cfg.Client == nil {
+		return nil, errors.New("watcher requires a logger and an rpc client")
+	}
+	if cfg.Interval <= 0 {
+		return nil, errors.New("watcher interval must be positive")
+	}
+	if cfg.PollTimeout <= 0 {
+		cfg.PollTimeout = defaultPollTimeout
+	}
+	if cfg.AlertTimeout <= 0 {
+		cfg.AlertTimeout = defaultAlertTimeout
+	}
+	switch cfg.Pending {
+	case "", pendingFilter, pendingTxPool:
+	default:
+		return nil, fmt.Errorf("unknown pending transaction source %q", cfg.Pending)
+	}
+	if cfg.AlertClient == nil {
+		cfg.AlertClient = http.DefaultClient
+	}
+	if cfg.Store == nil {
+		cfg.Store = state.NewStore(state.DefaultHistorySize)
+	}
+	w := &Watcher{cfg: cfg, clock: cfg.Clock, templates: cfg.Templates, book: cfg.Book, supply: cfg.Supply, whales: cfg.Whales}
+	if w.clock == nil {
+		w.clock = clock.Real
+	}
+	if w.templates == nil {
+		w.templates = defaultTemplates
+	}
+	if err := w.SetWatches(cfg.Watches); err != nil {
+		return nil, err
+	}
+	if err := w.SetAllowances(cfg.Allowances); err != nil {
+		return nil, err
+	}
+	w.meta = metaState{started: w.clock.Now(), active: make(map[string]bool)}
+	w.pending.tracked = make(map[pendingKey]*pendingTransfer)
+	w.chain, w.whaleState.cursor = cursor.New(), cfg.WhaleCursor
+	if w.whaleState.cursor == nil {
+		w.whaleState.cursor = cursor.New()
+	}
+	return w, nil
+}
+
+// SetWatches replaces the watched addresses and their rules. A poll cycle in
+// progress finishes with the previous set; the next cycle uses the new one.
+// Addresses that remain watched keep their last reading.
+func (w *Watcher) SetWatches(watches []Watch) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.setWatchesLocked(watches)
+}
+
+// Reconfigure atomically replaces the watches, allowance pairs, supply and
+// whale watches, message templates and address book, as SetWatches does for watches alone.
+func (w *Watcher) Reconfigure(watches []Watch, allowances []Allowance, supply *SupplyWatch, whales *WhaleWatch, templates *alert.Templates, book *addressbook.Book) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	pairs, err := w.allowanceTargetsLocked(allowances)
+	if err != nil {
+		return err
+	}
+	targets, err := w.watchTargetsLocked(watches)
+	if err != nil {
+		return err
+	}
+	w.allowances = pairs
+	w.applyTargetsLocked(targets)
+	w.supply, w.whales, w.templates, w.book = supply, whales, templates, book
+	return nil
+}
+
+// CheckConfig returns the error Reconfigure would reject watches and
+// allowances with, without applying them.
+func (w *Watcher) CheckConfig(watches []Watch, allowances []Allowance) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.allowanceTargetsLocked(allowances); err != nil {
+		return err
+	}
+	_, err := w.watchTargetsLocked(watches)
+	return err
+}
+
+func (w *Watcher) setWatchesLocked(watches []Watch) error {
+	targets, err := w.watchTargetsLocked(watches)
+	if err != nil {
+		return err
+	}
+	w.applyTargetsLocked(targets)
+	return nil
+}
+
+// watchTargetsLocked validates watches and builds their targets, carrying
+// over the readings of addresses that remain watched.
+func (w *Watcher) watchTargetsLocked(watches []Watch) ([]*target, error) {
+	if len(watches) == 0 {
+		return nil, errors.New("watcher requires at least one address")
+	}
+	current := make(map[string]*target, len(w.targets))
+	for _, t := range w.targets {
+		current[t.Address] = t
+	}
+	targets := make([]*target, 0, len(watches))
+	seen := make(map[string]bool, len(watches))
+	for _, watch := range watches {
+		if watch.Threshold == nil {
+			return nil, fmt.Errorf("watch %s: missing threshold", watch.Address)
+		}
+		if seen[watch.Address] {
+			return nil, fmt.Errorf("watch %s: duplicate address", watch.Address)
+		}
+		seen[watch.Address] = true
+		callData, err := usdc.EncodeBalanceOfCall(watch.Address)
+		if err != nil {
+			return nil, fmt.Errorf("watch %s: encode call data: %w", watch.Address, err)
+		}
+		t := &target{Watch: watch, callData: callData}
+		if old, ok := current[watch.Address]; ok {
+			t.previous, t.blacklisted = old.previous, old.blacklisted
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// applyTargetsLocked makes targets the watched set, tracking its addresses in
+// the store in place of those no longer watched.
+func (w *Watcher) applyTargetsLocked(targets []*target) {
+	watched := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		watched[t.Address] = true
+	}
+	for _, t := range w.targets {
+		if !watched[t.Address] {
+			w.cfg.Store.Untrack(t.Address)
+		}
+	}
+	for _, t := range targets {
+		w.cfg.Store.Track(t.Address)
+	}
+	w.targets = targets
+}
+
+// snapshot returns the targets for one poll cycle.
+func (w *Watcher) snapshot() []*target {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]*target(nil), w.targets...)
+}
+
+// Run polls until ctx is cancelled, returning ctx.Err(), or until the watcher
+// stops itself after a single poll or an alert, returning nil.
+func (w *Watcher) Run(ctx context.Context) error {
+	logger := w.cfg.Logger
+	for iteration := 0; ; iteration++ {
+		if iteration > 0 {
+			select {
+			case <-ctx.Done():
+				logger.Info("stopping watcher", "reason", ctx.Err())
+				return ctx.Err()
+			case <-w.clock.After(w.nextDelay()):
+			case <-w.cfg.Checks:
+				logger.Info("manual balance check requested")
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			logger.Info("stopping watcher", "reason", err)
+			return err
+		}
+
+		w.checkReorg(ctx)
+		alerted := w.Poll(ctx)
+		w.checkStatus(ctx)
+		w.checkAllowances(ctx)
+		w.checkSupply(ctx)
+		w.checkWhales(ctx)
+		w.checkMeta(ctx)
+		w.heartbeat(ctx, w.failures > 0)
+		if alerted && w.cfg.ExitAfterAlert {
+			return nil
+		}
+		if w.cfg.Once {
+			return nil
+		}
+	}
+}
+
+// nextDelay returns how long to wait before the next scheduled poll. Near a
+// threshold the most urgent address sets the pace.
+func (w *Watcher) nextDelay() time.Duration {
+	st := scheduleState{
+		now:      w.clock.Now(),
+		failures: w.failures,
+		headTime: w.headTime,
+	}
+	delay := time.Duration(-1)
+	for _, t := range w.snapshot() {
+		st.threshold, st.balance = t.Threshold, nil
+		if t.previous != nil {
+			st.balance = t.previous.Balance
+		}
+		if d := w.cfg.Schedule.next(w.cfg.Interval, st); delay < 0 || d < delay {
+			delay = d
+		}
+	}
+	w.cfg.Logger.Debug("next poll scheduled", "delay_ms", delay.Milliseconds(), "failures", w.failures)
+	return delay
+}
+
+// Poll checks every watched address once and reports whether an alert fired.
+// The cycle counts as failed when no address could be read.
+func (w *Watcher) Poll(ctx context.Context) bool {
+	alerted, succeeded := false, false
+	for _, t := range w.snapshot() {
+		fired, err := w.pollTarget(ctx, t)
+		alerted = alerted || fired
+		succeeded = succeeded || err == nil
+	}
+	if !succeeded {
+		w.failures++
+		return alerted
+	}
+	w.failures = 0
+	w.meta.lastSuccess = w.clock.Now()
+	w.refreshHeadTime(ctx)
+	return alerted
+}
+
+// pollTarget reads one address, records the observation and raises its alert.
+func (w *Watcher) pollTarget(ctx context.Context, t *target) (bool, error) {
+	address := t.Address
+	logger := w.cfg.Logger.With(addressAttr(address), labelAttr(t.Label))
+
+	pollCtx, cancel := context.WithTimeout(ctx, w.cfg.PollTimeout)
+	pollStarted := w.clock.Now()
+	reading, err := fetchBalance(pollCtx, w.cfg.Client, t.callData)
+	cancel()
+	pollEnded := w.clock.Now()
+	pollLatency := pollEnded.Sub(pollStarted)
+	w.cfg.Metrics.observePoll(address, pollEnded, pollLatency, reading.Balance, err)
+	if err != nil {
+		logger.Warn("balance poll failed", latencyAttr(pollLatency), "error", err)
+		w.cfg.Store.RecordError(address, w.clock.Now(), err)
+		return false, err
+	}
+
+	balance := reading.Balance
+	observedAt := w.clock.Now()
+	var transfers []history.Transfer
+	w.cfg.Store.Record(address, state.Observation{Time: observedAt, Block: reading.Block, Balance: balance, Endpoint: reading.Endpoint})
+	if series := w.cfg.Series; series != nil {
+		point := history.Point{Time: observedAt, Address: address, Block: reading.Block, Balance: balance, Endpoint: reading.Endpoint}
+		if err := series.Append(point); err != nil {
+			logger.Error("append balance history failed", "error", err)
+		}
+	}
+	if previous := t.previous; previous != nil && previous.Balance.Cmp(balance) != 0 && reading.Block > previous.Block {
+		transfers = w.recordTransfers(ctx, logger, address, previous.Block+1, reading.Block, observedAt)
+	}
+	if reading.Block > w.head {
+		w.head = reading.Block
+	}
+	previous := t.previous
+	w.mu.Lock()
+	t.previous = &reading
+	w.mu.Unlock()
+	logger.Info("balance polled",
+		slog.Group("", balanceAttrs(balance)...),
+		"endpoint", reading.Endpoint,
+		"block", reading.Block,
+		latencyAttr(pollLatency),
+	)
+
+	if balance.Cmp(t.Threshold) < 0 {
+		return false, nil
+	}
+	data := alert.Data{
+		Rule:      alert.RuleThreshold,
+		Address:   address,
+		Label:     t.Label,
+		Chain:     w.cfg.Chain,
+		Balance:   balance,
+		Threshold: t.Threshold,
+		Block:     reading.Block,
+		Endpoint:  reading.Endpoint,
+		Time:      observedAt,
+		Transfers: transfers,
+	}
+	if previous != nil {
+		data.PreviousBalance = previous.Balance
+		data.Delta = new(big.Int).Sub(balance, previous.Balance)
+	}
+	if w.cfg.Enrich && len(transfers) > 0 {
+		hashes := make([]string, 0, len(transfers))
+		for _, t := range transfers {
+			hashes = append(hashes, t.TxHash)
+		}
+		data.Transactions = w.lookupTransactions(ctx, logger, hashes)
+	}
+	w.raiseAlert(ctx, data)
+	return true, nil
+}
+
+func (w *Watcher) raiseAlert(ctx context.Context, data alert.Data) {
+	alertLogger := w.cfg.Logger.With(
+		addressAttr(data.Address),
+		labelAttr(data.Label),
+		slog.Group("", balanceAttrs(data.Balance)...),
+		"threshold", usdc.FormatAmount(data.Threshold),
+		"endpoint", data.Endpoint,
+		"block", data.Block,
+		"rule", data.Rule,
+	)
+	message := w.render(alertLogger, "", data)
+	alertLogger.Warn("balance alert", "message", message)
+	w.recordFired(data, message)
+	w.cfg.Metrics.observeAlert(data.Address, data.Rule)
+	if series := w.cfg.Series; series != nil {
+		occurrence := history.Alert{Time: data.Time, Address: data.Address, Rule: data.Rule, Block: data.Block, Balance: data.Balance, Message: message}
+		if err := series.AppendAlert(occurrence); err != nil {
+			alertLogger.Error("append alert history failed", "error", err)
+		}
+	}
+	w.notify(ctx, alertLogger, data)
+}
+
+// notifierWebhook names the alert webhook for notifier-specific templates.
+const notifierWebhook = "webhook"
+
+// defaultTemplates renders the built-in messages.
+var defaultTemplates = func() *alert.Templates {
+	templates, err := alert.New(nil, alert.Options{})
+	if err != nil {
+		panic(err)
+	}
+	return templates
+}()
+
+// render produces the message for data and notifier, falling back to the
+// built-in template when a configured template fails.
+func (w *Watcher) render(logger *slog.Logger, notifier string, data alert.Data) string {
+	w.mu.Lock()
+	templates := w.templates
+	w.mu.Unlock()
+	message, err := templates.Render(data.Rule, notifier, data)
+	if err == nil {
+		return message
+	}
+	logger.Error("render alert template failed", "notifier", notifier, "error", err)
+	message, err = defaultTemplates.Render(data.Rule, notifier, data)
+	if err != nil {
+		return fmt.Sprintf("USDC alert %s for %s", data.Rule, data.Address)
+	}
+	return message
+}
+
+// notify delivers the alert to the webhook, if one is configured.
+func (w *Watcher) notify(ctx context.Context, alertLogger *slog.Logger, data alert.Data) {
+	if w.cfg.AlertURL == "" {
+		return
+	}
+	message := w.render(alertLogger, notifierWebhook, data)
+	alertCtx, cancel := context.WithTimeout(ctx, w.cfg.AlertTimeout)
+	defer cancel()
+	alertStarted := w.clock.Now()
+	if err := sendAlert(alertCtx, w.cfg.AlertClient, w.cfg.AlertURL, message); err != nil {
+		alertLogger.Error("alert webhook failed", latencyAttr(w.clock.Now().Sub(alertStarted)), "error", err)
+		return
+	}
+	alertLogger.Info("alert webhook notified", latencyAttr(w.clock.Now().Sub(alertStarted)))
+}
+
+// maxTransferLookback bounds the block range scanned for transfers after a balance change.
+const maxTransferLookback = 2000
+
+// recordTransfers looks up the Transfer logs touching address in [fromBlock,
+// toBlock], logs them and stores them in the history when one is kept. It
+// returns the transfers found. Only the last maxTransferLookback blocks are
+// scanned; the skipped range is logged so a backfill can fill it in.
+func (w *Watcher) recordTransfers(ctx context.Context, logger *slog.Logger, address string, fromBlock, toBlock uint64, observedAt time.Time) []history.Transfer {
+	if toBlock-fromBlock >= maxTransferLookback {
+		skippedTo := toBlock - maxTransferLookback
+		logger.Warn("transfer lookup limited to recent blocks, older transfers not recorded",
+			"skipped_from_block", fromBlock, "skipped_to_block", skippedTo)
+		fromBlock = skippedTo + 1
+	}
+	lookupCtx, cancel := context.WithTimeout(ctx, w.cfg.PollTimeout)
+	defer cancel()
+	transfers, err := fetchTransfers(lookupCtx, w.cfg.Client, address, fromBlock, toBlock)
+	if err != nil {
+		logger.Warn("transfer lookup failed", "from_block", fromBlock, "to_block", toBlock, "error", err)
+		return nil
+	}
+	w.mu.Lock()
+	book := w.book
+	w.mu.Unlock()
+	// series stops being written after a failed append, while the transfers
+	// are still returned for the alert.
+	series := w.cfg.Series
+	records := make([]history.Transfer, 0, len(transfers))
+	for _, t := range transfers {
+		record := history.Transfer{
+			Time:     observedAt,
+			Address:  address,
+			Block:    t.Block,
+			TxHash:   t.TxHash,
+			LogIndex: t.LogIndex,
+			From:     t.From,
+			To:       t.To,
+			Value:    t.Value,
+		}
+		records = append(records, record)
+		direction, counterparty := "in", t.From
+		if !record.Incoming() {
+			direction, counterparty = "out", t.To
+		}
+		attrs := []any{"direction", direction, "amount", usdc.FormatAmount(t.Value), "counterparty", counterparty}
+		if label := book.Label(counterparty); label != "" {
+			attrs = append(attrs, "counterparty_label", label)
+		}
+		logger.Info("transfer recorded", append(attrs, "tx", t.TxHash, "block", t.Block)...)
+		if series == nil {
+			continue
+		}
+		if err := series.AppendTransfer(record); err != nil {
+			logger.Error("append transfer history failed", "error", err)
+			series = nil
+		}
+	}
+	return records
+}
+
+// fetchTransfers returns USDC transfers sent or received by address in [fromBlock, toBlock],
+// ordered by block and log index.
+func fetchTransfers(ctx context.Context, client *rpc.Client, address string, fromBlock, toBlock uint64) ([]usdc.Transfer, error) {
+	topic, err := eth.AddressTopic(address)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var transfers []usdc.Transfer
+	for _, topics := range [][]interface{}{
+		{usdc.TransferTopic, topic},
+		{usdc.TransferTopic, nil, topic},
+	} {
+		logs, _, err := client.GetLogs(ctx, rpc.LogFilter{
+			FromBlock: fromBlock,
+			ToBlock:   toBlock,
+			Address:   usdc.ContractAddress,
+			Topics:    topics,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, log := range logs {
+			if log.Removed {
+				continue
+			}
+			t, err := usdc.DecodeTransfer(log)
+			if err != nil {
+				return nil, err
+			}
+			key := fmt.Sprintf("%s/%d", t.TxHash, t.LogIndex)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			transfers = append(transfers, t)
+		}
+	}
+	sort.Slice(transfers, func(i, j int) bool {
+		if transfers[i].Block != transfers[j].Block {
+			return transfers[i].Block < transfers[j].Block
+		}
+		return transfers[i].LogIndex < transfers[j].LogIndex
+	})
+	return transfers, nil
+}
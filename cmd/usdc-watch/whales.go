@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"sort"
+
+	"usdc-watch/internal/addressbook"
+	"usdc-watch/internal/alert"
+	"usdc-watch/internal/cursor"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/usdc"
+)
+
+// maxWhaleBatch bounds the blocks per eth_getLogs query of the whale scan,
+// which reads every USDC transfer rather than those of a few addresses.
+const maxWhaleBatch = 100
+
+// maxWhaleBatchesPerCheck bounds the batches one whale check scans, so a scan
+// far behind the head after downtime catches up over several poll cycles
+// instead of holding up balance polls until it reaches the head.
+const maxWhaleBatchesPerCheck = 10
+
+// WhaleWatch alerts on USDC transfers between arbitrary addresses.
+type WhaleWatch struct {
+	// MinAmount alerts on any single transfer of at least this many base
+	// units; nil disables the rule.
+	MinAmount *big.Int
+	// Watchlist holds the normalized addresses, such as exchanges or
+	// sanctioned addresses, whose every transfer alerts.
+	Watchlist map[string]bool
+}
+
+// whaleState is what the whale scan knows between poll cycles.
+type whaleState struct {
+	// cursor holds the last block whose transfers were scanned, at zero
+	// before the first check, and the hashes of recently scanned blocks.
+	cursor *cursor.Cursor
+}
+
+// SetWhales replaces the whale watch; nil disables it.
+func (w *Watcher) SetWhales(whales *WhaleWatch) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.whales = whales
+}
+
+// checkWhales scans every USDC transfer since the last scanned block, in
+// block order, and alerts on large ones and on those involving a watchlisted
+// address. The cursor advances, and is persisted when backed by a file, after
+// each batch, so a failed or interrupted scan resumes where it stopped and no
+// block is skipped. Before each batch the last scanned block is checked to
+// still be canonical; after a reorganisation the orphaned blocks are reverted
+// and rescanned. A check scans at most maxWhaleBatchesPerCheck batches and
+// leaves the rest to the following checks. Without a persisted cursor the
+// first check starts at the head.
+func (w *Watcher) checkWhales(ctx context.Context) {
+	w.mu.Lock()
+	rules, book := w.whales, w.book
+	w.mu.Unlock()
+	if rules == nil {
+		return
+	}
+	logger, c := w.cfg.Logger, w.whaleState.cursor
+	headCtx, cancel := context.WithTimeout(ctx, w.cfg.PollTimeout)
+	defer cancel()
+	head, endpoint, err := w.cfg.Client.BlockNumber(headCtx)
+	if err != nil {
+		logger.Warn("whale scan failed", "endpoint", endpoint.Name, "error", err)
+		return
+	}
+	if c.Block() == 0 {
+		header, err := w.headerAt(headCtx, head)
+		if err != nil {
+			logger.Warn("whale scan failed", "block", head, "error", err)
+			return
+		}
+		if err := c.Advance(head, header.Hash); err != nil {
+			logger.Error("persist whale cursor failed", "block", head, "error", err)
+		}
+		return
+	}
+
+	for i := 0; i < maxWhaleBatchesPerCheck && c.Block() < head && ctx.Err() == nil; i++ {
+		if !w.scanWhales(ctx, rules, book, head) {
+			return
+		}
+	}
+}
+
+// scanWhales scans the next batch of blocks up to head, reporting whether
+// the cursor could move on, possibly backwards after a reorganisation.
+func (w *Watcher) scanWhales(ctx context.Context, rules *WhaleWatch, book *addressbook.Book, head uint64) bool {
+	logger, c := w.cfg.Logger, w.whaleState.cursor
+	batchCtx, cancel := context.WithTimeout(ctx, w.cfg.PollTimeout)
+	defer cancel()
+	from := c.Block() + 1
+	to := min(from+maxWhaleBatch-1, head)
+	header, err := w.headerAt(batchCtx, to)
+	if err != nil {
+		logger.Warn("whale scan failed", "block", to, "error", err)
+		return false
+	}
+	reorg, err := c.Verify(batchCtx, header, w.headerAt)
+	if err != nil {
+		logger.Warn("whale scan failed", "block", to, "error", err)
+		return false
+	}
+	if reorg != nil {
+		w.revert(ctx, *reorg, "whales")
+		return true
+	}
+	transfers, err := fetchAllTransfers(batchCtx, w.cfg.Client, from, to)
+	if err != nil {
+		logger.Warn("whale transfer lookup failed", "from_block", from, "to_block", to, "error", err)
+		return false
+	}
+	for _, t := range transfers {
+		party := ""
+		switch {
+		case rules.Watchlist[t.From]:
+			party = t.From
+		case rules.Watchlist[t.To]:
+			party = t.To
+		case rules.MinAmount == nil || t.Value.Cmp(rules.MinAmount) < 0:
+			continue
+		}
+		w.raiseChangeAlert(ctx, alert.Data{
+			Rule:      alert.RuleWhale,
+			Address:   party,
+			Label:     book.Label(party),
+			Chain:     w.cfg.Chain,
+			Amount:    t.Value,
+			From:      t.From,
+			To:        t.To,
+			Threshold: rules.MinAmount,
+			Block:     t.Block,
+			TxHash:    t.TxHash,
+			Time:      w.clock.Now(),
+		}, "event")
+	}
+	// A failed write stops the scan so it is retried rather than resumed
+	// past an unrecorded block after a restart.
+	if err := c.Advance(to, header.Hash); err != nil {
+		logger.Error("persist whale cursor failed", "block", to, "error", err)
+		return false
+	}
+	return true
+}
+
+// fetchAllTransfers returns every USDC Transfer in [fromBlock, toBlock], in
+// chain order.
+func fetchAllTransfers(ctx context.Context, client *rpc.Client, fromBlock, toBlock uint64) ([]usdc.Transfer, error) {
+	logs, _, err := client.GetLogs(ctx, rpc.LogFilter{
+		FromBlock: fromBlock,
+		ToBlock:   toBlock,
+		Address:   usdc.ContractAddress,
+		Topics:    []interface{}{usdc.TransferTopic},
+	})
+	if err != nil {
+		return nil, err
+	}
+	transfers := make([]usdc.Transfer, 0, len(logs))
+	for _, log := range logs {
+		if log.Removed {
+			continue
+		}
+		t, err := usdc.DecodeTransfer(log)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, t)
+	}
+	sort.Slice(transfers, func(i, j int) bool {
+		if transfers[i].Block != transfers[j].Block {
+			return transfers[i].Block < transfers[j].Block
+		}
+		return transfers[i].LogIndex < transfers[j].LogIndex
+	})
+	return transfers, nil
+}
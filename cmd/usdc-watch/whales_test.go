@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/cursor"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/rpctest"
+)
+
+func TestWatcherReportsWhaleTransfers(t *testing.T) {
+	const (
+		exchange = "0x00000000000000000000000000000000000000f1"
+		alice    = "0x00000000000000000000000000000000000000e1"
+		bob      = "0x00000000000000000000000000000000000000e2"
+	)
+	node := rpctest.NewNode(t)
+	node.SetHead(100)
+
+	messages := make(chan string, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		messages <- r.URL.Query().Get("message")
+	}))
+	defer webhook.Close()
+
+	client, err := rpc.NewClient([]config.Endpoint{node.Endpoint("node")}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "whales.json")
+	newWatcher := func() *Watcher {
+		t.Helper()
+		c, err := cursor.Open(path)
+		if err != nil {
+			t.Fatalf("cursor.Open error: %v", err)
+		}
+		watcher, err := NewWatcher(WatcherConfig{
+			Logger:   discardLogger(),
+			Client:   client,
+			Watches:  []Watch{{Address: watchedAddress, Threshold: big.NewInt(1)}},
+			Interval: time.Minute,
+			AlertURL: webhook.URL,
+			Whales: &WhaleWatch{
+				MinAmount: big.NewInt(1_000_000_000_000),
+				Watchlist: map[string]bool{exchange: true},
+			},
+			WhaleCursor: c,
+		})
+		if err != nil {
+			t.Fatalf("NewWatcher error: %v", err)
+		}
+		return watcher
+	}
+	drain := func() []string {
+		var got []string
+		for {
+			select {
+			case msg := <-messages:
+				got = append(got, msg)
+			default:
+				return got
+			}
+		}
+	}
+	ctx := context.Background()
+
+	watcher := newWatcher()
+	watcher.checkWhales(ctx)
+	if got := drain(); len(got) != 0 {
+		t.Fatalf("unexpected alerts on the first check: %q", got)
+	}
+
+	node.AddTransfer(101, alice, bob, big.NewInt(2_000_000_000_000), "0xb1")
+	node.AddTransfer(101, alice, bob, big.NewInt(5_000_000), "0xb2")
+	node.AddTransfer(102, exchange, alice, big.NewInt(5_000_000), "0xb3")
+	node.SetHead(102)
+	node.InjectFault(rpctest.Fault{Methods: []string{"eth_getLogs"}, Times: 1, HTTPStatus: http.StatusBadGateway})
+	watcher.checkWhales(ctx)
+	if got := drain(); len(got) != 0 {
+		t.Fatalf("unexpected alerts after a failed lookup: %q", got)
+	}
+	watcher.checkWhales(ctx)
+	want := []string{
+		"USDC transfer of 2000000.000000 from " + alice + " to " + bob + " at block 101",
+		"USDC transfer of 5.000000 from " + exchange + " to " + alice + " at block 102",
+	}
+	if got := drain(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("whale alerts = %q, want %q", got, want)
+	}
+
+	// A restarted watcher resumes after the persisted block, across batches.
+	node.AddTransfer(150, bob, exchange, big.NewInt(7_000_000), "0xb4")
+	node.AddTransfer(260, bob, alice, big.NewInt(3_000_000_000_000), "0xb5")
+	node.SetHead(300)
+	watcher = newWatcher()
+	watcher.checkWhales(ctx)
+	want = []string{
+		"USDC transfer of 7.000000 from " + bob + " to " + exchange + " at block 150",
+		"USDC transfer of 3000000.000000 from " + bob + " to " + alice + " at block 260",
+	}
+	if got := drain(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("resumed alerts = %q, want %q", got, want)
+	}
+	if got := node.Requests("eth_getLogs"); got != 4 {
+		t.Fatalf("eth_getLogs requests = %d, want 4", got)
+	}
+}
+
+func TestWatcherWhaleScanCatchesUpAcrossChecks(t *testing.T) {
+	const alice = "0x00000000000000000000000000000000000000e1"
+	node := rpctest.NewNode(t)
+	node.SetHead(100)
+	client, err := rpc.NewClient([]config.Endpoint{node.Endpoint("node")}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	messages := make(chan string, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		messages <- r.URL.Query().Get("message")
+	}))
+	defer webhook.Close()
+	watcher, err := NewWatcher(WatcherConfig{
+		Logger:   discardLogger(),
+		Client:   client,
+		Watches:  []Watch{{Address: watchedAddress, Threshold: big.NewInt(1)}},
+		Interval: time.Minute,
+		AlertURL: webhook.URL,
+		Whales:   &WhaleWatch{MinAmount: big.NewInt(1_000_000)},
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher error: %v", err)
+	}
+	ctx := context.Background()
+	watcher.checkWhales(ctx)
+
+	// Far behind the head, one check scans a bounded number of batches.
+	node.AddTransfer(1500, alice, watchedAddress, big.NewInt(2_000_000), "0xc1")
+	node.SetHead(2000)
+	watcher.checkWhales(ctx)
+	if got := node.Requests("eth_getLogs"); got != maxWhaleBatchesPerCheck {
+		t.Fatalf("eth_getLogs requests = %d, want %d", got, maxWhaleBatchesPerCheck)
+	}
+	if got, want := watcher.whaleState.cursor.Block(), uint64(100+maxWhaleBatchesPerCheck*maxWhaleBatch); got != want {
+		t.Fatalf("cursor block = %d, want %d", got, want)
+	}
+	select {
+	case msg := <-messages:
+		t.Fatalf("unexpected alert before the scan reached block 1500: %q", msg)
+	default:
+	}
+
+	// The following check carries on from there.
+	watcher.checkWhales(ctx)
+	if got := watcher.whaleState.cursor.Block(); got != 2000 {
+		t.Fatalf("cursor block = %d, want 2000", got)
+	}
+	select {
+	case msg := <-messages:
+		want := "USDC transfer of 2.000000 from " + alice + " to " + watchedAddress + " at block 1500"
+		if msg != want {
+			t.Fatalf("whale alert = %q, want %q", msg, want)
+		}
+	default:
+		t.Fatal("no whale alert after catching up")
+	}
+}
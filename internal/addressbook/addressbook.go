@@ -0,0 +1,122 @@
+// Package addressbook maps addresses to human-readable labels and ownership
+// metadata, and selects addresses by that metadata.
+package addressbook
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"usdc-watch/internal/eth"
+)
+
+// Entry describes a known address.
+type Entry struct {
+	Address string
+	Label   string
+	Owner   string
+	Team    string
+	Tags    []string
+}
+
+// Book is an immutable set of entries keyed by normalized address.
+type Book struct {
+	entries   []Entry
+	byAddress map[string]Entry
+}
+
+// New builds a book, normalizing addresses and rejecting duplicates.
+func New(entries []Entry) (*Book, error) {
+	b := &Book{byAddress: make(map[string]Entry, len(entries))}
+	for _, entry := range entries {
+		address, err := eth.NormalizeAddress(entry.Address)
+		if err != nil {
+			return nil, fmt.Errorf("address book entry %q: %w", entry.Address, err)
+		}
+		if _, ok := b.byAddress[address]; ok {
+			return nil, fmt.Errorf("address book entry %s: duplicate address", address)
+		}
+		entry.Address = address
+		b.entries = append(b.entries, entry)
+		b.byAddress[address] = entry
+	}
+	return b, nil
+}
+
+// Lookup returns the entry for a normalized address. A nil book has no entries.
+func (b *Book) Lookup(address string) (Entry, bool) {
+	if b == nil {
+		return Entry{}, false
+	}
+	entry, ok := b.byAddress[strings.ToLower(address)]
+	return entry, ok
+}
+
+// Label returns the label of address, or "" when it has none.
+func (b *Book) Label(address string) string {
+	entry, _ := b.Lookup(address)
+	return entry.Label
+}
+
+// Select returns the entries matching selector, in book order. A selector is a
+// comma-separated list of key:value terms that must all match, where key is
+// tag, team, owner or label; for example "tag:hot-wallet,team:treasury".
+func (b *Book) Select(selector string) ([]Entry, error) {
+	terms, err := parseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, nil
+	}
+	var out []Entry
+	for _, entry := range b.entries {
+		if matches(entry, terms) {
+			out = append(out, entry)
+		}
+	}
+	return out, nil
+}
+
+type term struct {
+	key, value string
+}
+
+func parseSelector(selector string) ([]term, error) {
+	var terms []term
+	for _, raw := range strings.Split(selector, ",") {
+		raw = strings.TrimSpace(raw)
+		key, value, ok := strings.Cut(raw, ":")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("selector %q: term %q is not key:value", selector, raw)
+		}
+		switch key {
+		case "tag", "team", "owner", "label":
+		default:
+			return nil, fmt.Errorf("selector %q: unknown key %q", selector, key)
+		}
+		terms = append(terms, term{key: key, value: value})
+	}
+	return terms, nil
+}
+
+func matches(entry Entry, terms []term) bool {
+	for _, t := range terms {
+		var ok bool
+		switch t.key {
+		case "tag":
+			ok = slices.Contains(entry.Tags, t.value)
+		case "team":
+			ok = entry.Team == t.value
+		case "owner":
+			ok = entry.Owner == t.value
+		case "label":
+			ok = entry.Label == t.value
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
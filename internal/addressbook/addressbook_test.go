@@ -0,0 +1,42 @@
+package addressbook
+
+import "testing"
+
+func TestBookLookupAndSelect(t *testing.T) {
+	book, err := New([]Entry{
+		{Address: "0x00000000000000000000000000000000000000A1", Label: "ops hot", Team: "ops", Tags: []string{"hot-wallet"}},
+		{Address: "0x00000000000000000000000000000000000000a2", Label: "treasury", Team: "finance", Tags: []string{"cold"}},
+		{Address: "0x00000000000000000000000000000000000000a3", Label: "finance hot", Team: "finance", Tags: []string{"hot-wallet", "exchange"}},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	if label := book.Label("0x00000000000000000000000000000000000000a1"); label != "ops hot" {
+		t.Fatalf("Label = %q", label)
+	}
+	if label := book.Label("0x00000000000000000000000000000000000000ff"); label != "" {
+		t.Fatalf("unexpected label for unknown address: %q", label)
+	}
+
+	hot, err := book.Select("tag:hot-wallet")
+	if err != nil || len(hot) != 2 || hot[0].Label != "ops hot" || hot[1].Label != "finance hot" {
+		t.Fatalf("Select(tag:hot-wallet) = %+v, %v", hot, err)
+	}
+	financeHot, err := book.Select("tag:hot-wallet, team:finance")
+	if err != nil || len(financeHot) != 1 || financeHot[0].Address != "0x00000000000000000000000000000000000000a3" {
+		t.Fatalf("Select(tag:hot-wallet,team:finance) = %+v, %v", financeHot, err)
+	}
+	for _, bad := range []string{"", "hot-wallet", "color:red", "tag:"} {
+		if _, err := book.Select(bad); err == nil {
+			t.Fatalf("expected an error for selector %q", bad)
+		}
+	}
+
+	if _, err := New([]Entry{{Address: "0x00000000000000000000000000000000000000a1"}, {Address: "0x00000000000000000000000000000000000000A1"}}); err == nil {
+		t.Fatalf("expected a duplicate address error")
+	}
+	var empty *Book
+	if got, err := empty.Select("tag:x"); err != nil || got != nil || empty.Label("0x01") != "" {
+		t.Fatalf("nil book should be empty")
+	}
+}
@@ -0,0 +1,250 @@
+// Package alert renders notification messages from text/template templates.
+package alert
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+	"text/template"
+	"time"
+
+	"usdc-watch/internal/history"
+	"usdc-watch/internal/usdc"
+)
+
+// Built-in rules with default templates.
+const (
+	RuleThreshold      = "threshold"
+	RuleMeta           = "meta"
+	RuleBlacklisted    = "blacklisted"
+	RulePaused         = "paused"
+	RuleAllowance      = "allowance"
+	RuleUnknownSpender = "unknown_spender"
+	RuleMint           = "mint"
+	RuleBurn           = "burn"
+	RuleSupplyChange   = "supply_change"
+	RuleWhale          = "whale"
+	RuleReverted       = "reverted"
+	RulePending        = "pending"
+)
+
+// DefaultExplorerURL is the block explorer used for links when none is configured.
+const DefaultExplorerURL = "https://etherscan.io"
+
+// transactionsSuffix lists the transactions behind an alert, when looked up.
+const transactionsSuffix = `{{range .Transactions}} [tx {{abbrev .Hash}} by {{label .Sender}} via {{label .Contract}}, gas {{.GasUsed}}{{if not .Success}}, failed{{end}}]{{end}}`
+
+// defaults are the built-in message templates per rule. Rules without a
+// template of their own fall back to RuleMeta's when prefixed with "meta_".
+var defaults = map[string]string{
+	RuleThreshold:      `{{if .Label}}{{.Label}}: {{end}}USDC balance {{amount .Balance}} >= threshold {{amount .Threshold}}` + transactionsSuffix,
+	RuleMeta:           `USDC watcher {{if .Recovered}}recovered{{else}}unhealthy{{end}}: {{.Message}}`,
+	RuleBlacklisted:    `{{if .Label}}{{.Label}}: {{end}}{{.Address}} {{if .Recovered}}removed from{{else}}added to{{end}} the USDC blacklist at block {{.Block}}`,
+	RulePaused:         `USDC contract {{if .Recovered}}unpaused{{else}}paused{{end}} at block {{.Block}}`,
+	RuleAllowance:      `{{if .Label}}{{.Label}}: {{end}}USDC allowance for {{label .Spender}} {{if .Recovered}}back within limit at {{amount .Allowance}}{{else if unlimited .Allowance}}is unlimited{{else}}{{amount .Allowance}} > limit {{amount .Threshold}}{{end}}`,
+	RuleMint:           `USDC mint of {{amount .Amount}} to {{label .Address}} at block {{.Block}}` + transactionsSuffix,
+	RuleBurn:           `USDC burn of {{amount .Amount}} by {{label .Address}} at block {{.Block}}` + transactionsSuffix,
+	RuleSupplyChange:   `USDC supply {{if .Recovered}}change back under {{amount .Threshold}}{{else}}changed by {{amount .Delta}}{{end}} over {{.Window}}: total {{amount .Supply}}`,
+	RuleWhale:          `USDC transfer of {{amount .Amount}} from {{label .From}} to {{label .To}} at block {{.Block}}` + transactionsSuffix,
+	RulePending:        `{{if .Label}}{{.Label}}: {{end}}USDC transfer {{if .Recovered}}{{.Message}}{{else}}pending{{end}}: {{if eq .Address .To}}incoming{{else}}outgoing{{end}} {{amount .Amount}} from {{label .From}} to {{label .To}}{{if .Block}} at block {{.Block}}{{end}} (tx {{abbrev .TxHash}})`,
+	RuleReverted:       `Retracted, block {{.Block}} was orphaned by a chain reorganisation: {{.Message}}`,
+	RuleUnknownSpender: `{{if .Label}}{{.Label}}: {{end}}{{.Address}} approved unknown spender {{label .Spender}} for {{if unlimited .Allowance}}an unlimited amount{{else}}{{amount .Allowance}}{{end}} at block {{.Block}}`,
+}
+
+// Data is what a message template can refer to.
+type Data struct {
+	Rule    string
+	Address string
+	// Label names the address; empty when it has none.
+	Label string
+	Chain string
+
+	Balance         *big.Int
+	PreviousBalance *big.Int
+	Delta           *big.Int
+	Threshold       *big.Int
+
+	Block    uint64
+	Endpoint string
+	Time     time.Time
+	// TxHash is the transaction behind the alert, when known.
+	TxHash string
+
+	// Spender and Allowance describe allowance alerts; Threshold holds the limit.
+	Spender   string
+	Allowance *big.Int
+
+	// Amount is the size of a single mint, burn, whale or pending transfer, whose
+	// parties are From and To. Supply is the total supply for supply alerts,
+	// whose Delta is the net change over Window.
+	Amount *big.Int
+	From   string
+	To     string
+	Supply *big.Int
+	Window time.Duration
+
+	// Transfers are the transfers recorded since the previous observation.
+	Transfers []history.Transfer
+	// Transactions describe the transactions behind Transfers or TxHash
+	// when they were looked up.
+	Transactions []Transaction
+
+	// Message describes rules without balance data, such as meta alerts,
+	// holds the retracted message of a reverted alert or how a pending
+	// transfer ended. Recovered reports that the condition has cleared.
+	Message   string
+	Recovered bool
+}
+
+// Transaction describes the transaction behind a transfer or event: who sent
+// it, which contract it called and how it executed.
+type Transaction struct {
+	Hash   string
+	Sender string
+	// Contract is the account the sender called, such as USDC itself for a
+	// plain transfer or a bridge or exchange contract; Method is the called
+	// 4-byte selector in hex.
+	Contract string
+	Method   string
+	GasUsed  uint64
+	Success  bool
+}
+
+// Definition overrides the template for a rule, optionally for one notifier only.
+type Definition struct {
+	Rule     string
+	Notifier string
+	Text     string
+}
+
+// Templates renders messages, preferring a rule's notifier-specific template,
+// then its general template, then the built-in default.
+type Templates struct {
+	templates map[string]*template.Template
+}
+
+// Options configures the helpers available to templates.
+type Options struct {
+	// ExplorerURL is the base URL for explorer links; DefaultExplorerURL when empty.
+	ExplorerURL string
+	// Label returns the label of an address, or "" when it has none.
+	Label func(address string) string
+}
+
+// New parses the definitions on top of the built-in defaults.
+func New(defs []Definition, opts Options) (*Templates, error) {
+	if opts.ExplorerURL == "" {
+		opts.ExplorerURL = DefaultExplorerURL
+	}
+	if opts.Label == nil {
+		opts.Label = func(string) string { return "" }
+	}
+	funcs := funcMap(strings.TrimSuffix(opts.ExplorerURL, "/"), opts.Label)
+	t := &Templates{templates: make(map[string]*template.Template)}
+	for rule, text := range defaults {
+		tmpl, err := template.New(rule).Funcs(funcs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("default template %s: %w", rule, err)
+		}
+		t.templates[key(rule, "")] = tmpl
+	}
+	for _, def := range defs {
+		name := key(def.Rule, def.Notifier)
+		tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(def.Text)
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", name, err)
+		}
+		t.templates[name] = tmpl
+	}
+	return t, nil
+}
+
+// Render executes the template for rule and notifier with data.
+func (t *Templates) Render(rule, notifier string, data Data) (string, error) {
+	tmpl := t.lookup(rule, notifier)
+	if tmpl == nil {
+		return "", fmt.Errorf("no template for rule %q", rule)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("render %s template: %w", rule, err)
+	}
+	return buf.String(), nil
+}
+
+func (t *Templates) lookup(rule, notifier string) *template.Template {
+	for _, name := range []string{key(rule, notifier), key(rule, "")} {
+		if tmpl, ok := t.templates[name]; ok {
+			return tmpl
+		}
+	}
+	if strings.HasPrefix(rule, RuleMeta+"_") {
+		return t.lookup(RuleMeta, notifier)
+	}
+	return nil
+}
+
+func key(rule, notifier string) string {
+	if notifier == "" {
+		return rule
+	}
+	return rule + "/" + notifier
+}
+
+// funcMap returns the helpers available to templates.
+func funcMap(explorerURL string, label func(string) string) template.FuncMap {
+	return template.FuncMap{
+		// amount formats base units with all six decimals, e.g. 2.500000.
+		"amount": FormatFixed,
+		// short formats base units without trailing zero decimals, e.g. 2.5.
+		"short": func(amount *big.Int) string {
+			return strings.TrimSuffix(strings.TrimRight(FormatFixed(amount), "0"), ".")
+		},
+		// abbrev shortens an address or hash to 0x1234…abcd.
+		"abbrev": func(value string) string {
+			if len(value) <= 12 {
+				return value
+			}
+			return value[:6] + "…" + value[len(value)-4:]
+		},
+		// name is the label when set, otherwise the address.
+		"name": func(label, address string) string {
+			if label != "" {
+				return label
+			}
+			return address
+		},
+		// label names any address, such as a transfer counterparty, from the
+		// address book, falling back to the address itself.
+		"label": func(address string) string {
+			if l := label(address); l != "" {
+				return l
+			}
+			return address
+		},
+		// unlimited reports whether an allowance is the maximum uint256.
+		"unlimited":  usdc.IsUnlimited,
+		"addressURL": func(address string) string { return explorerURL + "/address/" + address },
+		"txURL":      func(hash string) string { return explorerURL + "/tx/" + hash },
+		"blockURL":   func(block uint64) string { return fmt.Sprintf("%s/block/%d", explorerURL, block) },
+		"timestamp":  func(t time.Time) string { return t.UTC().Format(time.RFC3339) },
+	}
+}
+
+// FormatFixed formats a possibly negative base-unit amount with all six
+// decimals. A nil amount formats as zero.
+func FormatFixed(amount *big.Int) string {
+	if amount == nil {
+		amount = new(big.Int)
+	}
+	sign := ""
+	if amount.Sign() < 0 {
+		sign, amount = "-", new(big.Int).Neg(amount)
+	}
+	formatted := usdc.FormatAmount(amount)
+	if !strings.Contains(formatted, ".") {
+		formatted += ".000000"
+	}
+	return sign + formatted
+}
@@ -0,0 +1,95 @@
+package alert
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"usdc-watch/internal/history"
+)
+
+func TestDefaultTemplates(t *testing.T) {
+	tmpl, err := New(nil, Options{})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	msg, err := tmpl.Render(RuleThreshold, "webhook", Data{Balance: big.NewInt(2_500_000), Threshold: big.NewInt(1_000_000)})
+	if err != nil || msg != "USDC balance 2.500000 >= threshold 1.000000" {
+		t.Fatalf("threshold message %q, %v", msg, err)
+	}
+	msg, err = tmpl.Render("meta_stale", "", Data{Message: "no successful balance poll for 3m0s"})
+	if err != nil || msg != "USDC watcher unhealthy: no successful balance poll for 3m0s" {
+		t.Fatalf("meta message %q, %v", msg, err)
+	}
+	msg, err = tmpl.Render(RuleThreshold, "", Data{Label: "treasury", Balance: big.NewInt(2_500_000), Threshold: big.NewInt(1_000_000)})
+	if err != nil || msg != "treasury: USDC balance 2.500000 >= threshold 1.000000" {
+		t.Fatalf("labelled threshold message %q, %v", msg, err)
+	}
+	msg, err = tmpl.Render(RuleThreshold, "", Data{Balance: big.NewInt(2_500_000), Threshold: big.NewInt(1_000_000), Transactions: []Transaction{
+		{Hash: "0x1234567890abcdef", Sender: "0xa1", Contract: "0xc1", GasUsed: 51000, Success: true},
+		{Hash: "0xfedcba0987654321", Sender: "0xa2", Contract: "0xc2", GasUsed: 21000},
+	}})
+	if want := "USDC balance 2.500000 >= threshold 1.000000 [tx 0x1234…cdef by 0xa1 via 0xc1, gas 51000] [tx 0xfedc…4321 by 0xa2 via 0xc2, gas 21000, failed]"; err != nil || msg != want {
+		t.Fatalf("threshold message with transactions %q, %v; want %q", msg, err, want)
+	}
+	pending := Data{Address: "0x00000000000000000000000000000000000000a1", Label: "treasury", Amount: big.NewInt(5_000_000), From: "0x00000000000000000000000000000000000000b0", To: "0x00000000000000000000000000000000000000a1", TxHash: "0x1234567890abcdef"}
+	msg, err = tmpl.Render(RulePending, "", pending)
+	if want := "treasury: USDC transfer pending: incoming 5.000000 from 0x00000000000000000000000000000000000000b0 to 0x00000000000000000000000000000000000000a1 (tx 0x1234…cdef)"; err != nil || msg != want {
+		t.Fatalf("pending message %q, %v; want %q", msg, err, want)
+	}
+	pending.Block, pending.Message, pending.Recovered = 9, "confirmed", true
+	msg, err = tmpl.Render(RulePending, "", pending)
+	if want := "treasury: USDC transfer confirmed: incoming 5.000000 from 0x00000000000000000000000000000000000000b0 to 0x00000000000000000000000000000000000000a1 at block 9 (tx 0x1234…cdef)"; err != nil || msg != want {
+		t.Fatalf("confirmed pending message %q, %v; want %q", msg, err, want)
+	}
+	msg, err = tmpl.Render(RuleBlacklisted, "", Data{Address: "0x00000000000000000000000000000000000000a1", Block: 7, Recovered: true})
+	if err != nil || msg != "0x00000000000000000000000000000000000000a1 removed from the USDC blacklist at block 7" {
+		t.Fatalf("blacklist message %q, %v", msg, err)
+	}
+	msg, err = tmpl.Render(RulePaused, "", Data{Block: 8})
+	if err != nil || msg != "USDC contract paused at block 8" {
+		t.Fatalf("paused message %q, %v", msg, err)
+	}
+	if _, err := tmpl.Render("unknown", "", Data{}); err == nil {
+		t.Fatalf("expected an error for a rule without a template")
+	}
+}
+
+func TestCustomTemplates(t *testing.T) {
+	tmpl, err := New([]Definition{
+		{Rule: RuleThreshold, Text: `{{name .Label .Address}} on {{.Chain}}: {{short .Balance}} ({{amount .Delta}})`},
+		{Rule: RuleThreshold, Notifier: "webhook", Text: `{{range .Transfers}}{{label .From}} {{txURL .TxHash}} {{end}}{{addressURL .Address}} {{blockURL .Block}} {{timestamp .Time}} {{abbrev .Address}}`},
+	}, Options{
+		ExplorerURL: "https://explorer.example/",
+		Label: func(address string) string {
+			if address == "0x00000000000000000000000000000000000000b0" {
+				return "exchange"
+			}
+			return ""
+		},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	data := Data{
+		Address:   "0x00000000000000000000000000000000000000a1",
+		Label:     "treasury",
+		Chain:     "ethereum",
+		Balance:   big.NewInt(1_500_000),
+		Delta:     big.NewInt(-250_000),
+		Block:     42,
+		Time:      time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Transfers: []history.Transfer{{TxHash: "0xfeed", From: "0x00000000000000000000000000000000000000b0"}},
+	}
+	if msg, err := tmpl.Render(RuleThreshold, "log", data); err != nil || msg != "treasury on ethereum: 1.5 (-0.250000)" {
+		t.Fatalf("general template rendered %q, %v", msg, err)
+	}
+	want := "exchange https://explorer.example/tx/0xfeed https://explorer.example/address/0x00000000000000000000000000000000000000a1 " +
+		"https://explorer.example/block/42 2024-01-02T03:04:05Z 0x0000…00a1"
+	if msg, err := tmpl.Render(RuleThreshold, "webhook", data); err != nil || msg != want {
+		t.Fatalf("notifier template rendered %q, %v", msg, err)
+	}
+	if _, err := New([]Definition{{Rule: RuleThreshold, Text: "{{.Balance"}}, Options{}); err == nil {
+		t.Fatalf("expected a parse error")
+	}
+}
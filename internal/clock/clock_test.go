@@ -0,0 +1,56 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeAdvance(t *testing.T) {
+	start := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	short := f.After(time.Second)
+	long := f.After(time.Minute)
+	if f.Waiters() != 2 {
+		t.Fatalf("expected 2 waiters, got %d", f.Waiters())
+	}
+	if d, ok := f.NextDeadline(); !ok || d != time.Second {
+		t.Fatalf("NextDeadline = %s, %v", d, ok)
+	}
+
+	f.Advance(30 * time.Second)
+	select {
+	case at := <-short:
+		if !at.Equal(start.Add(30 * time.Second)) {
+			t.Fatalf("unexpected fire time %s", at)
+		}
+	default:
+		t.Fatalf("expected short timer to fire")
+	}
+	select {
+	case <-long:
+		t.Fatalf("long timer fired early")
+	default:
+	}
+
+	f.Advance(30 * time.Second)
+	<-long
+	if f.Waiters() != 0 {
+		t.Fatalf("expected no waiters, got %d", f.Waiters())
+	}
+	select {
+	case <-f.After(0):
+	default:
+		t.Fatalf("zero duration should fire immediately")
+	}
+}
+
+func TestFakeBlockUntil(t *testing.T) {
+	f := NewFake(time.Unix(0, 0))
+	done := make(chan struct{})
+	go func() {
+		f.BlockUntil(1)
+		close(done)
+	}()
+	f.After(time.Hour)
+	<-done
+}
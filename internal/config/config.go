@@ -0,0 +1,309 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"usdc-watch/internal/eth"
+)
+
+// Config is the content of the watcher's TOML-style configuration file.
+type Config struct {
+	Endpoints  []Endpoint
+	Watches    []Watch
+	Templates  []Template
+	Addresses  []Address
+	Allowances []Allowance
+	Supply     []Supply
+	Whales     []Whales
+}
+
+// Watch is a [[watch]] block: an address, or a selector over the address book,
+// and the rules applied to it.
+type Watch struct {
+	Address  string
+	Selector string
+	// Threshold is the alert threshold as a decimal USDC amount.
+	Threshold string
+}
+
+// Address is an [[address]] block describing a known address.
+type Address struct {
+	Address string
+	Label   string
+	Owner   string
+	Team    string
+	Tags    []string
+}
+
+// Allowance is an [[allowance]] block: an owner/spender pair whose USDC
+// allowance is monitored. Limit is a decimal USDC amount; when empty only
+// unlimited allowances alert.
+type Allowance struct {
+	Owner   string
+	Spender string
+	Limit   string
+}
+
+// Supply is a [[supply]] block configuring alerts on USDC's total supply.
+// Amounts are decimal USDC; Window is a duration such as "1h".
+type Supply struct {
+	MintThreshold string
+	BurnThreshold string
+	NetChange     string
+	Window        string
+}
+
+// Whales is a [[whales]] block configuring alerts on arbitrary USDC transfers:
+// any transfer of at least MinAmount, a decimal USDC amount, and any transfer
+// to or from an address-book entry carrying one of the Watchlist tags.
+type Whales struct {
+	MinAmount string
+	Watchlist []string
+}
+
+// Template is a [[template]] block overriding the alert message for a rule,
+// optionally only for one notifier. The text is given inline or read from File,
+// which is relative to the configuration file.
+type Template struct {
+	Rule     string
+	Notifier string
+	Text     string
+	File     string
+}
+
+// table is one [[name]] block and its key/value pairs.
+type table struct {
+	name   string
+	line   int
+	values map[string]string
+}
+
+// Load parses the [[rpc.endpoints]], [[watch]], [[address]], [[allowance]],
+// [[supply]], [[whales]] and [[template]] blocks from a configuration file.
+// Unknown blocks and keys are ignored.
+func Load(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open config file: %w", err)
+	}
+	defer f.Close()
+
+	tables, err := parseTables(f)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	for _, t := range tables {
+		switch t.name {
+		case "rpc.endpoints":
+			endpoint := Endpoint{Name: t.values["name"], URL: t.values["url"]}
+			if strings.TrimSpace(endpoint.URL) == "" {
+				return nil, fmt.Errorf("endpoint missing url near line %d", t.line)
+			}
+			if strings.TrimSpace(endpoint.Name) == "" {
+				endpoint.Name = fmt.Sprintf("endpoint-%d", len(cfg.Endpoints)+1)
+			}
+			cfg.Endpoints = append(cfg.Endpoints, endpoint)
+		case "watch":
+			cfg.Watches = append(cfg.Watches, Watch{Address: t.values["address"], Selector: t.values["selector"], Threshold: t.values["threshold"]})
+		case "address":
+			cfg.Addresses = append(cfg.Addresses, Address{
+				Address: t.values["address"],
+				Label:   t.values["label"],
+				Owner:   t.values["owner"],
+				Team:    t.values["team"],
+				Tags:    parseList(t.values["tags"]),
+			})
+		case "allowance":
+			cfg.Allowances = append(cfg.Allowances, Allowance{Owner: t.values["owner"], Spender: t.values["spender"], Limit: t.values["limit"]})
+		case "supply":
+			cfg.Supply = append(cfg.Supply, Supply{
+				MintThreshold: t.values["mint_threshold"],
+				BurnThreshold: t.values["burn_threshold"],
+				NetChange:     t.values["net_change"],
+				Window:        t.values["window"],
+			})
+		case "whales":
+			cfg.Whales = append(cfg.Whales, Whales{MinAmount: t.values["min_amount"], Watchlist: parseList(t.values["watchlist"])})
+		case "template":
+			tmpl := Template{Rule: t.values["rule"], Notifier: t.values["notifier"], Text: t.values["text"], File: t.values["file"]}
+			if tmpl.File != "" && !filepath.IsAbs(tmpl.File) {
+				tmpl.File = filepath.Join(filepath.Dir(path), tmpl.File)
+			}
+			cfg.Templates = append(cfg.Templates, tmpl)
+		}
+	}
+	if len(cfg.Endpoints) == 0 {
+		return nil, errors.New("no endpoints found in configuration")
+	}
+	return cfg, nil
+}
+
+// Validate reports every problem found in the configuration.
+func Validate(cfg *Config) error {
+	errs := []error{ValidateEndpoints(cfg.Endpoints)}
+	seen := make(map[string]bool)
+	for i, watch := range cfg.Watches {
+		if (watch.Address == "") == (watch.Selector == "") {
+			errs = append(errs, fmt.Errorf("watch %d: exactly one of address and selector is required", i+1))
+			continue
+		}
+		if strings.TrimSpace(watch.Threshold) == "" {
+			errs = append(errs, fmt.Errorf("watch %d: missing threshold", i+1))
+		}
+		if watch.Selector != "" {
+			continue
+		}
+		address, err := eth.NormalizeAddress(watch.Address)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("watch %d: invalid address %q: %w", i+1, watch.Address, err))
+			continue
+		}
+		if seen[address] {
+			errs = append(errs, fmt.Errorf("watch %d: duplicate address %s", i+1, address))
+		}
+		seen[address] = true
+	}
+	known := make(map[string]bool)
+	for i, entry := range cfg.Addresses {
+		address, err := eth.NormalizeAddress(entry.Address)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("address %d: invalid address %q: %w", i+1, entry.Address, err))
+			continue
+		}
+		if known[address] {
+			errs = append(errs, fmt.Errorf("address %d: duplicate address %s", i+1, address))
+		}
+		known[address] = true
+	}
+	pairs := make(map[string]bool)
+	for i, allowance := range cfg.Allowances {
+		owner, err := eth.NormalizeAddress(allowance.Owner)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("allowance %d: invalid owner %q: %w", i+1, allowance.Owner, err))
+			continue
+		}
+		spender, err := eth.NormalizeAddress(allowance.Spender)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("allowance %d: invalid spender %q: %w", i+1, allowance.Spender, err))
+			continue
+		}
+		if pairs[owner+"/"+spender] {
+			errs = append(errs, fmt.Errorf("allowance %d: duplicate pair %s/%s", i+1, owner, spender))
+		}
+		pairs[owner+"/"+spender] = true
+	}
+	if len(cfg.Supply) > 1 {
+		errs = append(errs, fmt.Errorf("supply: at most one [[supply]] block is allowed, found %d", len(cfg.Supply)))
+	}
+	for _, supply := range cfg.Supply {
+		if supply.MintThreshold == "" && supply.BurnThreshold == "" && supply.NetChange == "" {
+			errs = append(errs, errors.New("supply: at least one of mint_threshold, burn_threshold and net_change is required"))
+		}
+		if (supply.NetChange == "") != (supply.Window == "") {
+			errs = append(errs, errors.New("supply: net_change and window must be given together"))
+		} else if supply.Window != "" {
+			if window, err := time.ParseDuration(supply.Window); err != nil || window <= 0 {
+				errs = append(errs, fmt.Errorf("supply: invalid window %q", supply.Window))
+			}
+		}
+	}
+	if len(cfg.Whales) > 1 {
+		errs = append(errs, fmt.Errorf("whales: at most one [[whales]] block is allowed, found %d", len(cfg.Whales)))
+	}
+	for _, whales := range cfg.Whales {
+		if whales.MinAmount == "" && len(whales.Watchlist) == 0 {
+			errs = append(errs, errors.New("whales: at least one of min_amount and watchlist is required"))
+		}
+	}
+	for i, tmpl := range cfg.Templates {
+		if strings.TrimSpace(tmpl.Rule) == "" {
+			errs = append(errs, fmt.Errorf("template %d: missing rule", i+1))
+		}
+		if (tmpl.Text == "") == (tmpl.File == "") {
+			errs = append(errs, fmt.Errorf("template %d: exactly one of text and file is required", i+1))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func parseTables(f *os.File) ([]table, error) {
+	scanner := bufio.NewScanner(f)
+	var (
+		tables  []table
+		current map[string]string
+		lineNo  int
+	)
+	for scanner.Scan() {
+		lineNo++
+		rawLine := scanner.Text()
+		line := strings.TrimSpace(rawLine)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[[") && strings.HasSuffix(line, "]]") {
+			current = make(map[string]string)
+			tables = append(tables, table{name: strings.TrimSpace(line[2 : len(line)-2]), line: lineNo, values: current})
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			current = nil
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid line %d: %s", lineNo, rawLine)
+		}
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+		current[key] = unquote(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan endpoints file: %w", err)
+	}
+	return tables, nil
+}
+
+// unquote strips the quotes around a string value. Double-quoted values may use
+// escapes such as \n; single-quoted values are literal.
+func unquote(value string) string {
+	if len(value) < 2 {
+		return value
+	}
+	switch {
+	case value[0] == '"' && value[len(value)-1] == '"':
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			return unquoted
+		}
+		return value[1 : len(value)-1]
+	case value[0] == '\'' && value[len(value)-1] == '\'':
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// parseList splits a list value written either as an array, ["a", "b"], or as a
+// comma-separated string, "a, b".
+func parseList(value string) []string {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+		value = value[1 : len(value)-1]
+	}
+	var out []string
+	for _, item := range strings.Split(value, ",") {
+		if item = unquote(strings.TrimSpace(item)); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,242 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadWatches(t *testing.T) {
+	content := `[[rpc.endpoints]]
+name = "first"
+url = "https://a.example"
+
+[[watch]]
+address = "0x00000000000000000000000000000000000000a1"
+threshold = "1000.5"
+
+[[watch]]
+address = '0x00000000000000000000000000000000000000a2'
+threshold = "5"
+`
+	path := filepath.Join(t.TempDir(), "watcher.toml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if len(cfg.Endpoints) != 1 || len(cfg.Watches) != 2 {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	if w := cfg.Watches[1]; w.Address != "0x00000000000000000000000000000000000000a2" || w.Threshold != "5" {
+		t.Fatalf("unexpected second watch: %+v", w)
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+}
+
+func TestValidateWatches(t *testing.T) {
+	cfg := &Config{
+		Endpoints: []Endpoint{{Name: "a", URL: "https://a.example"}},
+		Watches: []Watch{
+			{Address: "0x00000000000000000000000000000000000000a1", Threshold: "1"},
+			{Address: "0x00000000000000000000000000000000000000A1", Threshold: "2"},
+			{Address: "0x1234", Threshold: "1"},
+			{Address: "0x00000000000000000000000000000000000000a3"},
+		},
+	}
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatalf("expected validation errors")
+	}
+	for _, want := range []string{"watch 2: duplicate address", "watch 3: invalid address", "watch 4: missing threshold"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("validation error missing %q: %v", want, err)
+		}
+	}
+}
+
+func TestLoadTemplates(t *testing.T) {
+	content := `[[rpc.endpoints]]
+url = "https://a.example"
+
+[[template]]
+rule = "threshold"
+text = "line one\nbalance {{amount .Balance}}"
+
+[[template]]
+rule = "meta"
+notifier = "webhook"
+file = "meta.tmpl"
+`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "watcher.toml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if len(cfg.Templates) != 2 {
+		t.Fatalf("unexpected templates: %+v", cfg.Templates)
+	}
+	if got := cfg.Templates[0].Text; got != "line one\nbalance {{amount .Balance}}" {
+		t.Fatalf("escapes not applied: %q", got)
+	}
+	if got := cfg.Templates[1]; got.Notifier != "webhook" || got.File != filepath.Join(dir, "meta.tmpl") {
+		t.Fatalf("unexpected file template: %+v", got)
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	cfg.Templates = append(cfg.Templates, Template{Text: "x", File: "y"})
+	err = Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "template 3: missing rule") || !strings.Contains(err.Error(), "exactly one of text and file") {
+		t.Fatalf("unexpected validation result: %v", err)
+	}
+}
+
+func TestLoadAddressBook(t *testing.T) {
+	content := `[[rpc.endpoints]]
+url = "https://a.example"
+
+[[address]]
+address = "0x00000000000000000000000000000000000000a1"
+label = "ops hot wallet"
+owner = "alice"
+team = "ops"
+tags = ["hot-wallet", "ops"]
+
+[[address]]
+address = "0x00000000000000000000000000000000000000a2"
+label = "treasury"
+tags = "cold, treasury"
+
+[[watch]]
+selector = "tag:hot-wallet"
+threshold = "10"
+`
+	path := filepath.Join(t.TempDir(), "watcher.toml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if len(cfg.Addresses) != 2 {
+		t.Fatalf("unexpected addresses: %+v", cfg.Addresses)
+	}
+	first := cfg.Addresses[0]
+	if first.Label != "ops hot wallet" || first.Owner != "alice" || first.Team != "ops" || strings.Join(first.Tags, "|") != "hot-wallet|ops" {
+		t.Fatalf("unexpected first entry: %+v", first)
+	}
+	if got := strings.Join(cfg.Addresses[1].Tags, "|"); got != "cold|treasury" {
+		t.Fatalf("unexpected comma-separated tags: %q", got)
+	}
+	if len(cfg.Watches) != 1 || cfg.Watches[0].Selector != "tag:hot-wallet" {
+		t.Fatalf("unexpected watches: %+v", cfg.Watches)
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+
+	cfg.Watches = append(cfg.Watches, Watch{Address: "0x00000000000000000000000000000000000000a3", Selector: "tag:x", Threshold: "1"})
+	cfg.Addresses = append(cfg.Addresses, Address{Address: "0x00000000000000000000000000000000000000A2"})
+	err = Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "watch 2: exactly one of address and selector") || !strings.Contains(err.Error(), "address 3: duplicate address") {
+		t.Fatalf("unexpected validation result: %v", err)
+	}
+}
+
+func TestLoadAllowances(t *testing.T) {
+	content := `[[rpc.endpoints]]
+url = "https://a.example"
+
+[[allowance]]
+owner = "0x00000000000000000000000000000000000000a1"
+spender = "0x00000000000000000000000000000000000000c1"
+limit = "50000"
+
+[[allowance]]
+owner = "0x00000000000000000000000000000000000000a1"
+spender = "0x00000000000000000000000000000000000000c2"
+`
+	path := filepath.Join(t.TempDir(), "watcher.toml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if len(cfg.Allowances) != 2 || cfg.Allowances[0].Limit != "50000" || cfg.Allowances[1].Limit != "" {
+		t.Fatalf("unexpected allowances: %+v", cfg.Allowances)
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+
+	cfg.Allowances = append(cfg.Allowances,
+		Allowance{Owner: "0x00000000000000000000000000000000000000A1", Spender: "0x00000000000000000000000000000000000000c1"},
+		Allowance{Owner: "0x00000000000000000000000000000000000000a1", Spender: "router"},
+	)
+	err = Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "allowance 3: duplicate pair") || !strings.Contains(err.Error(), "allowance 4: invalid spender") {
+		t.Fatalf("unexpected validation result: %v", err)
+	}
+}
+
+func TestValidateSupply(t *testing.T) {
+	cfg := &Config{
+		Endpoints: []Endpoint{{Name: "a", URL: "https://a.example"}},
+		Supply:    []Supply{{MintThreshold: "1000000", NetChange: "5000000", Window: "1h"}},
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	cfg.Supply = append(cfg.Supply, Supply{NetChange: "1"}, Supply{Window: "soon"}, Supply{})
+	err := Validate(cfg)
+	for _, want := range []string{"at most one [[supply]] block", "net_change and window must be given together", "at least one of mint_threshold"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("validation error missing %q: %v", want, err)
+		}
+	}
+}
+
+func TestLoadWhales(t *testing.T) {
+	content := `[[rpc.endpoints]]
+url = "https://a.example"
+
+[[whales]]
+min_amount = "10000000"
+watchlist = ["exchange", "sanctioned"]
+`
+	path := filepath.Join(t.TempDir(), "watcher.toml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if len(cfg.Whales) != 1 || cfg.Whales[0].MinAmount != "10000000" || strings.Join(cfg.Whales[0].Watchlist, "|") != "exchange|sanctioned" {
+		t.Fatalf("unexpected whales: %+v", cfg.Whales)
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+
+	cfg.Whales = append(cfg.Whales, Whales{})
+	err = Validate(cfg)
+	for _, want := range []string{"at most one [[whales]] block", "at least one of min_amount and watchlist"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("validation error missing %q: %v", want, err)
+		}
+	}
+}
@@ -1,11 +1,9 @@
 package config
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
-	"os"
-	"strings"
+	"net/url"
 )
 
 // Endpoint represents a JSON-RPC endpoint definition.
@@ -16,91 +14,34 @@ type Endpoint struct {
 
 // LoadEndpoints parses the [[rpc.endpoints]] blocks from a TOML-style configuration file.
 func LoadEndpoints(path string) ([]Endpoint, error) {
-	f, err := os.Open(path)
+	cfg, err := Load(path)
 	if err != nil {
-		return nil, fmt.Errorf("open endpoints file: %w", err)
-	}
-	defer f.Close()
-
-	scanner := bufio.NewScanner(f)
-	var (
-		endpoints    []Endpoint
-		current      Endpoint
-		inCollection bool
-		lineNo       int
-	)
-
-	flush := func(force bool) error {
-		if !inCollection {
-			return nil
-		}
-		if strings.TrimSpace(current.URL) == "" {
-			if force {
-				return fmt.Errorf("endpoint missing url near line %d", lineNo)
-			}
-			current = Endpoint{}
-			inCollection = false
-			return nil
-		}
-		if strings.TrimSpace(current.Name) == "" {
-			current.Name = fmt.Sprintf("endpoint-%d", len(endpoints)+1)
-		}
-		endpoints = append(endpoints, current)
-		current = Endpoint{}
-		inCollection = false
-		return nil
+		return nil, err
 	}
+	return cfg.Endpoints, nil
+}
 
-	for scanner.Scan() {
-		lineNo++
-		rawLine := scanner.Text()
-		line := strings.TrimSpace(rawLine)
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-		if strings.HasPrefix(line, "[[") && strings.HasSuffix(line, "]]") {
-			if err := flush(true); err != nil {
-				return nil, err
-			}
-			if line == "[[rpc.endpoints]]" {
-				inCollection = true
-				current = Endpoint{}
-			} else {
-				inCollection = false
-			}
-			continue
-		}
-		if !inCollection {
+// ValidateEndpoints reports every problem found in the endpoint list: duplicate
+// names and URLs that are not absolute http(s) URLs.
+func ValidateEndpoints(endpoints []Endpoint) error {
+	var errs []error
+	seen := make(map[string]bool)
+	for _, endpoint := range endpoints {
+		if seen[endpoint.Name] {
+			errs = append(errs, fmt.Errorf("endpoint %q: duplicate name", endpoint.Name))
+		}
+		seen[endpoint.Name] = true
+		parsed, err := url.Parse(endpoint.URL)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("endpoint %q: invalid url: %w", endpoint.Name, err))
 			continue
 		}
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 {
-			return nil, fmt.Errorf("invalid line %d: %s", lineNo, rawLine)
+		if parsed.Scheme != "http" && parsed.Scheme != "https" {
+			errs = append(errs, fmt.Errorf("endpoint %q: url scheme must be http or https", endpoint.Name))
 		}
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-		if len(value) >= 2 {
-			if (value[0] == '"' && value[len(value)-1] == '"') || (value[0] == '\'' && value[len(value)-1] == '\'') {
-				value = value[1 : len(value)-1]
-			}
-		}
-		switch key {
-		case "name":
-			current.Name = value
-		case "url":
-			current.URL = value
-		default:
-			continue
+		if parsed.Host == "" {
+			errs = append(errs, fmt.Errorf("endpoint %q: url has no host", endpoint.Name))
 		}
 	}
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("scan endpoints file: %w", err)
-	}
-	if err := flush(true); err != nil {
-		return nil, err
-	}
-	if len(endpoints) == 0 {
-		return nil, errors.New("no endpoints found in configuration")
-	}
-	return endpoints, nil
+	return errors.Join(errs...)
 }
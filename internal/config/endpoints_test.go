@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -51,3 +52,20 @@ func TestLoadEndpointsErrors(t *testing.T) {
 		t.Fatalf("expected error for missing file")
 	}
 }
+
+func TestValidateEndpoints(t *testing.T) {
+	valid := []Endpoint{{Name: "a", URL: "https://a.example"}, {Name: "b", URL: "http://127.0.0.1:8545"}}
+	if err := ValidateEndpoints(valid); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	invalid := []Endpoint{{Name: "a", URL: "https://a.example"}, {Name: "a", URL: "ftp://b.example"}, {Name: "c", URL: "https://"}}
+	err := ValidateEndpoints(invalid)
+	if err == nil {
+		t.Fatalf("expected validation errors")
+	}
+	for _, want := range []string{"duplicate name", "scheme", "no host"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("validation error missing %q: %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+// Package cursor tracks how far a log scanner has processed the chain and
+// detects reorganisations of the blocks it processed, so a restarted scanner
+// resumes after the last block it finished and orphaned blocks are rescanned.
+package cursor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"usdc-watch/internal/eth"
+)
+
+// Depth is the number of recent block hashes a cursor keeps to locate the
+// fork point of a reorganisation.
+const Depth = 128
+
+// Checkpoint is the hash a processed block had when it was processed.
+type Checkpoint struct {
+	Block uint64 `json:"block"`
+	Hash  string `json:"hash"`
+}
+
+// Reorg reports processed blocks that are no longer canonical: those after
+// Fork up to and including Orphaned.
+type Reorg struct {
+	Fork     uint64
+	Orphaned uint64
+}
+
+// HeaderFunc returns the canonical header of a block.
+type HeaderFunc func(ctx context.Context, block uint64) (eth.Header, error)
+
+// Cursor is the last processed block and the recent block hashes. A cursor
+// opened from a file replaces it atomically on each update.
+type Cursor struct {
+	path string
+
+	mu     sync.Mutex
+	block  uint64
+	hashes []Checkpoint
+}
+
+type document struct {
+	Block  uint64       `json:"block"`
+	Hashes []Checkpoint `json:"hashes,omitempty"`
+}
+
+// New returns a cursor kept in memory only, at block zero.
+func New() *Cursor {
+	return &Cursor{}
+}
+
+// Open reads the cursor at path. A missing file is a cursor at block zero,
+// meaning nothing has been processed yet.
+func Open(path string) (*Cursor, error) {
+	c := &Cursor{path: path}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read cursor: %w", err)
+	}
+	var doc document
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("decode cursor %s: %w", path, err)
+	}
+	c.block, c.hashes = doc.Block, doc.Hashes
+	return c, nil
+}
+
+// Block returns the last processed block, or zero when none has been.
+func (c *Cursor) Block() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.block
+}
+
+// Advance records block, whose hash is given when known, as processed.
+func (c *Cursor) Advance(block uint64, hash string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	hashes := c.hashes
+	if hash != "" {
+		hashes = append(hashes[:len(hashes):len(hashes)], Checkpoint{Block: block, Hash: strings.ToLower(hash)})
+		if len(hashes) > Depth {
+			hashes = hashes[len(hashes)-Depth:]
+		}
+	}
+	return c.storeLocked(block, hashes)
+}
+
+// Rewind moves the cursor back to block, forgetting the hashes of later
+// blocks. A cursor at or before block is unchanged.
+func (c *Cursor) Rewind(block uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if block >= c.block {
+		return nil
+	}
+	kept := 0
+	for kept < len(c.hashes) && c.hashes[kept].Block <= block {
+		kept++
+	}
+	return c.storeLocked(block, c.hashes[:kept:kept])
+}
+
+// Verify checks that the last processed block is still canonical, given the
+// header of the current head. A head following that block must name it as
+// parent; otherwise the block is looked up. After a reorganisation the cursor
+// rewinds to the newest retained block that is still canonical, or to before
+// the oldest retained one when none is, and the orphaned range is returned.
+// Verify returns nil when the hash of the last processed block is unknown.
+func (c *Cursor) Verify(ctx context.Context, head eth.Header, lookup HeaderFunc) (*Reorg, error) {
+	c.mu.Lock()
+	block, hashes := c.block, c.hashes
+	c.mu.Unlock()
+	if len(hashes) == 0 || hashes[len(hashes)-1].Block != block {
+		return nil, nil
+	}
+	number, err := eth.ParseQuantity(head.Number)
+	if err != nil {
+		return nil, fmt.Errorf("decode head number: %w", err)
+	}
+	var canonical string
+	switch {
+	case number == block+1:
+		canonical = head.ParentHash
+	case number > block+1:
+		child, err := lookup(ctx, block+1)
+		if err != nil {
+			return nil, fmt.Errorf("block %d header: %w", block+1, err)
+		}
+		canonical = child.ParentHash
+	default:
+		header, err := lookup(ctx, block)
+		if err != nil {
+			return nil, fmt.Errorf("block %d header: %w", block, err)
+		}
+		canonical = header.Hash
+	}
+	if strings.EqualFold(canonical, hashes[len(hashes)-1].Hash) {
+		return nil, nil
+	}
+
+	fork := hashes[0].Block
+	if fork > 0 {
+		fork--
+	}
+	for i := len(hashes) - 2; i >= 0; i-- {
+		header, err := lookup(ctx, hashes[i].Block)
+		if err != nil {
+			return nil, fmt.Errorf("block %d header: %w", hashes[i].Block, err)
+		}
+		if strings.EqualFold(header.Hash, hashes[i].Hash) {
+			fork = hashes[i].Block
+			break
+		}
+	}
+	if err := c.Rewind(fork); err != nil {
+		return nil, err
+	}
+	return &Reorg{Fork: fork, Orphaned: block}, nil
+}
+
+func (c *Cursor) storeLocked(block uint64, hashes []Checkpoint) error {
+	if c.path != "" {
+		if err := write(c.path, document{Block: block, Hashes: hashes}); err != nil {
+			return fmt.Errorf("write cursor: %w", err)
+		}
+	}
+	c.block, c.hashes = block, hashes
+	return nil
+}
+
+func write(path string, doc document) error {
+	content, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(content, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,102 @@
+package cursor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"usdc-watch/internal/eth"
+)
+
+func TestCursorAdvanceAndReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cursor.json")
+	c, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	if c.Block() != 0 {
+		t.Fatalf("new cursor at block %d, want 0", c.Block())
+	}
+	if err := c.Advance(120, "0xAB"); err != nil {
+		t.Fatalf("Advance error: %v", err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("reopen error: %v", err)
+	}
+	if reopened.Block() != 120 || len(reopened.hashes) != 1 || reopened.hashes[0].Hash != "0xab" {
+		t.Fatalf("reopened cursor = %d %+v, want block 120 with its hash", reopened.Block(), reopened.hashes)
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected only the cursor file, got %v (%v)", entries, err)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Fatalf("expected error for a corrupt cursor")
+	}
+}
+
+// chain serves headers whose hashes change on branch for blocks after fork.
+type chain struct {
+	fork   uint64
+	branch string
+}
+
+func (c chain) hash(block uint64) string {
+	if c.branch != "" && block > c.fork {
+		return fmt.Sprintf("0x%s%d", c.branch, block)
+	}
+	return fmt.Sprintf("0x%d", block)
+}
+
+func (c chain) header(block uint64) eth.Header {
+	return eth.Header{Number: eth.FormatQuantity(block), Hash: c.hash(block), ParentHash: c.hash(block - 1)}
+}
+
+func (c chain) lookup(_ context.Context, block uint64) (eth.Header, error) {
+	return c.header(block), nil
+}
+
+func TestCursorVerify(t *testing.T) {
+	ctx := context.Background()
+	c := New()
+	canonical := chain{}
+	for _, block := range []uint64{100, 105, 110} {
+		if err := c.Advance(block, canonical.hash(block)); err != nil {
+			t.Fatalf("Advance error: %v", err)
+		}
+	}
+	for _, head := range []uint64{110, 111, 120} {
+		if reorg, err := c.Verify(ctx, canonical.header(head), canonical.lookup); err != nil || reorg != nil {
+			t.Fatalf("Verify at head %d = %+v, %v; want no reorg", head, reorg, err)
+		}
+	}
+
+	reorged := chain{fork: 107, branch: "b"}
+	reorg, err := c.Verify(ctx, reorged.header(111), reorged.lookup)
+	if err != nil {
+		t.Fatalf("Verify error: %v", err)
+	}
+	if reorg == nil || *reorg != (Reorg{Fork: 105, Orphaned: 110}) {
+		t.Fatalf("Verify = %+v, want fork 105 orphaning up to 110", reorg)
+	}
+	if c.Block() != 105 {
+		t.Fatalf("cursor rewound to %d, want 105", c.Block())
+	}
+	if reorg, err := c.Verify(ctx, reorged.header(106), reorged.lookup); err != nil || reorg != nil {
+		t.Fatalf("Verify after rewind = %+v, %v; want no reorg", reorg, err)
+	}
+
+	deep := chain{fork: 50, branch: "c"}
+	reorg, err = c.Verify(ctx, deep.header(106), deep.lookup)
+	if err != nil || reorg == nil || *reorg != (Reorg{Fork: 99, Orphaned: 105}) {
+		t.Fatalf("Verify past the retained hashes = %+v, %v; want fork 99", reorg, err)
+	}
+}
@@ -32,3 +32,24 @@ func TestAddressDataHex(t *testing.T) {
 		t.Fatalf("AddressDataHex = %s, expected %s", data, expected)
 	}
 }
+
+func TestAddressTopicRoundTrip(t *testing.T) {
+	topic, err := AddressTopic("0xABCDEFabcdefABCDEFabcdefABCDEFabcdefABCD")
+	if err != nil {
+		t.Fatalf("AddressTopic error: %v", err)
+	}
+	expected := "0x000000000000000000000000abcdefabcdefabcdefabcdefabcdefabcdefabcd"
+	if topic != expected {
+		t.Fatalf("AddressTopic = %s, expected %s", topic, expected)
+	}
+	addr, err := TopicAddress(topic)
+	if err != nil {
+		t.Fatalf("TopicAddress error: %v", err)
+	}
+	if addr != "0xabcdefabcdefabcdefabcdefabcdefabcdefabcd" {
+		t.Fatalf("TopicAddress = %s", addr)
+	}
+	if _, err := TopicAddress("0x1234"); err == nil {
+		t.Fatalf("expected error for short topic")
+	}
+}
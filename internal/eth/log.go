@@ -0,0 +1,44 @@
+package eth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Log is an event log entry as returned by eth_getLogs.
+type Log struct {
+	Address     string   `json:"address"`
+	Topics      []string `json:"topics"`
+	Data        string   `json:"data"`
+	BlockNumber string   `json:"blockNumber"`
+	BlockHash   string   `json:"blockHash"`
+	TxHash      string   `json:"transactionHash"`
+	LogIndex    string   `json:"logIndex"`
+	Removed     bool     `json:"removed"`
+}
+
+// AddressTopic left-pads an address to the 32-byte topic form used for indexed parameters.
+func AddressTopic(addr string) (string, error) {
+	data, err := AddressDataHex(addr)
+	if err != nil {
+		return "", err
+	}
+	return "0x" + strings.Repeat("0", 24) + data, nil
+}
+
+// TopicAddress extracts the address stored in the low 20 bytes of a 32-byte topic.
+func TopicAddress(topic string) (string, error) {
+	trimmed := strings.TrimPrefix(strings.ToLower(topic), "0x")
+	if len(trimmed) != 64 {
+		return "", fmt.Errorf("topic must be 64 hex characters, got %d", len(trimmed))
+	}
+	return NormalizeAddress(trimmed[24:])
+}
+
+// Header is the subset of block header fields used by the watcher.
+type Header struct {
+	Number     string `json:"number"`
+	Hash       string `json:"hash"`
+	ParentHash string `json:"parentHash"`
+	Timestamp  string `json:"timestamp"`
+}
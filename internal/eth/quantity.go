@@ -0,0 +1,28 @@
+package eth
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseQuantity decodes a JSON-RPC hex quantity such as "0x1b4" into a uint64.
+func ParseQuantity(value string) (uint64, error) {
+	if !strings.HasPrefix(value, "0x") && !strings.HasPrefix(value, "0X") {
+		return 0, fmt.Errorf("quantity missing 0x prefix: %s", value)
+	}
+	digits := value[2:]
+	if digits == "" {
+		return 0, fmt.Errorf("quantity has no digits: %s", value)
+	}
+	n, err := strconv.ParseUint(digits, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quantity %s: %w", value, err)
+	}
+	return n, nil
+}
+
+// FormatQuantity encodes n as a JSON-RPC hex quantity.
+func FormatQuantity(n uint64) string {
+	return "0x" + strconv.FormatUint(n, 16)
+}
@@ -0,0 +1,27 @@
+package eth
+
+import "testing"
+
+func TestParseQuantity(t *testing.T) {
+	n, err := ParseQuantity("0x1b4")
+	if err != nil {
+		t.Fatalf("ParseQuantity error: %v", err)
+	}
+	if n != 436 {
+		t.Fatalf("ParseQuantity = %d, expected 436", n)
+	}
+	for _, input := range []string{"", "0x", "1b4", "0xzz"} {
+		if _, err := ParseQuantity(input); err == nil {
+			t.Fatalf("ParseQuantity(%q) expected error", input)
+		}
+	}
+}
+
+func TestFormatQuantity(t *testing.T) {
+	if got := FormatQuantity(0); got != "0x0" {
+		t.Fatalf("FormatQuantity(0) = %s", got)
+	}
+	if got := FormatQuantity(436); got != "0x1b4" {
+		t.Fatalf("FormatQuantity(436) = %s", got)
+	}
+}
@@ -0,0 +1,154 @@
+package eth
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Transaction is a decoded eth_getTransactionByHash result.
+type Transaction struct {
+	Hash string
+	// Block is zero while the transaction is pending.
+	Block uint64
+	From  string
+	// To is the called account; empty for a contract creation.
+	To    string
+	Input string
+	Value *big.Int
+	Nonce uint64
+}
+
+// Selector returns the 4-byte method selector of the call data without the
+// 0x prefix, or "" when the input is shorter.
+func (t Transaction) Selector() string {
+	input := strings.TrimPrefix(t.Input, "0x")
+	if len(input) < 8 {
+		return ""
+	}
+	return strings.ToLower(input[:8])
+}
+
+// Receipt is a decoded eth_getTransactionReceipt result.
+type Receipt struct {
+	TxHash string
+	Block  uint64
+	From   string
+	To     string
+	// ContractAddress is the created contract; empty for calls.
+	ContractAddress   string
+	GasUsed           uint64
+	EffectiveGasPrice *big.Int
+	// Success reports status 0x1; failed transactions revert and emit no logs.
+	Success bool
+	Logs    []Log
+}
+
+type transactionJSON struct {
+	Hash        string  `json:"hash"`
+	BlockNumber *string `json:"blockNumber"`
+	From        string  `json:"from"`
+	To          *string `json:"to"`
+	Input       string  `json:"input"`
+	Value       string  `json:"value"`
+	Nonce       string  `json:"nonce"`
+}
+
+type receiptJSON struct {
+	TxHash            string  `json:"transactionHash"`
+	BlockNumber       string  `json:"blockNumber"`
+	From              string  `json:"from"`
+	To                *string `json:"to"`
+	ContractAddress   *string `json:"contractAddress"`
+	GasUsed           string  `json:"gasUsed"`
+	EffectiveGasPrice string  `json:"effectiveGasPrice"`
+	Status            string  `json:"status"`
+	Logs              []Log   `json:"logs"`
+}
+
+// DecodeTransaction decodes the JSON result of eth_getTransactionByHash.
+func DecodeTransaction(raw json.RawMessage) (Transaction, error) {
+	var body transactionJSON
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return Transaction{}, fmt.Errorf("decode transaction: %w", err)
+	}
+	tx := Transaction{Hash: strings.ToLower(body.Hash), Input: body.Input}
+	var err error
+	if body.BlockNumber != nil {
+		if tx.Block, err = ParseQuantity(*body.BlockNumber); err != nil {
+			return Transaction{}, fmt.Errorf("decode block: %w", err)
+		}
+	}
+	if tx.From, err = NormalizeAddress(body.From); err != nil {
+		return Transaction{}, fmt.Errorf("decode sender: %w", err)
+	}
+	if tx.To, err = optionalAddress(body.To); err != nil {
+		return Transaction{}, fmt.Errorf("decode recipient: %w", err)
+	}
+	if tx.Value, err = parseBigQuantity(body.Value); err != nil {
+		return Transaction{}, fmt.Errorf("decode value: %w", err)
+	}
+	if tx.Nonce, err = ParseQuantity(body.Nonce); err != nil {
+		return Transaction{}, fmt.Errorf("decode nonce: %w", err)
+	}
+	return tx, nil
+}
+
+// DecodeReceipt decodes the JSON result of eth_getTransactionReceipt.
+func DecodeReceipt(raw json.RawMessage) (Receipt, error) {
+	var body receiptJSON
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return Receipt{}, fmt.Errorf("decode receipt: %w", err)
+	}
+	receipt := Receipt{TxHash: strings.ToLower(body.TxHash), Logs: body.Logs}
+	var err error
+	if receipt.Block, err = ParseQuantity(body.BlockNumber); err != nil {
+		return Receipt{}, fmt.Errorf("decode block: %w", err)
+	}
+	if receipt.From, err = NormalizeAddress(body.From); err != nil {
+		return Receipt{}, fmt.Errorf("decode sender: %w", err)
+	}
+	if receipt.To, err = optionalAddress(body.To); err != nil {
+		return Receipt{}, fmt.Errorf("decode recipient: %w", err)
+	}
+	if receipt.ContractAddress, err = optionalAddress(body.ContractAddress); err != nil {
+		return Receipt{}, fmt.Errorf("decode contract address: %w", err)
+	}
+	if receipt.GasUsed, err = ParseQuantity(body.GasUsed); err != nil {
+		return Receipt{}, fmt.Errorf("decode gas used: %w", err)
+	}
+	if body.EffectiveGasPrice != "" {
+		if receipt.EffectiveGasPrice, err = parseBigQuantity(body.EffectiveGasPrice); err != nil {
+			return Receipt{}, fmt.Errorf("decode gas price: %w", err)
+		}
+	}
+	switch body.Status {
+	case "0x1":
+		receipt.Success = true
+	case "0x0":
+	default:
+		return Receipt{}, fmt.Errorf("unknown receipt status %q", body.Status)
+	}
+	return receipt, nil
+}
+
+// optionalAddress normalizes an address that JSON-RPC reports as null when absent.
+func optionalAddress(value *string) (string, error) {
+	if value == nil || *value == "" {
+		return "", nil
+	}
+	return NormalizeAddress(*value)
+}
+
+// parseBigQuantity decodes a hex quantity that may exceed 64 bits.
+func parseBigQuantity(value string) (*big.Int, error) {
+	if !strings.HasPrefix(value, "0x") && !strings.HasPrefix(value, "0X") {
+		return nil, fmt.Errorf("quantity missing 0x prefix: %s", value)
+	}
+	n, ok := new(big.Int).SetString(value[2:], 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid quantity %s", value)
+	}
+	return n, nil
+}
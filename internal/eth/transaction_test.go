@@ -0,0 +1,50 @@
+package eth
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecodeTransaction(t *testing.T) {
+	raw := json.RawMessage(`{"hash":"0xABC","blockNumber":"0x10","from":"0x00000000000000000000000000000000000000A1","to":"0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48","input":"0xA9059CBB0000","value":"0xde0b6b3a7640000","nonce":"0x7"}`)
+	tx, err := DecodeTransaction(raw)
+	if err != nil {
+		t.Fatalf("DecodeTransaction error: %v", err)
+	}
+	if tx.Hash != "0xabc" || tx.Block != 16 || tx.From != "0x00000000000000000000000000000000000000a1" || tx.Nonce != 7 {
+		t.Fatalf("unexpected transaction: %+v", tx)
+	}
+	if tx.Value.String() != "1000000000000000000" || tx.Selector() != "a9059cbb" {
+		t.Fatalf("value %s, selector %q", tx.Value, tx.Selector())
+	}
+
+	pending, err := DecodeTransaction(json.RawMessage(`{"hash":"0x1","blockNumber":null,"from":"0x00000000000000000000000000000000000000a1","to":null,"input":"0x","value":"0x0","nonce":"0x0"}`))
+	if err != nil || pending.Block != 0 || pending.To != "" || pending.Selector() != "" {
+		t.Fatalf("pending creation = %+v, %v", pending, err)
+	}
+	if _, err := DecodeTransaction(json.RawMessage(`{"from":"nobody"}`)); err == nil {
+		t.Fatalf("expected error for an invalid sender")
+	}
+}
+
+func TestDecodeReceipt(t *testing.T) {
+	raw := json.RawMessage(`{"transactionHash":"0xABC","blockNumber":"0x10","from":"0x00000000000000000000000000000000000000a1","to":"0x00000000000000000000000000000000000000c1","contractAddress":null,"gasUsed":"0xfde8","effectiveGasPrice":"0x3b9aca00","status":"0x1","logs":[{"logIndex":"0x0"}]}`)
+	receipt, err := DecodeReceipt(raw)
+	if err != nil {
+		t.Fatalf("DecodeReceipt error: %v", err)
+	}
+	if receipt.TxHash != "0xabc" || receipt.Block != 16 || receipt.To != "0x00000000000000000000000000000000000000c1" || receipt.ContractAddress != "" {
+		t.Fatalf("unexpected receipt: %+v", receipt)
+	}
+	if !receipt.Success || receipt.GasUsed != 65000 || receipt.EffectiveGasPrice.Int64() != 1_000_000_000 || len(receipt.Logs) != 1 {
+		t.Fatalf("unexpected receipt outcome: %+v", receipt)
+	}
+
+	failed, err := DecodeReceipt(json.RawMessage(`{"blockNumber":"0x1","from":"0x00000000000000000000000000000000000000a1","gasUsed":"0x5208","status":"0x0"}`))
+	if err != nil || failed.Success {
+		t.Fatalf("failed receipt = %+v, %v", failed, err)
+	}
+	if _, err := DecodeReceipt(json.RawMessage(`{"blockNumber":"0x1","from":"0x00000000000000000000000000000000000000a1","gasUsed":"0x1"}`)); err == nil {
+		t.Fatalf("expected error for a receipt without status")
+	}
+}
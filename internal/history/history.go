@@ -0,0 +1,618 @@
+// Package history persists balance observations, transfers and alerts to an
+// append-only, day-segmented time series on local disk and answers range
+// queries over it.
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	segmentLayout = "2006-01-02"
+	segmentSuffix = ".jsonl"
+)
+
+// Record kinds stored in a segment. Balance records omit the kind field.
+const (
+	kindBalance  = ""
+	kindTransfer = "transfer"
+	kindAlert    = "alert"
+	kindRevert   = "revert"
+)
+
+// Point is a single balance observation.
+type Point struct {
+	Time     time.Time
+	Address  string
+	Block    uint64
+	Balance  *big.Int
+	Endpoint string
+}
+
+// Transfer is a USDC transfer involving a watched address.
+type Transfer struct {
+	Time     time.Time
+	Address  string
+	Block    uint64
+	TxHash   string
+	LogIndex uint64
+	From     string
+	To       string
+	Value    *big.Int
+}
+
+// Incoming reports whether the transfer credits the watched address.
+func (t Transfer) Incoming() bool {
+	return t.To == t.Address
+}
+
+// Alert is an alert raised for a watched address.
+type Alert struct {
+	Time    time.Time
+	Address string
+	Rule    string
+	Block   uint64
+	Balance *big.Int
+	Message string
+}
+
+// Revert marks the balances and transfers recorded up to Time for Block and
+// later as orphaned by a chain reorganisation. Queries no longer return them;
+// alerts are kept as they were sent.
+type Revert struct {
+	Time  time.Time
+	Block uint64
+}
+
+// record is the on-disk JSON representation shared by every kind.
+type record struct {
+	Kind     string    `json:"kind,omitempty"`
+	Time     time.Time `json:"ts"`
+	Address  string    `json:"address"`
+	Block    uint64    `json:"block"`
+	Balance  string    `json:"balance_raw,omitempty"`
+	Endpoint string    `json:"endpoint,omitempty"`
+	TxHash   string    `json:"tx,omitempty"`
+	LogIndex uint64    `json:"log_index,omitempty"`
+	From     string    `json:"from,omitempty"`
+	To       string    `json:"to,omitempty"`
+	Value    string    `json:"value_raw,omitempty"`
+	Rule     string    `json:"rule,omitempty"`
+	Message  string    `json:"message,omitempty"`
+}
+
+// Store appends records to one file per UTC day under a directory.
+type Store struct {
+	dir       string
+	retention time.Duration
+
+	mu   sync.Mutex
+	file *os.File
+	day  string
+}
+
+// Open prepares dir for use as a series store. A zero retention keeps segments forever.
+func Open(dir string, retention time.Duration) (*Store, error) {
+	if retention < 0 {
+		return nil, fmt.Errorf("retention cannot be negative")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create history dir: %w", err)
+	}
+	return &Store{dir: dir, retention: retention}, nil
+}
+
+// Append writes a balance observation to the segment for its UTC day.
+func (s *Store) Append(p Point) error {
+	if p.Balance == nil {
+		return errors.New("point has no balance")
+	}
+	return s.appendRecord(record{
+		Time:     p.Time.UTC(),
+		Address:  p.Address,
+		Block:    p.Block,
+		Balance:  p.Balance.String(),
+		Endpoint: p.Endpoint,
+	})
+}
+
+// AppendTransfer writes a transfer to the segment for its UTC day.
+func (s *Store) AppendTransfer(t Transfer) error {
+	if t.Value == nil {
+		return errors.New("transfer has no value")
+	}
+	return s.appendRecord(record{
+		Kind:     kindTransfer,
+		Time:     t.Time.UTC(),
+		Address:  t.Address,
+		Block:    t.Block,
+		TxHash:   t.TxHash,
+		LogIndex: t.LogIndex,
+		From:     t.From,
+		To:       t.To,
+		Value:    t.Value.String(),
+	})
+}
+
+// AppendAlert writes an alert occurrence to the segment for its UTC day.
+func (s *Store) AppendAlert(a Alert) error {
+	rec := record{
+		Kind:    kindAlert,
+		Time:    a.Time.UTC(),
+		Address: a.Address,
+		Block:   a.Block,
+		Rule:    a.Rule,
+		Message: a.Message,
+	}
+	if a.Balance != nil {
+		rec.Balance = a.Balance.String()
+	}
+	return s.appendRecord(rec)
+}
+
+// AppendRevert writes a revert to the segment for its UTC day.
+func (s *Store) AppendRevert(r Revert) error {
+	return s.appendRecord(record{Kind: kindRevert, Time: r.Time.UTC(), Block: r.Block})
+}
+
+func (s *Store) appendRecord(rec record) error {
+	line, err := encodeRecord(rec)
+	if err != nil {
+		return fmt.Errorf("encode record: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	day := rec.Time.Format(segmentLayout)
+	if s.file == nil || s.day != day {
+		if s.file != nil {
+			s.file.Close()
+			s.file = nil
+		}
+		f, err := os.OpenFile(s.segmentPath(day), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("open segment: %w", err)
+		}
+		s.file = f
+		s.day = day
+	}
+	if _, err := s.file.Write(line); err != nil {
+		return fmt.Errorf("append record: %w", err)
+	}
+	return nil
+}
+
+// Query returns balance points for address within [from, to], oldest first.
+// An empty address matches every address.
+func (s *Store) Query(address string, from, to time.Time) ([]Point, error) {
+	var out []Point
+	err := s.scan(kindBalance, address, from, to, func(rec record) error {
+		p, err := rec.point()
+		if err != nil {
+			return err
+		}
+		out = append(out, p)
+		return nil
+	})
+	return out, err
+}
+
+// Transfers returns transfers recorded for address within [from, to], oldest first.
+// An empty address matches every address.
+func (s *Store) Transfers(address string, from, to time.Time) ([]Transfer, error) {
+	var out []Transfer
+	err := s.scan(kindTransfer, address, from, to, func(rec record) error {
+		t, err := rec.transfer()
+		if err != nil {
+			return err
+		}
+		out = append(out, t)
+		return nil
+	})
+	return out, err
+}
+
+// Alerts returns alerts recorded for address within [from, to], oldest first.
+// An empty address matches every address.
+func (s *Store) Alerts(address string, from, to time.Time) ([]Alert, error) {
+	var out []Alert
+	err := s.scan(kindAlert, address, from, to, func(rec record) error {
+		a, err := rec.alert()
+		if err != nil {
+			return err
+		}
+		out = append(out, a)
+		return nil
+	})
+	return out, err
+}
+
+// Latest returns the most recent balance point for address at or before t
+// that has not been reverted. The boolean is false when no such point exists.
+func (s *Store) Latest(address string, t time.Time) (Point, bool, error) {
+	days, err := s.segments()
+	if err != nil {
+		return Point{}, false, err
+	}
+	lastDay := t.UTC().Format(segmentLayout)
+	var reverts []record
+	for i := len(days) - 1; i >= 0; i-- {
+		records, err := readSegment(s.segmentPath(days[i]))
+		if err != nil {
+			return Point{}, false, err
+		}
+		for j := len(records) - 1; j >= 0; j-- {
+			rec := records[j]
+			if rec.Kind == kindRevert {
+				reverts = append(reverts, rec)
+				continue
+			}
+			if days[i] > lastDay || rec.Kind != kindBalance || rec.Address != address || rec.Time.After(t) || reverted(rec, reverts) {
+				continue
+			}
+			p, err := rec.point()
+			return p, err == nil, err
+		}
+	}
+	return Point{}, false, nil
+}
+
+// Maintain compacts closed segments and removes those past the retention window.
+// Segments for the UTC day containing now are never modified.
+func (s *Store) Maintain(now time.Time) error {
+	days, err := s.segments()
+	if err != nil {
+		return err
+	}
+	today := now.UTC().Format(segmentLayout)
+	cutoff := ""
+	if s.retention > 0 {
+		cutoff = now.Add(-s.retention).UTC().Format(segmentLayout)
+	}
+	for _, day := range days {
+		if day >= today {
+			continue
+		}
+		if err := s.maintainSegment(day, cutoff != "" && day < cutoff); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maintainSegment removes or compacts the segment for day. It holds the lock
+// so no record is appended meanwhile, as a backfill may write to past days,
+// and closes the segment if it is the open one; the next append reopens it.
+func (s *Store) maintainSegment(day string, expired bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file != nil && s.day == day {
+		err := s.file.Close()
+		s.file, s.day = nil, ""
+		if err != nil {
+			return fmt.Errorf("close segment %s: %w", day, err)
+		}
+	}
+	if expired {
+		if err := os.Remove(s.segmentPath(day)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("remove segment %s: %w", day, err)
+		}
+		return nil
+	}
+	if err := compactSegment(s.segmentPath(day)); err != nil {
+		return fmt.Errorf("compact segment %s: %w", day, err)
+	}
+	return nil
+}
+
+// Close releases the open segment.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (s *Store) segmentPath(day string) string {
+	return filepath.Join(s.dir, day+segmentSuffix)
+}
+
+// segments lists the segment days present on disk in ascending order.
+func (s *Store) segments() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("list history dir: %w", err)
+	}
+	var days []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		day := strings.TrimSuffix(name, segmentSuffix)
+		if _, err := time.Parse(segmentLayout, day); err != nil {
+			continue
+		}
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	return days, nil
+}
+
+// scan calls fn for every record of kind matching address within [from, to],
+// oldest first. Balances and transfers that a later revert orphaned are
+// skipped, so their segments are read up to the newest one, and so are
+// repeats of one already passed to fn, such as those of a re-run backfill.
+func (s *Store) scan(kind, address string, from, to time.Time, fn func(record) error) error {
+	days, err := s.segments()
+	if err != nil {
+		return err
+	}
+	firstDay := from.UTC().Format(segmentLayout)
+	lastDay := to.UTC().Format(segmentLayout)
+	var matched, reverts []record
+	for _, day := range days {
+		if day < firstDay || (day > lastDay && kind == kindAlert) {
+			continue
+		}
+		records, err := readSegment(s.segmentPath(day))
+		if err != nil {
+			return err
+		}
+		for _, rec := range records {
+			if rec.Kind == kindRevert {
+				reverts = append(reverts, rec)
+				continue
+			}
+			if day > lastDay || rec.Kind != kind || (address != "" && rec.Address != address) {
+				continue
+			}
+			if rec.Time.Before(from) || rec.Time.After(to) {
+				continue
+			}
+			matched = append(matched, rec)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].Time.Before(matched[j].Time) })
+	seen := make(map[string]bool)
+	for _, rec := range matched {
+		if kind != kindAlert && reverted(rec, reverts) {
+			continue
+		}
+		if key := rec.key(); key != "" {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// key identifies the observation rec records, so a repeat can be dropped: a
+// balance by address and block, a transfer by address and log. Alerts and
+// reverts have no key, as each occurrence counts, and neither has a record
+// without a block.
+func (rec record) key() string {
+	if rec.Block == 0 {
+		return ""
+	}
+	switch rec.Kind {
+	case kindBalance:
+		return fmt.Sprintf("balance %s %d", rec.Address, rec.Block)
+	case kindTransfer:
+		return fmt.Sprintf("transfer %s %d %s %d", rec.Address, rec.Block, rec.TxHash, rec.LogIndex)
+	}
+	return ""
+}
+
+// reverted reports whether one of reverts orphaned rec.
+func reverted(rec record, reverts []record) bool {
+	for _, r := range reverts {
+		if rec.Block >= r.Block && !rec.Time.After(r.Time) {
+			return true
+		}
+	}
+	return false
+}
+
+// readSegment decodes every record in a segment. A torn final line left by an
+// interrupted write is ignored.
+func readSegment(path string) ([]record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("open segment: %w", err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var records []record
+	for lineNo := 1; ; lineNo++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("read segment: %w", err)
+		}
+		complete := err == nil
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			var rec record
+			if decodeErr := json.Unmarshal(line, &rec); decodeErr != nil {
+				if !complete {
+					break
+				}
+				return nil, fmt.Errorf("%s line %d: %w", filepath.Base(path), lineNo, decodeErr)
+			}
+			records = append(records, rec)
+		}
+		if !complete {
+			break
+		}
+	}
+	return records, nil
+}
+
+// encodeRecord renders rec as a newline-terminated JSON line.
+func encodeRecord(rec record) ([]byte, error) {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+func (rec record) point() (Point, error) {
+	balance, err := parseAmount(rec.Balance)
+	if err != nil {
+		return Point{}, err
+	}
+	return Point{
+		Time:     rec.Time,
+		Address:  rec.Address,
+		Block:    rec.Block,
+		Balance:  balance,
+		Endpoint: rec.Endpoint,
+	}, nil
+}
+
+func (rec record) transfer() (Transfer, error) {
+	value, err := parseAmount(rec.Value)
+	if err != nil {
+		return Transfer{}, err
+	}
+	return Transfer{
+		Time:     rec.Time,
+		Address:  rec.Address,
+		Block:    rec.Block,
+		TxHash:   rec.TxHash,
+		LogIndex: rec.LogIndex,
+		From:     rec.From,
+		To:       rec.To,
+		Value:    value,
+	}, nil
+}
+
+func (rec record) alert() (Alert, error) {
+	a := Alert{
+		Time:    rec.Time,
+		Address: rec.Address,
+		Rule:    rec.Rule,
+		Block:   rec.Block,
+		Message: rec.Message,
+	}
+	if rec.Balance != "" {
+		balance, err := parseAmount(rec.Balance)
+		if err != nil {
+			return Alert{}, err
+		}
+		a.Balance = balance
+	}
+	return a, nil
+}
+
+func parseAmount(raw string) (*big.Int, error) {
+	amount, ok := new(big.Int).SetString(raw, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %q", raw)
+	}
+	return amount, nil
+}
+
+// compactSegment drops balance records that repeat the previous balance of the
+// same address, keeping each address's first and last balance in the segment,
+// and repeated balances and transfers of the same block. A repeat following a
+// revert is kept when the revert may have orphaned the first. Alert records
+// are always kept.
+func compactSegment(path string) error {
+	records, err := readSegment(path)
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	unique := make([]record, 0, len(records))
+	for _, rec := range records {
+		if rec.Kind == kindRevert {
+			clear(seen)
+		}
+		if key := rec.key(); key != "" {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		unique = append(unique, rec)
+	}
+	last := make(map[string]int)
+	for i, rec := range unique {
+		if rec.Kind == kindBalance {
+			last[rec.Address] = i
+		}
+	}
+	prev := make(map[string]record)
+	kept := make([]record, 0, len(unique))
+	for i, rec := range unique {
+		switch rec.Kind {
+		case kindRevert:
+			// A balance the revert orphans is no longer the previous one.
+			for address, before := range prev {
+				if before.Block >= rec.Block {
+					delete(prev, address)
+				}
+			}
+		case kindBalance:
+			before, seen := prev[rec.Address]
+			prev[rec.Address] = rec
+			if seen && before.Balance == rec.Balance && last[rec.Address] != i {
+				continue
+			}
+		}
+		kept = append(kept, rec)
+	}
+	if len(kept) == len(records) {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".compact-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	for _, rec := range kept {
+		line, err := encodeRecord(rec)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		w.Write(line)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,276 @@
+package history
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAppendAndQuery(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir, 0)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer store.Close()
+
+	base := time.Date(2026, 10, 17, 23, 58, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		p := Point{Time: base.Add(time.Duration(i) * time.Minute), Address: "0xa", Block: uint64(100 + i), Balance: big.NewInt(int64(i)), Endpoint: "primary"}
+		if err := store.Append(p); err != nil {
+			t.Fatalf("Append error: %v", err)
+		}
+	}
+	if err := store.Append(Point{Time: base, Address: "0xb", Balance: big.NewInt(7)}); err != nil {
+		t.Fatalf("Append error: %v", err)
+	}
+
+	for _, name := range []string{"2026-10-17.jsonl", "2026-10-18.jsonl"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("expected segment %s: %v", name, err)
+		}
+	}
+
+	points, err := store.Query("0xa", base.Add(time.Minute), base.Add(2*time.Minute))
+	if err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	if len(points) != 2 || points[0].Block != 101 || points[1].Balance.Int64() != 2 {
+		t.Fatalf("unexpected query result: %+v", points)
+	}
+
+	latest, ok, err := store.Latest("0xb", base.Add(time.Hour))
+	if err != nil || !ok || latest.Balance.Int64() != 7 {
+		t.Fatalf("Latest = %+v, %v, %v", latest, ok, err)
+	}
+}
+
+func TestQueryIgnoresTornLine(t *testing.T) {
+	dir := t.TempDir()
+	content := `{"ts":"2026-10-17T10:00:00Z","address":"0xa","block":1,"balance_raw":"5","endpoint":"e"}
+{"ts":"2026-10-17T10:01:00Z","addr`
+	if err := os.WriteFile(filepath.Join(dir, "2026-10-17.jsonl"), []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+	store, err := Open(dir, 0)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	points, err := store.Query("", time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	if len(points) != 1 {
+		t.Fatalf("expected torn line to be skipped, got %d points", len(points))
+	}
+}
+
+func TestMaintainCompactsAndPrunes(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir, 48*time.Hour)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer store.Close()
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	old := now.AddDate(0, 0, -5)
+	yesterday := now.AddDate(0, 0, -1)
+	balances := []int64{1, 1, 1, 2, 2}
+	for i, b := range balances {
+		store.Append(Point{Time: yesterday.Add(time.Duration(i) * time.Minute), Address: "0xa", Balance: big.NewInt(b)})
+	}
+	store.Append(Point{Time: old, Address: "0xa", Balance: big.NewInt(9)})
+	store.Append(Point{Time: now, Address: "0xa", Balance: big.NewInt(2)})
+	store.Append(Point{Time: now.Add(time.Minute), Address: "0xa", Balance: big.NewInt(2)})
+
+	if err := store.Maintain(now); err != nil {
+		t.Fatalf("Maintain error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, old.Format("2006-01-02")+".jsonl")); !os.IsNotExist(err) {
+		t.Fatalf("expected expired segment to be removed, stat err: %v", err)
+	}
+
+	points, err := store.Query("0xa", yesterday.Add(-time.Hour), yesterday.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	var got []int64
+	for _, p := range points {
+		got = append(got, p.Balance.Int64())
+	}
+	if len(got) != 3 || got[0] != 1 || got[1] != 2 || got[2] != 2 {
+		t.Fatalf("unexpected compacted balances: %v", got)
+	}
+
+	today, err := store.Query("0xa", now, now.Add(time.Hour))
+	if err != nil || len(today) != 2 {
+		t.Fatalf("current segment must not be compacted: %d points, err %v", len(today), err)
+	}
+}
+
+func TestTransfersAndAlerts(t *testing.T) {
+	store, err := Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer store.Close()
+
+	at := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	store.Append(Point{Time: at, Address: "0xa", Balance: big.NewInt(1)})
+	store.AppendTransfer(Transfer{Time: at, Address: "0xa", Block: 5, TxHash: "0x01", From: "0xb", To: "0xa", Value: big.NewInt(3)})
+	store.AppendAlert(Alert{Time: at, Address: "0xa", Rule: "threshold", Balance: big.NewInt(4), Message: "hi"})
+
+	day := [2]time.Time{at.Add(-time.Hour), at.Add(time.Hour)}
+	transfers, err := store.Transfers("0xa", day[0], day[1])
+	if err != nil || len(transfers) != 1 {
+		t.Fatalf("Transfers = %+v, %v", transfers, err)
+	}
+	if !transfers[0].Incoming() || transfers[0].Value.Int64() != 3 {
+		t.Fatalf("unexpected transfer: %+v", transfers[0])
+	}
+	alerts, err := store.Alerts("", day[0], day[1])
+	if err != nil || len(alerts) != 1 || alerts[0].Rule != "threshold" || alerts[0].Balance.Int64() != 4 {
+		t.Fatalf("Alerts = %+v, %v", alerts, err)
+	}
+	points, err := store.Query("0xa", day[0], day[1])
+	if err != nil || len(points) != 1 {
+		t.Fatalf("Query should only return balance points, got %+v, %v", points, err)
+	}
+}
+
+func TestRevertHidesOrphanedRecords(t *testing.T) {
+	store, err := Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer store.Close()
+
+	at := time.Date(2026, 10, 17, 23, 59, 0, 0, time.UTC)
+	store.Append(Point{Time: at, Address: "0xa", Block: 10, Balance: big.NewInt(1)})
+	store.Append(Point{Time: at.Add(10 * time.Second), Address: "0xa", Block: 12, Balance: big.NewInt(2)})
+	store.AppendTransfer(Transfer{Time: at.Add(10 * time.Second), Address: "0xa", Block: 11, TxHash: "0x01", From: "0xb", To: "0xa", Value: big.NewInt(1)})
+	store.AppendAlert(Alert{Time: at.Add(10 * time.Second), Address: "0xa", Rule: "threshold", Block: 12, Message: "hi"})
+	// The revert lands in the next day's segment; a later point on the new
+	// branch is kept.
+	store.AppendRevert(Revert{Time: at.Add(2 * time.Minute), Block: 11})
+	store.Append(Point{Time: at.Add(3 * time.Minute), Address: "0xa", Block: 12, Balance: big.NewInt(3)})
+
+	points, err := store.Query("0xa", at, at.Add(30*time.Second))
+	if err != nil || len(points) != 1 || points[0].Block != 10 {
+		t.Fatalf("Query = %+v, %v; want only block 10", points, err)
+	}
+	if transfers, err := store.Transfers("0xa", at, at.Add(30*time.Second)); err != nil || len(transfers) != 0 {
+		t.Fatalf("Transfers = %+v, %v; want none", transfers, err)
+	}
+	if alerts, err := store.Alerts("0xa", at, at.Add(30*time.Second)); err != nil || len(alerts) != 1 {
+		t.Fatalf("Alerts = %+v, %v; want the alert kept", alerts, err)
+	}
+	if latest, ok, err := store.Latest("0xa", at.Add(time.Minute)); err != nil || !ok || latest.Block != 10 {
+		t.Fatalf("Latest = %+v, %v, %v; want block 10", latest, ok, err)
+	}
+	if latest, ok, err := store.Latest("0xa", at.Add(time.Hour)); err != nil || !ok || latest.Balance.Int64() != 3 {
+		t.Fatalf("Latest = %+v, %v, %v; want the new branch", latest, ok, err)
+	}
+}
+
+func TestMaintainReopensOpenSegment(t *testing.T) {
+	store, err := Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer store.Close()
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	yesterday := now.AddDate(0, 0, -1)
+	for i := 0; i < 3; i++ {
+		store.Append(Point{Time: yesterday.Add(time.Duration(i) * time.Minute), Address: "0xa", Block: uint64(10 + i), Balance: big.NewInt(1)})
+	}
+	if err := store.Maintain(now); err != nil {
+		t.Fatalf("Maintain error: %v", err)
+	}
+	// A backfill still writing to yesterday must not append to the segment
+	// compaction replaced.
+	store.Append(Point{Time: yesterday.Add(time.Hour), Address: "0xa", Block: 20, Balance: big.NewInt(2)})
+
+	points, err := store.Query("0xa", yesterday, now)
+	if err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	if len(points) != 3 || points[2].Block != 20 {
+		t.Fatalf("Query = %+v; want blocks 10, 12 and 20", points)
+	}
+}
+
+func TestRepeatedRecordsAreDropped(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir, 0)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer store.Close()
+
+	at := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	// A backfill run twice over the same blocks.
+	for run := 0; run < 2; run++ {
+		store.Append(Point{Time: at, Address: "0xa", Block: 10, Balance: big.NewInt(1)})
+		store.Append(Point{Time: at.Add(time.Minute), Address: "0xa", Block: 15, Balance: big.NewInt(4)})
+		store.AppendTransfer(Transfer{Time: at.Add(time.Minute), Address: "0xa", Block: 15, TxHash: "0x01", From: "0xb", To: "0xa", Value: big.NewInt(3)})
+	}
+
+	check := func(stage string) {
+		t.Helper()
+		points, err := store.Query("0xa", at, at.Add(time.Hour))
+		if err != nil || len(points) != 2 || points[0].Block != 10 || points[1].Block != 15 {
+			t.Fatalf("%s: Query = %+v, %v; want blocks 10 and 15 once", stage, points, err)
+		}
+		transfers, err := store.Transfers("0xa", at, at.Add(time.Hour))
+		if err != nil || len(transfers) != 1 {
+			t.Fatalf("%s: Transfers = %+v, %v; want one", stage, transfers, err)
+		}
+	}
+	check("before compaction")
+	if err := store.Maintain(at.AddDate(0, 0, 1)); err != nil {
+		t.Fatalf("Maintain error: %v", err)
+	}
+	check("after compaction")
+	records, err := readSegment(filepath.Join(dir, "2026-10-17.jsonl"))
+	if err != nil || len(records) != 3 {
+		t.Fatalf("compacted segment has %d records, err %v; want 3", len(records), err)
+	}
+}
+
+func TestCompactionKeepsBalanceAfterRevert(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir, 0)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer store.Close()
+
+	at := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	store.Append(Point{Time: at, Address: "0xa", Block: 10, Balance: big.NewInt(1)})
+	store.Append(Point{Time: at.Add(time.Minute), Address: "0xa", Block: 12, Balance: big.NewInt(5)})
+	store.AppendRevert(Revert{Time: at.Add(2 * time.Minute), Block: 11})
+	// The new branch reaches the same balance as the orphaned block.
+	store.Append(Point{Time: at.Add(3 * time.Minute), Address: "0xa", Block: 12, Balance: big.NewInt(5)})
+	store.Append(Point{Time: at.Add(4 * time.Minute), Address: "0xa", Block: 13, Balance: big.NewInt(7)})
+
+	if err := store.Maintain(at.AddDate(0, 0, 1)); err != nil {
+		t.Fatalf("Maintain error: %v", err)
+	}
+	points, err := store.Query("0xa", at, at.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	var blocks []uint64
+	for _, p := range points {
+		blocks = append(blocks, p.Block)
+	}
+	if !reflect.DeepEqual(blocks, []uint64{10, 12, 13}) {
+		t.Fatalf("compacted blocks = %v, want [10 12 13]", blocks)
+	}
+}
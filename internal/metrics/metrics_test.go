@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	reg := NewRegistry()
+	requests := reg.NewCounterVec("rpc_requests_total", "Requests sent.", "endpoint", "method")
+	balance := reg.NewGaugeVec("balance", "Current balance.", "address")
+	latency := reg.NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "endpoint")
+
+	requests.Inc("b", "eth_call")
+	requests.Add(2, "a", "eth_call")
+	balance.Set(1.5, `quote"d`)
+	latency.Observe(0.05, "a")
+	latency.Observe(0.5, "a")
+	latency.Observe(3, "a")
+
+	var out strings.Builder
+	if err := reg.WriteText(&out); err != nil {
+		t.Fatalf("WriteText error: %v", err)
+	}
+	expected := `# HELP rpc_requests_total Requests sent.
+# TYPE rpc_requests_total counter
+rpc_requests_total{endpoint="a",method="eth_call"} 2
+rpc_requests_total{endpoint="b",method="eth_call"} 1
+# HELP balance Current balance.
+# TYPE balance gauge
+balance{address="quote\"d"} 1.5
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{endpoint="a",le="0.1"} 1
+latency_seconds_bucket{endpoint="a",le="1"} 2
+latency_seconds_bucket{endpoint="a",le="+Inf"} 3
+latency_seconds_sum{endpoint="a"} 3.55
+latency_seconds_count{endpoint="a"} 3
+`
+	if out.String() != expected {
+		t.Fatalf("WriteText mismatch:\n%s\nexpected:\n%s", out.String(), expected)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewGaugeVec("up", "Whether the watcher is running.").Set(1)
+
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected HTTP 200, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "up 1\n") {
+		t.Fatalf("unexpected body: %s", rec.Body.String())
+	}
+}
+
+func TestDuplicateRegistrationPanics(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewCounterVec("dup", "first")
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic on duplicate registration")
+		}
+	}()
+	reg.NewGaugeVec("dup", "second")
+}
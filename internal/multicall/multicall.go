@@ -0,0 +1,347 @@
+// Package multicall batches many eth_calls into Multicall3 aggregate3 calls,
+// so hundreds of balances cost a handful of requests on providers without
+// JSON-RPC batching. On chains without a Multicall3 deployment it falls back
+// to one eth_call per sub-call.
+package multicall
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/eth"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/usdc"
+)
+
+// Address is where Multicall3 is deployed, at the same address on Ethereum
+// and most other EVM chains.
+const Address = "0xcA11bde05977b3631167028862bE2a173976CA11"
+
+// DefaultBatchSize bounds the sub-calls per aggregate3 call, keeping each
+// within the gas and response size limits of common providers.
+const DefaultBatchSize = 500
+
+// methodAggregate3 is the selector of aggregate3((address,bool,bytes)[]).
+const methodAggregate3 = "82ad56cb"
+
+// Call is one sub-call: the hex call data sent to Target.
+type Call struct {
+	Target string
+	Data   string
+}
+
+// Result is the outcome of one sub-call. Data holds the hex return data, or
+// the revert data when Success is false. Err holds why a call sent on its own
+// failed.
+type Result struct {
+	Success bool
+	Data    string
+	Err     error
+}
+
+// EncodeAggregate3 builds the call data of an aggregate3 call running calls
+// in order. Every sub-call may fail without reverting the others.
+func EncodeAggregate3(calls []Call) (string, error) {
+	var offsets, tuples strings.Builder
+	offset := 32 * len(calls)
+	for i, call := range calls {
+		target, err := eth.AddressDataHex(call.Target)
+		if err != nil {
+			return "", fmt.Errorf("call %d: encode target: %w", i, err)
+		}
+		data := strings.ToLower(strings.TrimPrefix(call.Data, "0x"))
+		if len(data)%2 != 0 || !isHex(data) {
+			return "", fmt.Errorf("call %d: invalid call data %q", i, call.Data)
+		}
+		tuple := word(target) + word("1") + uintWord(3*32) + uintWord(len(data)/2) + pad(data)
+		offsets.WriteString(uintWord(offset))
+		tuples.WriteString(tuple)
+		offset += len(tuple) / 2
+	}
+	return "0x" + methodAggregate3 + uintWord(32) + uintWord(len(calls)) + offsets.String() + tuples.String(), nil
+}
+
+// DecodeAggregate3 decodes the (bool success, bytes returnData)[] returned by
+// aggregate3.
+func DecodeAggregate3(data string) ([]Result, error) {
+	d := abiData(strings.ToLower(strings.TrimPrefix(data, "0x")))
+	if !isHex(string(d)) {
+		return nil, fmt.Errorf("invalid hex result")
+	}
+	array, err := d.uint(0)
+	if err != nil {
+		return nil, fmt.Errorf("decode array offset: %w", err)
+	}
+	count, err := d.uint(array)
+	if err != nil {
+		return nil, fmt.Errorf("decode array length: %w", err)
+	}
+	elements := array + 32
+	if count > uint64(len(d))/64 {
+		return nil, fmt.Errorf("array length %d exceeds the result", count)
+	}
+	results := make([]Result, 0, count)
+	for i := uint64(0); i < count; i++ {
+		offset, err := d.uint(elements + 32*i)
+		if err != nil {
+			return nil, fmt.Errorf("result %d: decode offset: %w", i, err)
+		}
+		tuple := elements + offset
+		success, err := d.uint(tuple)
+		if err != nil || success > 1 {
+			return nil, fmt.Errorf("result %d: invalid success flag", i)
+		}
+		bytesOffset, err := d.uint(tuple + 32)
+		if err != nil {
+			return nil, fmt.Errorf("result %d: decode data offset: %w", i, err)
+		}
+		returned, err := d.bytes(tuple + bytesOffset)
+		if err != nil {
+			return nil, fmt.Errorf("result %d: decode data: %w", i, err)
+		}
+		results = append(results, Result{Success: success == 1, Data: "0x" + returned})
+	}
+	return results, nil
+}
+
+// Options configures a Caller.
+type Options struct {
+	// Address is the Multicall3 contract; Address when empty.
+	Address string
+	// BatchSize bounds the sub-calls per aggregate3 call; DefaultBatchSize
+	// when zero.
+	BatchSize int
+	// Code, when set, is Multicall3 runtime bytecode placed at Address with an
+	// eth_call state override, so chains without a deployment aggregate too.
+	Code string
+}
+
+// Caller runs sub-calls through Multicall3 over an rpc.Client.
+type Caller struct {
+	client *rpc.Client
+	opts   Options
+
+	// deployed caches whether code was found at the Multicall3 address; nil
+	// until first checked.
+	mu       sync.Mutex
+	deployed *bool
+}
+
+// New returns a Caller sending its requests with client.
+func New(client *rpc.Client, opts Options) *Caller {
+	if opts.Address == "" {
+		opts.Address = Address
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	return &Caller{client: client, opts: opts}
+}
+
+// Call runs calls at block and returns a result per call, in order, and the
+// endpoint that answered last. Without Multicall3 at its address, which is
+// checked once, each call is sent on its own and a failed one reported as
+// unsuccessful. An error means no results could be read.
+func (c *Caller) Call(ctx context.Context, calls []Call, block uint64) ([]Result, config.Endpoint, error) {
+	deployed, err := c.available(ctx, block)
+	if err != nil {
+		return nil, config.Endpoint{}, err
+	}
+	if !deployed {
+		return c.callEach(ctx, calls, block)
+	}
+	results := make([]Result, 0, len(calls))
+	var endpoint config.Endpoint
+	for start := 0; start < len(calls); start += c.opts.BatchSize {
+		batch := calls[start:min(start+c.opts.BatchSize, len(calls))]
+		data, err := EncodeAggregate3(batch)
+		if err != nil {
+			return nil, endpoint, err
+		}
+		var returned string
+		returned, endpoint, err = c.ethCall(ctx, c.opts.Address, data, block)
+		if err != nil {
+			return nil, endpoint, fmt.Errorf("aggregate3: %w", err)
+		}
+		decoded, err := DecodeAggregate3(returned)
+		if err != nil {
+			return nil, endpoint, fmt.Errorf("aggregate3: %w", err)
+		}
+		if len(decoded) != len(batch) {
+			return nil, endpoint, fmt.Errorf("aggregate3 returned %d results for %d calls", len(decoded), len(batch))
+		}
+		results = append(results, decoded...)
+	}
+	return results, endpoint, nil
+}
+
+// Balance is the outcome of one balanceOf sub-call: Amount, or Err when the
+// call failed.
+type Balance struct {
+	Amount *big.Int
+	Err    error
+}
+
+// Balances reads the USDC balance of each address at block, in order.
+func (c *Caller) Balances(ctx context.Context, addresses []string, block uint64) ([]Balance, config.Endpoint, error) {
+	calls := make([]Call, len(addresses))
+	for i, address := range addresses {
+		data, err := usdc.EncodeBalanceOfCall(address)
+		if err != nil {
+			return nil, config.Endpoint{}, fmt.Errorf("encode balanceOf %s: %w", address, err)
+		}
+		calls[i] = Call{Target: usdc.ContractAddress, Data: data}
+	}
+	results, endpoint, err := c.Call(ctx, calls, block)
+	if err != nil {
+		return nil, endpoint, err
+	}
+	balances := make([]Balance, len(results))
+	for i, result := range results {
+		switch {
+		case result.Err != nil:
+			balances[i].Err = result.Err
+		case !result.Success:
+			balances[i].Err = fmt.Errorf("balanceOf reverted: %s", result.Data)
+		default:
+			amount, err := usdc.DecodeAmount(result.Data)
+			if err != nil {
+				err = fmt.Errorf("decode balance: %w", err)
+			}
+			balances[i] = Balance{Amount: amount, Err: err}
+		}
+	}
+	return balances, endpoint, nil
+}
+
+// available reports whether aggregate3 can be called: with a code override,
+// or when code is deployed at the Multicall3 address.
+func (c *Caller) available(ctx context.Context, block uint64) (bool, error) {
+	if c.opts.Code != "" {
+		return true, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.deployed != nil {
+		return *c.deployed, nil
+	}
+	raw, _, err := c.client.Call(ctx, "eth_getCode", []interface{}{c.opts.Address, eth.FormatQuantity(block)})
+	if err != nil {
+		return false, fmt.Errorf("check multicall deployment: %w", err)
+	}
+	var code string
+	if err := json.Unmarshal(raw, &code); err != nil {
+		return false, fmt.Errorf("decode code: %w", err)
+	}
+	deployed := code != "" && code != "0x"
+	c.deployed = &deployed
+	return deployed, nil
+}
+
+// callEach sends every call on its own, keeping the error of each failed one.
+func (c *Caller) callEach(ctx context.Context, calls []Call, block uint64) ([]Result, config.Endpoint, error) {
+	results := make([]Result, len(calls))
+	var endpoint config.Endpoint
+	var errs []error
+	for i, call := range calls {
+		returned, answered, err := c.ethCall(ctx, call.Target, call.Data, block)
+		if err != nil {
+			results[i].Err = err
+			errs = append(errs, err)
+			continue
+		}
+		results[i], endpoint = Result{Success: true, Data: returned}, answered
+	}
+	if len(calls) > 0 && len(errs) == len(calls) {
+		return nil, endpoint, fmt.Errorf("every call failed: %w", errors.Join(errs...))
+	}
+	return results, endpoint, nil
+}
+
+// ethCall sends one eth_call at block, with the code override when set.
+func (c *Caller) ethCall(ctx context.Context, to, data string, block uint64) (string, config.Endpoint, error) {
+	params := []interface{}{
+		map[string]string{"to": to, "data": data},
+		eth.FormatQuantity(block),
+	}
+	if c.opts.Code != "" {
+		params = append(params, map[string]interface{}{
+			c.opts.Address: map[string]string{"code": c.opts.Code},
+		})
+	}
+	raw, endpoint, err := c.client.Call(ctx, "eth_call", params)
+	if err != nil {
+		return "", endpoint, err
+	}
+	var returned string
+	if err := json.Unmarshal(raw, &returned); err != nil {
+		return "", endpoint, fmt.Errorf("decode result: %w", err)
+	}
+	return returned, endpoint, nil
+}
+
+// abiData is ABI-encoded data in hex without the 0x prefix, addressed by
+// byte offset.
+type abiData string
+
+// uint reads the word at offset as an integer that must fit a uint64.
+func (d abiData) uint(offset uint64) (uint64, error) {
+	if offset > uint64(len(d))/2 || uint64(len(d))/2-offset < 32 {
+		return 0, fmt.Errorf("word at byte %d out of range", offset)
+	}
+	w := string(d[2*offset : 2*offset+64])
+	if strings.TrimLeft(w[:48], "0") != "" {
+		return 0, fmt.Errorf("word at byte %d too large", offset)
+	}
+	n, ok := new(big.Int).SetString(w, 16)
+	if !ok {
+		return 0, fmt.Errorf("invalid word at byte %d", offset)
+	}
+	return n.Uint64(), nil
+}
+
+// bytes reads the length-prefixed bytes at offset.
+func (d abiData) bytes(offset uint64) (string, error) {
+	length, err := d.uint(offset)
+	if err != nil {
+		return "", err
+	}
+	start := offset + 32
+	if length > uint64(len(d))/2-start {
+		return "", fmt.Errorf("%d bytes at byte %d out of range", length, start)
+	}
+	return string(d[2*start : 2*(start+length)]), nil
+}
+
+// word left-pads a hex value to 32 bytes.
+func word(hex string) string {
+	return strings.Repeat("0", 64-len(hex)) + hex
+}
+
+// uintWord encodes n as a 32-byte word.
+func uintWord(n int) string {
+	return fmt.Sprintf("%064x", n)
+}
+
+// pad right-pads hex data to a multiple of 32 bytes.
+func pad(hex string) string {
+	if rem := len(hex) % 64; rem != 0 {
+		hex += strings.Repeat("0", 64-rem)
+	}
+	return hex
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
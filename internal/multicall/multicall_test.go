@@ -0,0 +1,121 @@
+package multicall
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/rpctest"
+	"usdc-watch/internal/usdc"
+)
+
+const (
+	alice = "0x00000000000000000000000000000000000000a1"
+	bob   = "0x00000000000000000000000000000000000000b0"
+	carol = "0x00000000000000000000000000000000000000c2"
+)
+
+func TestEncodeAggregate3(t *testing.T) {
+	data, err := EncodeAggregate3([]Call{{Target: alice, Data: "0x70a08231"}, {Target: bob, Data: "0x"}})
+	if err != nil {
+		t.Fatalf("EncodeAggregate3 error: %v", err)
+	}
+	want := "0x82ad56cb" +
+		uintWord(32) + uintWord(2) + uintWord(64) + uintWord(64+5*32) +
+		word(alice[2:]) + word("1") + uintWord(96) + uintWord(4) + "70a08231" + strings.Repeat("0", 56) +
+		word(bob[2:]) + word("1") + uintWord(96) + uintWord(0)
+	if data != want {
+		t.Fatalf("EncodeAggregate3 =\n%s\nwant\n%s", data, want)
+	}
+	if _, err := EncodeAggregate3([]Call{{Target: alice, Data: "0x123"}}); err == nil {
+		t.Fatalf("expected error for odd-length call data")
+	}
+	if _, err := EncodeAggregate3([]Call{{Target: "nobody", Data: "0x"}}); err == nil {
+		t.Fatalf("expected error for an invalid target")
+	}
+}
+
+func TestDecodeAggregate3(t *testing.T) {
+	data := "0x" + uintWord(32) + uintWord(2) + uintWord(64) + uintWord(64+4*32) +
+		uintWord(1) + uintWord(64) + uintWord(32) + uintWord(7) +
+		uintWord(0) + uintWord(64) + uintWord(4) + "08c379a0" + strings.Repeat("0", 56)
+	results, err := DecodeAggregate3(data)
+	if err != nil {
+		t.Fatalf("DecodeAggregate3 error: %v", err)
+	}
+	if len(results) != 2 || !results[0].Success || results[0].Data != "0x"+uintWord(7) || results[1].Success || results[1].Data != "0x08c379a0" {
+		t.Fatalf("DecodeAggregate3 = %+v", results)
+	}
+	for name, bad := range map[string]string{
+		"empty":     "0x",
+		"truncated": data[:len(data)-64],
+		"length":    "0x" + uintWord(32) + uintWord(1000),
+	} {
+		if _, err := DecodeAggregate3(bad); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestCallerBalances(t *testing.T) {
+	tests := []struct {
+		name      string
+		deploy    bool
+		opts      Options
+		wantCalls int
+	}{
+		{name: "deployed", deploy: true, opts: Options{BatchSize: 2}, wantCalls: 2},
+		{name: "override", opts: Options{Code: "0x6080604052"}, wantCalls: 1},
+		{name: "fallback", wantCalls: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := rpctest.NewNode(t)
+			node.SetHead(10)
+			node.SetBalance(alice, 5, big.NewInt(1_000_000))
+			node.SetBalance(carol, 8, big.NewInt(3_000_000))
+			if tt.deploy {
+				node.DeployMulticall(Address)
+			}
+			client, err := rpc.NewClient([]config.Endpoint{node.Endpoint("node")}, &http.Client{Timeout: time.Second})
+			if err != nil {
+				t.Fatalf("NewClient error: %v", err)
+			}
+			caller := New(client, tt.opts)
+			balances, _, err := caller.Balances(context.Background(), []string{alice, bob, carol}, 7)
+			if err != nil {
+				t.Fatalf("Balances error: %v", err)
+			}
+			if len(balances) != 3 {
+				t.Fatalf("Balances = %+v", balances)
+			}
+			for i, want := range []int64{1_000_000, 0, 0} {
+				if balances[i].Err != nil || balances[i].Amount.Int64() != want {
+					t.Fatalf("Balances[%d] = %+v, want %d", i, balances[i], want)
+				}
+			}
+			if got := node.Requests("eth_call"); got != tt.wantCalls {
+				t.Fatalf("%d eth_call requests, want %d", got, tt.wantCalls)
+			}
+
+			results, _, err := caller.Call(context.Background(), []Call{{Target: bob, Data: "0x12345678"}, {Target: usdc.ContractAddress, Data: usdc.EncodePausedCall()}}, 10)
+			if err != nil {
+				t.Fatalf("Call error: %v", err)
+			}
+			if len(results) != 2 || results[0].Success || !results[1].Success || results[1].Data != "0x"+uintWord(0) {
+				t.Fatalf("Call = %+v; want the unknown call to fail alone", results)
+			}
+			if sentAlone := !tt.deploy && tt.opts.Code == ""; sentAlone && (results[0].Err == nil || !strings.Contains(results[0].Err.Error(), "execution reverted")) {
+				t.Fatalf("failed call error = %v; want the node's error", results[0].Err)
+			}
+			if got, want := node.Requests("eth_getCode"), map[bool]int{true: 0, false: 1}[tt.opts.Code != ""]; got != want {
+				t.Fatalf("%d eth_getCode requests, want %d", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,410 @@
+// Package report summarises stored balance history, transfers and alerts for a period.
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"usdc-watch/internal/history"
+	"usdc-watch/internal/usdc"
+)
+
+// DefaultTopTransfers is the number of largest transfers listed per address.
+const DefaultTopTransfers = 5
+
+// Period is the half-open reporting window [From, To).
+type Period struct {
+	From time.Time
+	To   time.Time
+}
+
+// PeriodEnding returns the "daily" or "weekly" period that ends at end.
+func PeriodEnding(kind string, end time.Time) (Period, error) {
+	switch kind {
+	case "daily":
+		return Period{From: end.AddDate(0, 0, -1), To: end}, nil
+	case "weekly":
+		return Period{From: end.AddDate(0, 0, -7), To: end}, nil
+	default:
+		return Period{}, fmt.Errorf("unknown period %q (expected daily or weekly)", kind)
+	}
+}
+
+// AlertCount summarises how often a rule fired for an address.
+type AlertCount struct {
+	Rule  string
+	Count int
+	First time.Time
+	Last  time.Time
+}
+
+// AddressReport holds the period summary for one address. Balance fields are
+// nil when no observation was available.
+type AddressReport struct {
+	Address          string
+	Start            *big.Int
+	End              *big.Int
+	Min              *big.Int
+	Max              *big.Int
+	Inflow           *big.Int
+	Outflow          *big.Int
+	TransferCount    int
+	LargestTransfers []history.Transfer
+	Alerts           []AlertCount
+}
+
+// Net returns inflow minus outflow.
+func (r AddressReport) Net() *big.Int {
+	return new(big.Int).Sub(r.Inflow, r.Outflow)
+}
+
+// Report is the full summary for a period.
+type Report struct {
+	Period    Period
+	Addresses []AddressReport
+}
+
+// Build summarises series for the given addresses over period. When addresses
+// is empty, every address with data in the period is included.
+func Build(series *history.Store, addresses []string, period Period, topTransfers int) (Report, error) {
+	// Range queries are inclusive, so stop just short of the period end.
+	to := period.To.Add(-time.Nanosecond)
+	points, err := series.Query("", period.From, to)
+	if err != nil {
+		return Report{}, err
+	}
+	transfers, err := series.Transfers("", period.From, to)
+	if err != nil {
+		return Report{}, err
+	}
+	alerts, err := series.Alerts("", period.From, to)
+	if err != nil {
+		return Report{}, err
+	}
+
+	if len(addresses) == 0 {
+		seen := make(map[string]bool)
+		for _, p := range points {
+			seen[p.Address] = true
+		}
+		for _, t := range transfers {
+			seen[t.Address] = true
+		}
+		for _, a := range alerts {
+			seen[a.Address] = true
+		}
+		for address := range seen {
+			addresses = append(addresses, address)
+		}
+		sort.Strings(addresses)
+	}
+
+	rep := Report{Period: period}
+	for _, address := range addresses {
+		ar := AddressReport{Address: address, Inflow: new(big.Int), Outflow: new(big.Int)}
+
+		if prior, ok, err := series.Latest(address, period.From); err != nil {
+			return Report{}, err
+		} else if ok {
+			ar.observe(prior.Balance)
+		}
+		for _, p := range points {
+			if p.Address == address {
+				ar.observe(p.Balance)
+			}
+		}
+
+		var own []history.Transfer
+		for _, t := range transfers {
+			if t.Address != address {
+				continue
+			}
+			own = append(own, t)
+			if t.Incoming() {
+				ar.Inflow.Add(ar.Inflow, t.Value)
+			} else {
+				ar.Outflow.Add(ar.Outflow, t.Value)
+			}
+		}
+		ar.TransferCount = len(own)
+		sort.SliceStable(own, func(i, j int) bool { return own[i].Value.Cmp(own[j].Value) > 0 })
+		if len(own) > topTransfers {
+			own = own[:topTransfers]
+		}
+		ar.LargestTransfers = own
+
+		byRule := make(map[string]*AlertCount)
+		for _, a := range alerts {
+			if a.Address != address {
+				continue
+			}
+			count, ok := byRule[a.Rule]
+			if !ok {
+				count = &AlertCount{Rule: a.Rule, First: a.Time}
+				byRule[a.Rule] = count
+			}
+			count.Count++
+			count.Last = a.Time
+		}
+		for _, count := range byRule {
+			ar.Alerts = append(ar.Alerts, *count)
+		}
+		sort.Slice(ar.Alerts, func(i, j int) bool { return ar.Alerts[i].Rule < ar.Alerts[j].Rule })
+
+		rep.Addresses = append(rep.Addresses, ar)
+	}
+	return rep, nil
+}
+
+func (r *AddressReport) observe(balance *big.Int) {
+	if r.Start == nil {
+		r.Start = balance
+		r.Min = balance
+		r.Max = balance
+	}
+	r.End = balance
+	if balance.Cmp(r.Min) < 0 {
+		r.Min = balance
+	}
+	if balance.Cmp(r.Max) > 0 {
+		r.Max = balance
+	}
+}
+
+// ValidFormat returns an error unless Render accepts format.
+func ValidFormat(format string) error {
+	switch format {
+	case "markdown", "md", "csv", "json":
+		return nil
+	default:
+		return fmt.Errorf("unknown report format %q (expected markdown, csv or json)", format)
+	}
+}
+
+// ContentType returns the media type of a report rendered as format.
+func ContentType(format string) string {
+	switch format {
+	case "csv":
+		return "text/csv; charset=utf-8"
+	case "json":
+		return "application/json"
+	default:
+		return "text/markdown; charset=utf-8"
+	}
+}
+
+// Render writes rep to w as "markdown", "csv" or "json".
+func Render(w io.Writer, format string, rep Report) error {
+	if err := ValidFormat(format); err != nil {
+		return err
+	}
+	switch format {
+	case "csv":
+		return renderCSV(w, rep)
+	case "json":
+		return renderJSON(w, rep)
+	default:
+		return renderMarkdown(w, rep)
+	}
+}
+
+// Summary returns a one-line overview of rep across its addresses, short
+// enough for a chat message or notification title.
+func Summary(rep Report) string {
+	net := new(big.Int)
+	transfers, alerts := 0, 0
+	for _, ar := range rep.Addresses {
+		net.Add(net, ar.Net())
+		transfers += ar.TransferCount
+		for _, a := range ar.Alerts {
+			alerts += a.Count
+		}
+	}
+	return fmt.Sprintf("USDC report %s to %s: %d addresses, net flow %s USDC, %d transfers, %d alerts",
+		rep.Period.From.UTC().Format(time.RFC3339), rep.Period.To.UTC().Format(time.RFC3339),
+		len(rep.Addresses), formatSigned(net), transfers, alerts)
+}
+
+func renderMarkdown(w io.Writer, rep Report) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# USDC balance report\n\n%s to %s\n", rep.Period.From.UTC().Format(time.RFC3339), rep.Period.To.UTC().Format(time.RFC3339))
+	if len(rep.Addresses) == 0 {
+		b.WriteString("\nNo data recorded for this period.\n")
+	}
+	for _, ar := range rep.Addresses {
+		fmt.Fprintf(&b, "\n## %s\n\n", ar.Address)
+		b.WriteString("| Metric | USDC |\n|---|---:|\n")
+		for _, row := range [][2]string{
+			{"Start balance", formatOptional(ar.Start)},
+			{"End balance", formatOptional(ar.End)},
+			{"Minimum", formatOptional(ar.Min)},
+			{"Maximum", formatOptional(ar.Max)},
+			{"Inflow", usdc.FormatAmount(ar.Inflow)},
+			{"Outflow", usdc.FormatAmount(ar.Outflow)},
+			{"Net flow", formatSigned(ar.Net())},
+		} {
+			fmt.Fprintf(&b, "| %s | %s |\n", row[0], row[1])
+		}
+		if len(ar.LargestTransfers) > 0 {
+			fmt.Fprintf(&b, "\nLargest transfers (%d total):\n\n| Direction | Counterparty | USDC | Block | Tx |\n|---|---|---:|---:|---|\n", ar.TransferCount)
+			for _, t := range ar.LargestTransfers {
+				direction, counterparty := "out", t.To
+				if t.Incoming() {
+					direction, counterparty = "in", t.From
+				}
+				fmt.Fprintf(&b, "| %s | %s | %s | %d | %s |\n", direction, counterparty, usdc.FormatAmount(t.Value), t.Block, t.TxHash)
+			}
+		}
+		if len(ar.Alerts) > 0 {
+			b.WriteString("\nAlerts:\n\n")
+			for _, a := range ar.Alerts {
+				fmt.Fprintf(&b, "- %s: %d (first %s, last %s)\n", a.Rule, a.Count, a.First.UTC().Format(time.RFC3339), a.Last.UTC().Format(time.RFC3339))
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func renderCSV(w io.Writer, rep Report) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"address", "from", "to", "start", "end", "min", "max", "inflow", "outflow", "net", "transfers", "largest_transfer", "largest_transfer_tx", "alerts"})
+	for _, ar := range rep.Addresses {
+		largest, largestTx := "", ""
+		if len(ar.LargestTransfers) > 0 {
+			largest = usdc.FormatAmount(ar.LargestTransfers[0].Value)
+			largestTx = ar.LargestTransfers[0].TxHash
+		}
+		alerts := 0
+		for _, a := range ar.Alerts {
+			alerts += a.Count
+		}
+		cw.Write([]string{
+			ar.Address,
+			rep.Period.From.UTC().Format(time.RFC3339),
+			rep.Period.To.UTC().Format(time.RFC3339),
+			formatOptional(ar.Start),
+			formatOptional(ar.End),
+			formatOptional(ar.Min),
+			formatOptional(ar.Max),
+			usdc.FormatAmount(ar.Inflow),
+			usdc.FormatAmount(ar.Outflow),
+			formatSigned(ar.Net()),
+			strconv.Itoa(ar.TransferCount),
+			largest,
+			largestTx,
+			strconv.Itoa(alerts),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type jsonTransfer struct {
+	Time      string `json:"time"`
+	Direction string `json:"direction"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Value     string `json:"value"`
+	Block     uint64 `json:"block"`
+	TxHash    string `json:"tx"`
+}
+
+type jsonAlert struct {
+	Rule  string `json:"rule"`
+	Count int    `json:"count"`
+	First string `json:"first"`
+	Last  string `json:"last"`
+}
+
+type jsonAddress struct {
+	Address          string         `json:"address"`
+	Start            string         `json:"start,omitempty"`
+	End              string         `json:"end,omitempty"`
+	Min              string         `json:"min,omitempty"`
+	Max              string         `json:"max,omitempty"`
+	Inflow           string         `json:"inflow"`
+	Outflow          string         `json:"outflow"`
+	Net              string         `json:"net"`
+	Transfers        int            `json:"transfers"`
+	LargestTransfers []jsonTransfer `json:"largest_transfers"`
+	Alerts           []jsonAlert    `json:"alerts"`
+}
+
+func renderJSON(w io.Writer, rep Report) error {
+	out := struct {
+		From      string        `json:"from"`
+		To        string        `json:"to"`
+		Addresses []jsonAddress `json:"addresses"`
+	}{
+		From:      rep.Period.From.UTC().Format(time.RFC3339),
+		To:        rep.Period.To.UTC().Format(time.RFC3339),
+		Addresses: []jsonAddress{},
+	}
+	for _, ar := range rep.Addresses {
+		ja := jsonAddress{
+			Address:          ar.Address,
+			Inflow:           usdc.FormatAmount(ar.Inflow),
+			Outflow:          usdc.FormatAmount(ar.Outflow),
+			Net:              formatSigned(ar.Net()),
+			Transfers:        ar.TransferCount,
+			LargestTransfers: []jsonTransfer{},
+			Alerts:           []jsonAlert{},
+		}
+		if ar.Start != nil {
+			ja.Start = usdc.FormatAmount(ar.Start)
+			ja.End = usdc.FormatAmount(ar.End)
+			ja.Min = usdc.FormatAmount(ar.Min)
+			ja.Max = usdc.FormatAmount(ar.Max)
+		}
+		for _, t := range ar.LargestTransfers {
+			direction := "out"
+			if t.Incoming() {
+				direction = "in"
+			}
+			ja.LargestTransfers = append(ja.LargestTransfers, jsonTransfer{
+				Time:      t.Time.UTC().Format(time.RFC3339),
+				Direction: direction,
+				From:      t.From,
+				To:        t.To,
+				Value:     usdc.FormatAmount(t.Value),
+				Block:     t.Block,
+				TxHash:    t.TxHash,
+			})
+		}
+		for _, a := range ar.Alerts {
+			ja.Alerts = append(ja.Alerts, jsonAlert{
+				Rule:  a.Rule,
+				Count: a.Count,
+				First: a.First.UTC().Format(time.RFC3339),
+				Last:  a.Last.UTC().Format(time.RFC3339),
+			})
+		}
+		out.Addresses = append(out.Addresses, ja)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func formatOptional(amount *big.Int) string {
+	if amount == nil {
+		return ""
+	}
+	return usdc.FormatAmount(amount)
+}
+
+// formatSigned renders a possibly negative amount; usdc.FormatAmount only handles non-negative values.
+func formatSigned(amount *big.Int) string {
+	if amount.Sign() < 0 {
+		return "-" + usdc.FormatAmount(new(big.Int).Neg(amount))
+	}
+	return usdc.FormatAmount(amount)
+}
@@ -0,0 +1,128 @@
+package report
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"usdc-watch/internal/history"
+)
+
+func seedSeries(t *testing.T) (*history.Store, Period) {
+	t.Helper()
+	series, err := history.Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("history.Open error: %v", err)
+	}
+	t.Cleanup(func() { series.Close() })
+
+	end := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	period, err := PeriodEnding("daily", end)
+	if err != nil {
+		t.Fatalf("PeriodEnding error: %v", err)
+	}
+	before := period.From.Add(-time.Hour)
+	mid := period.From.Add(6 * time.Hour)
+	series.Append(history.Point{Time: before, Address: "0xa", Balance: big.NewInt(5_000_000)})
+	series.Append(history.Point{Time: mid, Address: "0xa", Balance: big.NewInt(2_000_000)})
+	series.Append(history.Point{Time: mid.Add(time.Hour), Address: "0xa", Balance: big.NewInt(9_000_000)})
+	series.Append(history.Point{Time: end, Address: "0xa", Balance: big.NewInt(1)})
+	series.AppendTransfer(history.Transfer{Time: mid, Address: "0xa", From: "0xa", To: "0xc", Value: big.NewInt(3_000_000), TxHash: "0x01"})
+	series.AppendTransfer(history.Transfer{Time: mid.Add(time.Hour), Address: "0xa", From: "0xd", To: "0xa", Value: big.NewInt(7_000_000), TxHash: "0x02"})
+	series.AppendAlert(history.Alert{Time: mid.Add(time.Hour), Address: "0xa", Rule: "threshold"})
+	return series, period
+}
+
+func TestBuild(t *testing.T) {
+	series, period := seedSeries(t)
+	rep, err := Build(series, nil, period, 1)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	if len(rep.Addresses) != 1 {
+		t.Fatalf("expected 1 address, got %d", len(rep.Addresses))
+	}
+	ar := rep.Addresses[0]
+	checks := map[string][2]*big.Int{
+		"start":   {ar.Start, big.NewInt(5_000_000)},
+		"end":     {ar.End, big.NewInt(9_000_000)},
+		"min":     {ar.Min, big.NewInt(2_000_000)},
+		"max":     {ar.Max, big.NewInt(9_000_000)},
+		"inflow":  {ar.Inflow, big.NewInt(7_000_000)},
+		"outflow": {ar.Outflow, big.NewInt(3_000_000)},
+		"net":     {ar.Net(), big.NewInt(4_000_000)},
+	}
+	for name, pair := range checks {
+		if pair[0] == nil || pair[0].Cmp(pair[1]) != 0 {
+			t.Fatalf("%s = %v, expected %s", name, pair[0], pair[1])
+		}
+	}
+	if ar.TransferCount != 2 || len(ar.LargestTransfers) != 1 || ar.LargestTransfers[0].TxHash != "0x02" {
+		t.Fatalf("unexpected transfers: %d %+v", ar.TransferCount, ar.LargestTransfers)
+	}
+	if len(ar.Alerts) != 1 || ar.Alerts[0].Count != 1 {
+		t.Fatalf("unexpected alerts: %+v", ar.Alerts)
+	}
+}
+
+func TestRender(t *testing.T) {
+	series, period := seedSeries(t)
+	rep, err := Build(series, []string{"0xa"}, period, DefaultTopTransfers)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+
+	var md strings.Builder
+	if err := Render(&md, "markdown", rep); err != nil {
+		t.Fatalf("Render markdown error: %v", err)
+	}
+	if !strings.Contains(md.String(), "| Net flow | 4 |") || !strings.Contains(md.String(), "- threshold: 1") {
+		t.Fatalf("unexpected markdown:\n%s", md.String())
+	}
+
+	var csvOut strings.Builder
+	if err := Render(&csvOut, "csv", rep); err != nil {
+		t.Fatalf("Render csv error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(csvOut.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], "0xa,") {
+		t.Fatalf("unexpected csv:\n%s", csvOut.String())
+	}
+
+	var jsonOut strings.Builder
+	if err := Render(&jsonOut, "json", rep); err != nil {
+		t.Fatalf("Render json error: %v", err)
+	}
+	var decoded struct {
+		Addresses []struct {
+			Net string `json:"net"`
+		} `json:"addresses"`
+	}
+	if err := json.Unmarshal([]byte(jsonOut.String()), &decoded); err != nil || decoded.Addresses[0].Net != "4" {
+		t.Fatalf("unexpected json %s: %v", jsonOut.String(), err)
+	}
+
+	if err := Render(&md, "pdf", rep); err == nil {
+		t.Fatalf("expected error for unknown format")
+	}
+	if err := ValidFormat("pdf"); err == nil {
+		t.Fatalf("ValidFormat accepted pdf")
+	}
+	if err := ValidFormat("csv"); err != nil {
+		t.Fatalf("ValidFormat(csv) = %v", err)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	series, period := seedSeries(t)
+	rep, err := Build(series, []string{"0xa"}, period, DefaultTopTransfers)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	got := Summary(rep)
+	if !strings.Contains(got, "1 addresses, net flow 4 USDC, 2 transfers, 1 alerts") || strings.Contains(got, "\n") {
+		t.Fatalf("unexpected summary %q", got)
+	}
+}
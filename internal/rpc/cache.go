@@ -0,0 +1,171 @@
+package rpc
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"usdc-watch/internal/config"
+)
+
+// blockParamIndex gives the position of the block tag parameter for cacheable
+// methods. eth_getBlockByNumber is left out: the block at a number changes
+// with a reorganisation, and one not yet seen by a lagging endpoint is null.
+var blockParamIndex = map[string]int{
+	"eth_call":                1,
+	"eth_getBalance":          1,
+	"eth_getCode":             1,
+	"eth_getTransactionCount": 1,
+	"eth_getStorageAt":        2,
+}
+
+// immutableMethods never change their result for identical parameters.
+var immutableMethods = map[string]bool{
+	"eth_chainId": true,
+}
+
+// responseCache stores results of calls made at a concrete block, which never
+// change, in a bounded LRU, and results of calls made at "latest" only until
+// a new head is observed.
+type responseCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+	head    uint64
+	latest  map[string]cacheEntry
+	hits    uint64
+	misses  uint64
+}
+
+type cacheEntry struct {
+	key      string
+	result   json.RawMessage
+	endpoint config.Endpoint
+}
+
+func newResponseCache(size int) *responseCache {
+	return &responseCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+		latest:  make(map[string]cacheEntry),
+	}
+}
+
+// EnableCache turns on response caching with room for up to size immutable
+// results. It must be called before the client is shared between goroutines.
+func (c *Client) EnableCache(size int) {
+	if size <= 0 {
+		c.cache = nil
+		return
+	}
+	c.cache = newResponseCache(size)
+}
+
+// CacheStats reports cache hits and misses since the cache was enabled.
+func (c *Client) CacheStats() (hits, misses uint64) {
+	if c.cache == nil {
+		return 0, 0
+	}
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+	return c.cache.hits, c.cache.misses
+}
+
+// cacheKey classifies a request. It returns the key and whether the result is
+// immutable; ok is false when the request must not be cached.
+func cacheKey(method string, params interface{}) (key string, immutable bool, ok bool) {
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		return "", false, false
+	}
+	key = method + ":" + string(encoded)
+	if immutableMethods[method] {
+		return key, true, true
+	}
+	idx, known := blockParamIndex[method]
+	if !known {
+		return "", false, false
+	}
+	values, isList := params.([]interface{})
+	if !isList || idx >= len(values) {
+		return "", false, false
+	}
+	tag, isString := values[idx].(string)
+	if !isString {
+		return "", false, false
+	}
+	switch {
+	case tag == "latest":
+		return key, false, true
+	case strings.HasPrefix(tag, "0x"):
+		return key, true, true
+	default:
+		return "", false, false
+	}
+}
+
+// get returns a cached result. head is the latest known block number; results
+// cached at "latest" are discarded whenever it changes.
+func (rc *responseCache) get(key string, immutable bool, head uint64) (cacheEntry, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if immutable {
+		if el, ok := rc.entries[key]; ok {
+			rc.order.MoveToFront(el)
+			rc.hits++
+			return el.Value.(cacheEntry), true
+		}
+		rc.misses++
+		return cacheEntry{}, false
+	}
+	rc.advanceLocked(head)
+	if entry, ok := rc.latest[key]; ok && head > 0 {
+		rc.hits++
+		return entry, true
+	}
+	rc.misses++
+	return cacheEntry{}, false
+}
+
+// put stores a result. A null result, which a node returns for state it does
+// not have yet, is never stored.
+func (rc *responseCache) put(key string, immutable bool, head uint64, entry cacheEntry) {
+	if result := bytes.TrimSpace(entry.result); len(result) == 0 || bytes.Equal(result, []byte("null")) {
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	entry.key = key
+	if !immutable {
+		// Without a known head there is nothing to invalidate against.
+		if head == 0 {
+			return
+		}
+		rc.advanceLocked(head)
+		rc.latest[key] = entry
+		return
+	}
+	if el, ok := rc.entries[key]; ok {
+		el.Value = entry
+		rc.order.MoveToFront(el)
+		return
+	}
+	rc.entries[key] = rc.order.PushFront(entry)
+	for rc.order.Len() > rc.size {
+		oldest := rc.order.Back()
+		rc.order.Remove(oldest)
+		delete(rc.entries, oldest.Value.(cacheEntry).key)
+	}
+}
+
+// advanceLocked drops "latest" results once a newer head has been observed.
+func (rc *responseCache) advanceLocked(head uint64) {
+	if head != rc.head {
+		rc.head = head
+		clear(rc.latest)
+	}
+}
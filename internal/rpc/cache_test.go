@@ -0,0 +1,128 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"usdc-watch/internal/config"
+)
+
+func TestCacheKey(t *testing.T) {
+	cases := []struct {
+		method    string
+		params    interface{}
+		immutable bool
+		ok        bool
+	}{
+		{"eth_chainId", []interface{}{}, true, true},
+		{"eth_call", []interface{}{map[string]string{"to": "0x1"}, "0x10"}, true, true},
+		{"eth_call", []interface{}{map[string]string{"to": "0x1"}, "latest"}, false, true},
+		{"eth_call", []interface{}{map[string]string{"to": "0x1"}, "pending"}, false, false},
+		{"eth_getBlockByNumber", []interface{}{"0x10", false}, false, false},
+		{"eth_blockNumber", []interface{}{}, false, false},
+		{"eth_getLogs", []interface{}{map[string]string{}}, false, false},
+	}
+	for _, tc := range cases {
+		_, immutable, ok := cacheKey(tc.method, tc.params)
+		if immutable != tc.immutable || ok != tc.ok {
+			t.Fatalf("cacheKey(%s, %v) = immutable %v ok %v", tc.method, tc.params, immutable, ok)
+		}
+	}
+}
+
+func TestClientCache(t *testing.T) {
+	var head, requests int64 = 100, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req jsonRPCRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		n := atomic.AddInt64(&requests, 1)
+		result := fmt.Sprintf(`"0x%x"`, n)
+		if req.Method == "eth_blockNumber" {
+			result = fmt.Sprintf(`"0x%x"`, atomic.LoadInt64(&head))
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"result":%s}`, req.ID, result)
+	}))
+	defer server.Close()
+
+	client, err := NewClient([]config.Endpoint{{Name: "node", URL: server.URL}}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	client.EnableCache(8)
+	ctx := context.Background()
+	call := func(tag string) string {
+		t.Helper()
+		raw, _, err := client.Call(ctx, "eth_call", []interface{}{map[string]string{"to": "0x1"}, tag})
+		if err != nil {
+			t.Fatalf("Call error: %v", err)
+		}
+		return string(raw)
+	}
+
+	if first, second := call("0x64"), call("0x64"); first != second {
+		t.Fatalf("concrete block result not cached: %s vs %s", first, second)
+	}
+
+	// "latest" is not cached until a head is known.
+	if first, second := call("latest"), call("latest"); first == second {
+		t.Fatalf("latest result cached without a known head")
+	}
+	if _, _, err := client.BlockNumber(ctx); err != nil {
+		t.Fatalf("BlockNumber error: %v", err)
+	}
+	cached := call("latest")
+	if again := call("latest"); again != cached {
+		t.Fatalf("latest result not cached within a head: %s vs %s", cached, again)
+	}
+
+	atomic.StoreInt64(&head, 101)
+	if _, _, err := client.BlockNumber(ctx); err != nil {
+		t.Fatalf("BlockNumber error: %v", err)
+	}
+	if fresh := call("latest"); fresh == cached {
+		t.Fatalf("latest result survived a new head")
+	}
+
+	hits, misses := client.CacheStats()
+	if hits != 2 || misses != 5 {
+		t.Fatalf("CacheStats = %d hits, %d misses", hits, misses)
+	}
+}
+
+func TestCacheSkipsNullResults(t *testing.T) {
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req jsonRPCRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		result := "null"
+		if atomic.AddInt64(&requests, 1) > 1 {
+			result = `"0x1"`
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"result":%s}`, req.ID, result)
+	}))
+	defer server.Close()
+
+	client, err := NewClient([]config.Endpoint{{Name: "node", URL: server.URL}}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	client.EnableCache(8)
+	params := []interface{}{map[string]string{"to": "0x1"}, "0x64"}
+	for _, want := range []string{"null", `"0x1"`, `"0x1"`} {
+		raw, _, err := client.Call(context.Background(), "eth_call", params)
+		if err != nil {
+			t.Fatalf("Call error: %v", err)
+		}
+		if string(raw) != want {
+			t.Fatalf("Call = %s, want %s", raw, want)
+		}
+	}
+	if n := atomic.LoadInt64(&requests); n != 2 {
+		t.Fatalf("%d requests, want the null result fetched again and the next cached", n)
+	}
+}
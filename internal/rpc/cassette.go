@@ -0,0 +1,210 @@
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"usdc-watch/internal/config"
+)
+
+// Interaction is one recorded request to an endpoint and its outcome: a line
+// of a JSONL cassette. Endpoint URLs are left out as they often embed API keys.
+type Interaction struct {
+	Time      time.Time       `json:"time"`
+	Endpoint  string          `json:"endpoint"`
+	Method    string          `json:"method"`
+	Params    json.RawMessage `json:"params"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	LatencyMS float64         `json:"latency_ms"`
+}
+
+// Recorder appends interactions to a cassette. It is safe for concurrent use.
+type Recorder struct {
+	mu  sync.Mutex
+	w   io.Writer
+	err error
+}
+
+// NewRecorder returns a Recorder writing one JSON interaction per line to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{w: w}
+}
+
+// Record appends an interaction. After a failed write the recorder keeps
+// its error and drops later interactions, so a cassette never has gaps.
+func (r *Recorder) Record(interaction Interaction) error {
+	line, err := json.Marshal(interaction)
+	if err != nil {
+		return fmt.Errorf("encode interaction: %w", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	if _, err := r.w.Write(append(line, '\n')); err != nil {
+		r.err = fmt.Errorf("write cassette: %w", err)
+	}
+	return r.err
+}
+
+// Err returns the write error that stopped recording, if any.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// SetRecorder records every request sent to an individual endpoint, with its
+// response or error, latency and start time. It must be called before the
+// client is shared between goroutines.
+func (c *Client) SetRecorder(recorder *Recorder) {
+	c.recorder = recorder
+}
+
+// record hands a finished request to the recorder, if one is set.
+func (c *Client) record(started time.Time, endpoint config.Endpoint, method string, params interface{}, result json.RawMessage, latency time.Duration, err error) {
+	if c.recorder == nil {
+		return
+	}
+	encoded, marshalErr := json.Marshal(params)
+	if marshalErr != nil {
+		return
+	}
+	interaction := Interaction{
+		Time:      started.UTC(),
+		Endpoint:  endpoint.Name,
+		Method:    method,
+		Params:    encoded,
+		Result:    result,
+		LatencyMS: float64(latency.Microseconds()) / 1000,
+	}
+	if err != nil {
+		interaction.Result, interaction.Error = nil, err.Error()
+	}
+	c.recorder.Record(interaction)
+}
+
+// ReadCassette decodes the interactions of a cassette in recorded order.
+func ReadCassette(r io.Reader) ([]Interaction, error) {
+	var interactions []Interaction
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<24)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var interaction Interaction
+		if err := json.Unmarshal(scanner.Bytes(), &interaction); err != nil {
+			return nil, fmt.Errorf("cassette line %d: %w", line, err)
+		}
+		interactions = append(interactions, interaction)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read cassette: %w", err)
+	}
+	return interactions, nil
+}
+
+// Replayer is an http.RoundTripper answering JSON-RPC requests from a
+// cassette instead of the network, so a recorded incident can be reproduced
+// offline by a Client built with it. Each request takes the earliest unplayed
+// interaction with the same method and parameters, whichever endpoint it was
+// recorded from, so failover plays out as recorded: a recorded failure fails
+// the request and the client moves on to its next endpoint. A request with
+// nothing left to play fails.
+type Replayer struct {
+	mu      sync.Mutex
+	pending map[string][]Interaction
+}
+
+// NewReplayer returns a Replayer serving interactions.
+func NewReplayer(interactions []Interaction) (*Replayer, error) {
+	r := &Replayer{pending: make(map[string][]Interaction)}
+	for i, interaction := range interactions {
+		key, err := replayKey(interaction.Method, interaction.Params)
+		if err != nil {
+			return nil, fmt.Errorf("interaction %d: %w", i+1, err)
+		}
+		r.pending[key] = append(r.pending[key], interaction)
+	}
+	return r, nil
+}
+
+// Unplayed returns the number of interactions not served yet.
+func (r *Replayer) Unplayed() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, queue := range r.pending {
+		n += len(queue)
+	}
+	return n
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+	var call struct {
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+		ID     json.RawMessage `json:"id"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&call); err != nil {
+		return nil, fmt.Errorf("decode replayed request: %w", err)
+	}
+	key, err := replayKey(call.Method, call.Params)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	queue := r.pending[key]
+	if len(queue) == 0 {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("no recorded response for %s %s", call.Method, call.Params)
+	}
+	interaction := queue[0]
+	r.pending[key] = queue[1:]
+	r.mu.Unlock()
+
+	if interaction.Error != "" {
+		return nil, errors.New(interaction.Error)
+	}
+	body, err := json.Marshal(jsonRPCResponse{JSONRPC: "2.0", ID: call.ID, Result: interaction.Result})
+	if err != nil {
+		return nil, fmt.Errorf("encode replayed response: %w", err)
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// replayKey identifies a request by method and compacted parameters.
+func replayKey(method string, params json.RawMessage) (string, error) {
+	var compact bytes.Buffer
+	if len(params) > 0 {
+		if err := json.Compact(&compact, params); err != nil {
+			return "", fmt.Errorf("compact params: %w", err)
+		}
+	}
+	return method + " " + strings.TrimSpace(compact.String()), nil
+}
@@ -0,0 +1,74 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/rpctest"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	broken, good := rpctest.NewNode(t), rpctest.NewNode(t)
+	good.SetHead(42)
+	broken.InjectFault(rpctest.Fault{HTTPStatus: http.StatusServiceUnavailable, Times: 1})
+
+	var cassette bytes.Buffer
+	client, err := NewClient([]config.Endpoint{broken.Endpoint("broken"), good.Endpoint("good")}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	client.SetRecorder(NewRecorder(&cassette))
+	ctx := context.Background()
+	if head, endpoint, err := client.BlockNumber(ctx); err != nil || head != 42 || endpoint.Name != "good" {
+		t.Fatalf("recorded BlockNumber = %d via %s, %v", head, endpoint.Name, err)
+	}
+	if _, _, err := client.TransactionReceipt(ctx, "0x01"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("recorded TransactionReceipt error %v, want ErrNotFound", err)
+	}
+
+	interactions, err := ReadCassette(bytes.NewReader(cassette.Bytes()))
+	if err != nil {
+		t.Fatalf("ReadCassette error: %v", err)
+	}
+	if len(interactions) != 3 {
+		t.Fatalf("recorded %d interactions, want 3: %s", len(interactions), cassette.String())
+	}
+	first := interactions[0]
+	if first.Endpoint != "broken" || first.Method != "eth_blockNumber" || first.Error == "" || first.Result != nil || first.Time.IsZero() {
+		t.Fatalf("first interaction = %+v", first)
+	}
+	if second := interactions[1]; second.Endpoint != "good" || string(second.Result) != `"0x2a"` || second.LatencyMS <= 0 {
+		t.Fatalf("second interaction = %+v", second)
+	}
+
+	replayer, err := NewReplayer(interactions)
+	if err != nil {
+		t.Fatalf("NewReplayer error: %v", err)
+	}
+	offline, err := NewClient([]config.Endpoint{
+		{Name: "broken", URL: "http://broken.invalid"},
+		{Name: "good", URL: "http://good.invalid"},
+	}, &http.Client{Transport: replayer})
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	if head, endpoint, err := offline.BlockNumber(ctx); err != nil || head != 42 || endpoint.Name != "good" {
+		t.Fatalf("replayed BlockNumber = %d via %s, %v", head, endpoint.Name, err)
+	}
+	if health := offline.Health(); health[0].Failures != 1 {
+		t.Fatalf("replayed failover health = %+v", health)
+	}
+	if _, _, err := offline.TransactionReceipt(ctx, "0x01"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("replayed TransactionReceipt error %v, want ErrNotFound", err)
+	}
+	if n := replayer.Unplayed(); n != 0 {
+		t.Fatalf("%d interactions unplayed", n)
+	}
+	if _, _, err := offline.BlockNumber(ctx); err == nil {
+		t.Fatalf("expected an error once the cassette is exhausted")
+	}
+}
@@ -20,10 +20,45 @@ type Client struct {
 	endpoints []config.Endpoint
 	http      *http.Client
 
-	mu   sync.Mutex
-	next int
+	mu     sync.Mutex
+	next   int
+	health []EndpointHealth
 
-	callID uint64
+	callID   uint64
+	observer Observer
+	recorder *Recorder
+	maxLag   uint64
+	cache    *responseCache
+	seenHead uint64
+}
+
+// Observer receives the outcome of every request sent to an individual endpoint.
+type Observer interface {
+	ObserveRequest(endpoint config.Endpoint, method string, latency time.Duration, err error)
+}
+
+// EndpointHealth reports the recent behaviour of a single endpoint.
+type EndpointHealth struct {
+	Name                string
+	Requests            uint64
+	Failures            uint64
+	ConsecutiveFailures int
+	LastLatency         time.Duration
+	LastSuccess         time.Time
+	LastFailure         time.Time
+	LastError           string
+
+	// Head is the latest block number reported by the head monitor (0 if unknown)
+	// and Lag how far it trails the highest head seen across all endpoints.
+	Head     uint64
+	HeadAt   time.Time
+	Lag      uint64
+	Excluded bool
+}
+
+// Healthy reports whether the most recent request to the endpoint succeeded.
+func (h EndpointHealth) Healthy() bool {
+	return h.ConsecutiveFailures == 0
 }
 
 // NewClient creates a new RPC client using the provided endpoints.
@@ -35,21 +70,42 @@ func NewClient(endpoints []config.Endpoint, httpClient *http.Client) (*Client, e
 	if client == nil {
 		client = &http.Client{Timeout: 12 * time.Second}
 	}
-	return &Client{endpoints: endpoints, http: client}, nil
+	health := make([]EndpointHealth, len(endpoints))
+	for i, endpoint := range endpoints {
+		health[i].Name = endpoint.Name
+	}
+	return &Client{endpoints: append([]config.Endpoint(nil), endpoints...), http: client, health: health}, nil
+}
+
+// SetObserver registers an observer for per-endpoint request outcomes.
+// It must be called before the client is shared between goroutines.
+func (c *Client) SetObserver(observer Observer) {
+	c.observer = observer
 }
 
 // Call performs the JSON-RPC call, rotating through endpoints until one succeeds.
 func (c *Client) Call(ctx context.Context, method string, params interface{}) (json.RawMessage, config.Endpoint, error) {
-	if len(c.endpoints) == 0 {
-		return nil, config.Endpoint{}, fmt.Errorf("no endpoints configured")
+	key, immutable, cacheable := "", false, false
+	if c.cache != nil {
+		key, immutable, cacheable = cacheKey(method, params)
+	}
+	if cacheable {
+		if entry, ok := c.cache.get(key, immutable, c.Head()); ok {
+			return entry.result, entry.endpoint, nil
+		}
 	}
-	start := c.nextIndex()
 	var errs []string
-	for i := 0; i < len(c.endpoints); i++ {
-		idx := (start + i) % len(c.endpoints)
-		endpoint := c.endpoints[idx]
-		result, err := c.callSingle(ctx, endpoint, method, params)
+	for _, endpoint := range c.candidates() {
+		result, err := c.callEndpoint(ctx, endpoint, method, params)
 		if err == nil {
+			if method == "eth_blockNumber" {
+				if head, err := decodeQuantity(result); err == nil {
+					c.observeHead(head)
+				}
+			}
+			if cacheable {
+				c.cache.put(key, immutable, c.Head(), cacheEntry{result: result, endpoint: endpoint})
+			}
 			return result, endpoint, nil
 		}
 		errs = append(errs, fmt.Sprintf("%s: %v", endpoint.Name, err))
@@ -57,12 +113,124 @@ func (c *Client) Call(ctx context.Context, method string, params interface{}) (j
 	return nil, config.Endpoint{}, fmt.Errorf("all endpoints failed: %s", strings.Join(errs, "; "))
 }
 
-func (c *Client) nextIndex() int {
+// CallEndpoint performs the JSON-RPC call on a single endpoint, for requests
+// that must reach the node that answered an earlier one, such as a read at
+// the head block it reported.
+func (c *Client) CallEndpoint(ctx context.Context, endpoint config.Endpoint, method string, params interface{}) (json.RawMessage, error) {
+	return c.callEndpoint(ctx, endpoint, method, params)
+}
+
+// callEndpoint sends a request to a single endpoint and records its outcome.
+func (c *Client) callEndpoint(ctx context.Context, endpoint config.Endpoint, method string, params interface{}) (json.RawMessage, error) {
+	started := time.Now()
+	result, err := c.callSingle(ctx, endpoint, method, params)
+	latency := time.Since(started)
+	c.recordHealth(endpoint, latency, err)
+	c.record(started, endpoint, method, params, result, latency, err)
+	if c.observer != nil {
+		c.observer.ObserveRequest(endpoint, method, latency, err)
+	}
+	return result, err
+}
+
+// candidates returns endpoints in rotation order, leaving out endpoints whose
+// head lags too far behind. If every endpoint lags, none are excluded.
+func (c *Client) candidates() []config.Endpoint {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	idx := c.next
+	start := c.next
 	c.next = (c.next + 1) % len(c.endpoints)
-	return idx
+	best := c.bestHeadLocked()
+	order := make([]config.Endpoint, 0, len(c.endpoints))
+	for i := 0; i < len(c.endpoints); i++ {
+		idx := (start + i) % len(c.endpoints)
+		if c.laggingLocked(idx, best) {
+			continue
+		}
+		order = append(order, c.endpoints[idx])
+	}
+	if len(order) == 0 {
+		for i := 0; i < len(c.endpoints); i++ {
+			order = append(order, c.endpoints[(start+i)%len(c.endpoints)])
+		}
+	}
+	return order
+}
+
+// Endpoints returns the endpoints currently in use.
+func (c *Client) Endpoints() []config.Endpoint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]config.Endpoint(nil), c.endpoints...)
+}
+
+// SetEndpoints replaces the endpoint pool. Health and head statistics carry over
+// for endpoints whose name and URL are unchanged. Requests already in flight
+// finish against the endpoint they were sent to.
+func (c *Client) SetEndpoints(endpoints []config.Endpoint) error {
+	if len(endpoints) == 0 {
+		return fmt.Errorf("rpc client requires at least one endpoint")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	health := make([]EndpointHealth, len(endpoints))
+	for i, endpoint := range endpoints {
+		health[i].Name = endpoint.Name
+		if idx := c.indexLocked(endpoint); idx >= 0 {
+			health[i] = c.health[idx]
+		}
+	}
+	c.endpoints = append([]config.Endpoint(nil), endpoints...)
+	c.health = health
+	c.next %= len(endpoints)
+	return nil
+}
+
+// indexLocked returns the position of endpoint in the pool, or -1 if it has
+// been removed. Callers hold c.mu.
+func (c *Client) indexLocked(endpoint config.Endpoint) int {
+	for i, candidate := range c.endpoints {
+		if candidate == endpoint {
+			return i
+		}
+	}
+	return -1
+}
+
+// Health returns a snapshot of per-endpoint request statistics in configuration order.
+func (c *Client) Health() []EndpointHealth {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	best := c.bestHeadLocked()
+	out := append([]EndpointHealth(nil), c.health...)
+	for i := range out {
+		if out[i].Head > 0 && best > out[i].Head {
+			out[i].Lag = best - out[i].Head
+		}
+		out[i].Excluded = c.laggingLocked(i, best)
+	}
+	return out
+}
+
+func (c *Client) recordHealth(endpoint config.Endpoint, latency time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	idx := c.indexLocked(endpoint)
+	if idx < 0 {
+		return
+	}
+	h := &c.health[idx]
+	h.Requests++
+	h.LastLatency = latency
+	if err != nil {
+		h.Failures++
+		h.ConsecutiveFailures++
+		h.LastFailure = time.Now()
+		h.LastError = err.Error()
+		return
+	}
+	h.ConsecutiveFailures = 0
+	h.LastSuccess = time.Now()
 }
 
 func (c *Client) callSingle(ctx context.Context, endpoint config.Endpoint, method string, params interface{}) (json.RawMessage, error) {
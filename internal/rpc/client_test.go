@@ -0,0 +1,91 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/rpctest"
+)
+
+func TestLaggingEndpointExcluded(t *testing.T) {
+	fresh, stale := rpctest.NewNode(t), rpctest.NewNode(t)
+	fresh.SetHead(1000)
+	stale.SetHead(990)
+
+	client, err := NewClient([]config.Endpoint{fresh.Endpoint("fresh"), stale.Endpoint("stale")}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	client.SetMaxLag(3)
+	client.PollHeads(context.Background(), time.Second)
+
+	if head := client.Head(); head != 1000 {
+		t.Fatalf("Head = %d, expected 1000", head)
+	}
+	health := client.Health()
+	if health[1].Lag != 10 || !health[1].Excluded || health[0].Excluded {
+		t.Fatalf("unexpected health: %+v", health)
+	}
+
+	for i := 0; i < 4; i++ {
+		if _, endpoint, err := client.ChainID(context.Background()); err != nil || endpoint.Name != "fresh" {
+			t.Fatalf("ChainID via %s, %v; expected fresh endpoint", endpoint.Name, err)
+		}
+	}
+	if stale.Requests("eth_chainId") != 0 || fresh.Requests("eth_chainId") != 4 {
+		t.Fatalf("expected all calls on fresh endpoint, got fresh=%d stale=%d", fresh.Requests("eth_chainId"), stale.Requests("eth_chainId"))
+	}
+}
+
+func TestCallFailsOver(t *testing.T) {
+	broken, good := rpctest.NewNode(t), rpctest.NewNode(t)
+	broken.InjectFault(rpctest.Fault{HTTPStatus: http.StatusServiceUnavailable})
+
+	client, err := NewClient([]config.Endpoint{broken.Endpoint("broken"), good.Endpoint("good")}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	if _, endpoint, err := client.BlockNumber(context.Background()); err != nil || endpoint.Name != "good" {
+		t.Fatalf("BlockNumber via %s, %v", endpoint.Name, err)
+	}
+	health := client.Health()
+	if health[0].Failures != 1 || health[0].Healthy() || !health[1].Healthy() {
+		t.Fatalf("unexpected health after failover: %+v", health)
+	}
+}
+
+func TestSetEndpointsKeepsHealth(t *testing.T) {
+	kept, removed, added := rpctest.NewNode(t), rpctest.NewNode(t), rpctest.NewNode(t)
+	client, err := NewClient([]config.Endpoint{kept.Endpoint("kept"), removed.Endpoint("removed")}, nil)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, _, err := client.BlockNumber(context.Background()); err != nil {
+			t.Fatalf("BlockNumber error: %v", err)
+		}
+	}
+
+	if err := client.SetEndpoints([]config.Endpoint{added.Endpoint("added"), kept.Endpoint("kept")}); err != nil {
+		t.Fatalf("SetEndpoints error: %v", err)
+	}
+	health := client.Health()
+	if len(health) != 2 || health[0].Name != "added" || health[0].Requests != 0 || health[1].Name != "kept" || health[1].Requests != 1 {
+		t.Fatalf("unexpected health after swap: %+v", health)
+	}
+	for i := 0; i < 4; i++ {
+		if _, _, err := client.ChainID(context.Background()); err != nil {
+			t.Fatalf("ChainID error: %v", err)
+		}
+	}
+	if removed.Requests("eth_chainId") != 0 || added.Requests("eth_chainId") != 2 || kept.Requests("eth_chainId") != 2 {
+		t.Fatalf("unexpected rotation after swap: added=%d kept=%d removed=%d",
+			added.Requests("eth_chainId"), kept.Requests("eth_chainId"), removed.Requests("eth_chainId"))
+	}
+	if err := client.SetEndpoints(nil); err == nil {
+		t.Fatalf("expected an error for an empty endpoint list")
+	}
+}
@@ -0,0 +1,134 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/eth"
+)
+
+// ErrNotFound reports that the node does not know the requested transaction
+// or receipt, such as the receipt of a transaction that is still pending.
+var ErrNotFound = errors.New("not found")
+
+// BlockNumber returns the head block reported by the first endpoint that answers.
+func (c *Client) BlockNumber(ctx context.Context) (uint64, config.Endpoint, error) {
+	raw, endpoint, err := c.Call(ctx, "eth_blockNumber", []interface{}{})
+	if err != nil {
+		return 0, endpoint, err
+	}
+	block, err := decodeQuantity(raw)
+	if err != nil {
+		return 0, endpoint, fmt.Errorf("decode block number: %w", err)
+	}
+	return block, endpoint, nil
+}
+
+// LogFilter selects logs for eth_getLogs. Topics follow the JSON-RPC positional
+// form: each entry is nil (wildcard), a topic string, or a []string of alternatives.
+type LogFilter struct {
+	FromBlock uint64
+	ToBlock   uint64
+	Address   string
+	Topics    []interface{}
+}
+
+// GetLogs returns the logs matching filter.
+func (c *Client) GetLogs(ctx context.Context, filter LogFilter) ([]eth.Log, config.Endpoint, error) {
+	query := map[string]interface{}{
+		"fromBlock": eth.FormatQuantity(filter.FromBlock),
+		"toBlock":   eth.FormatQuantity(filter.ToBlock),
+	}
+	if filter.Address != "" {
+		query["address"] = filter.Address
+	}
+	if len(filter.Topics) > 0 {
+		query["topics"] = filter.Topics
+	}
+	raw, endpoint, err := c.Call(ctx, "eth_getLogs", []interface{}{query})
+	if err != nil {
+		return nil, endpoint, err
+	}
+	var logs []eth.Log
+	if err := json.Unmarshal(raw, &logs); err != nil {
+		return nil, endpoint, fmt.Errorf("decode logs: %w", err)
+	}
+	return logs, endpoint, nil
+}
+
+// ChainID returns the chain ID reported by the first endpoint that answers.
+func (c *Client) ChainID(ctx context.Context) (uint64, config.Endpoint, error) {
+	raw, endpoint, err := c.Call(ctx, "eth_chainId", []interface{}{})
+	if err != nil {
+		return 0, endpoint, err
+	}
+	id, err := decodeQuantity(raw)
+	if err != nil {
+		return 0, endpoint, fmt.Errorf("decode chain id: %w", err)
+	}
+	return id, endpoint, nil
+}
+
+// HeaderByNumber returns the header of the given block.
+func (c *Client) HeaderByNumber(ctx context.Context, number uint64) (eth.Header, config.Endpoint, error) {
+	return c.header(ctx, eth.FormatQuantity(number), fmt.Sprint(number))
+}
+
+// LatestHeader returns the header of the head block in a single request, so
+// its number and hash come from the same endpoint.
+func (c *Client) LatestHeader(ctx context.Context) (eth.Header, config.Endpoint, error) {
+	header, endpoint, err := c.header(ctx, "latest", "latest")
+	if err != nil {
+		return header, endpoint, err
+	}
+	if head, err := eth.ParseQuantity(header.Number); err == nil {
+		c.observeHead(head)
+	}
+	return header, endpoint, nil
+}
+
+// header returns the header of the block tag names; name identifies the
+// block in errors.
+func (c *Client) header(ctx context.Context, tag, name string) (eth.Header, config.Endpoint, error) {
+	raw, endpoint, err := c.Call(ctx, "eth_getBlockByNumber", []interface{}{tag, false})
+	if err != nil {
+		return eth.Header{}, endpoint, err
+	}
+	if string(raw) == "null" {
+		return eth.Header{}, endpoint, fmt.Errorf("block %s not found", name)
+	}
+	var header eth.Header
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return eth.Header{}, endpoint, fmt.Errorf("decode block header: %w", err)
+	}
+	return header, endpoint, nil
+}
+
+// TransactionByHash returns the transaction with the given hash.
+func (c *Client) TransactionByHash(ctx context.Context, hash string) (eth.Transaction, config.Endpoint, error) {
+	raw, endpoint, err := c.Call(ctx, "eth_getTransactionByHash", []interface{}{hash})
+	if err != nil {
+		return eth.Transaction{}, endpoint, err
+	}
+	if string(raw) == "null" {
+		return eth.Transaction{}, endpoint, fmt.Errorf("transaction %s: %w", hash, ErrNotFound)
+	}
+	tx, err := eth.DecodeTransaction(raw)
+	return tx, endpoint, err
+}
+
+// TransactionReceipt returns the receipt of a mined transaction.
+func (c *Client) TransactionReceipt(ctx context.Context, hash string) (eth.Receipt, config.Endpoint, error) {
+	raw, endpoint, err := c.Call(ctx, "eth_getTransactionReceipt", []interface{}{hash})
+	if err != nil {
+		return eth.Receipt{}, endpoint, err
+	}
+	if string(raw) == "null" {
+		return eth.Receipt{}, endpoint, fmt.Errorf("receipt for %s: %w", hash, ErrNotFound)
+	}
+	receipt, err := eth.DecodeReceipt(raw)
+	return receipt, endpoint, err
+}
@@ -0,0 +1,110 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/eth"
+)
+
+// SetMaxLag excludes endpoints from reads while their head trails the highest
+// head seen by more than maxLag blocks. Zero disables exclusion. Lag is only
+// known while MonitorHeads is running. It must be called before the client is
+// shared between goroutines.
+func (c *Client) SetMaxLag(maxLag uint64) {
+	c.maxLag = maxLag
+}
+
+// Head returns the highest block number observed through eth_blockNumber,
+// either by the head monitor or by callers, or 0 if unknown.
+func (c *Client) Head() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return max(c.bestHeadLocked(), c.seenHead)
+}
+
+// observeHead records a head returned to a caller of eth_blockNumber.
+func (c *Client) observeHead(head uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seenHead = max(c.seenHead, head)
+}
+
+// MonitorHeads polls eth_blockNumber on every endpoint each interval until ctx
+// is cancelled, recording each endpoint's head for lag detection.
+func (c *Client) MonitorHeads(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		c.PollHeads(ctx, interval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PollHeads queries every endpoint's head once, concurrently, with the given timeout.
+func (c *Client) PollHeads(ctx context.Context, timeout time.Duration) {
+	pollCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, endpoint := range c.Endpoints() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			raw, err := c.callEndpoint(pollCtx, endpoint, "eth_blockNumber", []interface{}{})
+			if err != nil {
+				return
+			}
+			head, err := decodeQuantity(raw)
+			if err != nil {
+				return
+			}
+			c.recordHead(endpoint, head)
+		}()
+	}
+	wg.Wait()
+}
+
+func (c *Client) recordHead(endpoint config.Endpoint, head uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	idx := c.indexLocked(endpoint)
+	if idx < 0 {
+		return
+	}
+	c.health[idx].Head = head
+	c.health[idx].HeadAt = time.Now()
+}
+
+// bestHeadLocked returns the highest known head. Callers hold c.mu.
+func (c *Client) bestHeadLocked() uint64 {
+	var best uint64
+	for _, h := range c.health {
+		if h.Head > best {
+			best = h.Head
+		}
+	}
+	return best
+}
+
+// laggingLocked reports whether endpoint idx trails best by more than maxLag.
+// Endpoints with an unknown head are never considered lagging. Callers hold c.mu.
+func (c *Client) laggingLocked(idx int, best uint64) bool {
+	head := c.health[idx].Head
+	return c.maxLag > 0 && head > 0 && best-head > c.maxLag
+}
+
+func decodeQuantity(raw json.RawMessage) (uint64, error) {
+	var hexValue string
+	if err := json.Unmarshal(raw, &hexValue); err != nil {
+		return 0, fmt.Errorf("decode quantity: %w", err)
+	}
+	return eth.ParseQuantity(hexValue)
+}
@@ -0,0 +1,124 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/eth"
+)
+
+// PendingFilter is a pending-transaction filter installed on one endpoint.
+// Filters are state of the node that installed them, so every poll goes back
+// to that endpoint rather than rotating through the pool. A PendingFilter is
+// not safe for concurrent use.
+type PendingFilter struct {
+	client   *Client
+	endpoint config.Endpoint
+	id       string
+	// retry holds the hashes whose lookup failed in the previous call to
+	// Changes, looked up once more with the next.
+	retry []string
+}
+
+// NewPendingFilter installs an eth_newPendingTransactionFilter on the first
+// endpoint that supports it.
+func (c *Client) NewPendingFilter(ctx context.Context) (*PendingFilter, error) {
+	raw, endpoint, err := c.Call(ctx, "eth_newPendingTransactionFilter", []interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	var id string
+	if err := json.Unmarshal(raw, &id); err != nil {
+		return nil, fmt.Errorf("decode filter id: %w", err)
+	}
+	return &PendingFilter{client: c, endpoint: endpoint, id: id}, nil
+}
+
+// Endpoint returns the endpoint holding the filter.
+func (f *PendingFilter) Endpoint() config.Endpoint {
+	return f.endpoint
+}
+
+// Changes returns the transactions that entered the endpoint's pool since the
+// previous call. Transactions that left the pool before they could be read
+// are skipped. A transaction whose lookup fails is looked up again with the
+// next call and skipped if it fails again. An error means the filter may be
+// gone, as nodes expire idle filters, and a new one should be installed.
+func (f *PendingFilter) Changes(ctx context.Context) ([]eth.Transaction, error) {
+	raw, err := f.client.callEndpoint(ctx, f.endpoint, "eth_getFilterChanges", []interface{}{f.id})
+	if err != nil {
+		return nil, err
+	}
+	var hashes []string
+	if err := json.Unmarshal(raw, &hashes); err != nil {
+		return nil, fmt.Errorf("decode filter changes: %w", err)
+	}
+	retried := len(f.retry)
+	hashes, f.retry = append(f.retry, hashes...), nil
+	txs := make([]eth.Transaction, 0, len(hashes))
+	for i, hash := range hashes {
+		raw, err := f.client.callEndpoint(ctx, f.endpoint, "eth_getTransactionByHash", []interface{}{hash})
+		if err == nil && string(raw) == "null" {
+			continue
+		}
+		var tx eth.Transaction
+		if err == nil {
+			tx, err = eth.DecodeTransaction(raw)
+		}
+		if err != nil {
+			if i >= retried {
+				f.retry = append(f.retry, hash)
+			}
+			continue
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
+
+// Uninstall removes the filter from its endpoint.
+func (f *PendingFilter) Uninstall(ctx context.Context) error {
+	_, err := f.client.callEndpoint(ctx, f.endpoint, "eth_uninstallFilter", []interface{}{f.id})
+	return err
+}
+
+// TxPoolContent returns the executable transactions in a node's pool, read
+// with txpool_content, which usually only a local node exposes. Queued
+// transactions, which wait on a nonce gap, are left out. The rest are
+// ordered by sender and nonce.
+func (c *Client) TxPoolContent(ctx context.Context) ([]eth.Transaction, config.Endpoint, error) {
+	raw, endpoint, err := c.Call(ctx, "txpool_content", []interface{}{})
+	if err != nil {
+		return nil, endpoint, err
+	}
+	var content struct {
+		Pending map[string]map[string]json.RawMessage `json:"pending"`
+	}
+	if err := json.Unmarshal(raw, &content); err != nil {
+		return nil, endpoint, fmt.Errorf("decode txpool content: %w", err)
+	}
+	if content.Pending == nil {
+		return nil, endpoint, errors.New("txpool content without pending transactions")
+	}
+	var txs []eth.Transaction
+	for _, byNonce := range content.Pending {
+		for _, rawTx := range byNonce {
+			tx, err := eth.DecodeTransaction(rawTx)
+			if err != nil {
+				return nil, endpoint, err
+			}
+			txs = append(txs, tx)
+		}
+	}
+	sort.Slice(txs, func(i, j int) bool {
+		if txs[i].From != txs[j].From {
+			return txs[i].From < txs[j].From
+		}
+		return txs[i].Nonce < txs[j].Nonce
+	})
+	return txs, endpoint, nil
+}
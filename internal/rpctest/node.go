@@ -0,0 +1,964 @@
+// Package rpctest provides a deterministic fake Ethereum JSON-RPC node for tests.
+//
+// A Node serves eth_chainId, eth_blockNumber, eth_getBlockByNumber, eth_getLogs,
+// eth_getTransactionByHash, eth_getTransactionReceipt, txpool_content,
+// pending-transaction filters, eth_getCode and USDC balanceOf, allowance,
+// totalSupply, isBlacklisted and paused through eth_call, optionally batched
+// by a Multicall3 aggregate3 call, from scripted state, and can inject
+// faults such as latency, HTTP errors, JSON-RPC errors and malformed replies.
+// Diverging nodes are modelled by scripting several Nodes differently, and
+// chain reorganisations by Reorg.
+package rpctest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/eth"
+	"usdc-watch/internal/usdc"
+)
+
+// MainnetChainID is the chain ID a Node reports unless changed.
+const MainnetChainID = 1
+
+// GenesisTime is the timestamp of block 0; each block adds BlockTime.
+var GenesisTime = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// BlockTime is the spacing between synthetic block timestamps.
+const BlockTime = 12 * time.Second
+
+// RPCError is a JSON-RPC error object returned by an injected fault.
+type RPCError struct {
+	Code    int
+	Message string
+}
+
+// Fault describes a misbehaviour applied to matching requests.
+type Fault struct {
+	// Methods restricts the fault to these JSON-RPC methods; empty matches all.
+	Methods []string
+	// Times limits how many requests the fault applies to; zero means forever.
+	Times int
+
+	Latency    time.Duration
+	HTTPStatus int
+	RPCError   *RPCError
+	Malformed  bool
+}
+
+func (f Fault) matches(method string) bool {
+	if len(f.Methods) == 0 {
+		return true
+	}
+	for _, m := range f.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+type balanceChange struct {
+	block  uint64
+	amount *big.Int
+}
+
+type flagChange struct {
+	block uint64
+	set   bool
+}
+
+// Node is a scripted JSON-RPC server. All methods are safe for concurrent use.
+type Node struct {
+	server *httptest.Server
+
+	mu           sync.Mutex
+	chainID      uint64
+	head         uint64
+	balances     map[string][]balanceChange
+	allowances   map[string][]balanceChange
+	blacklist    map[string][]flagChange
+	paused       []flagChange
+	supply       *big.Int
+	minted       []balanceChange
+	logs         []eth.Log
+	hashes       map[uint64]string
+	transactions map[string]Transaction
+	pending      []string
+	filters      map[string]int
+	multicall    string
+	forks        []uint64
+	faults       []*Fault
+	requests     map[string]int
+	calls        []CallHandler
+}
+
+// CallHandler answers eth_call requests not handled by the node itself. It
+// returns the hex result and true when it handled the call.
+type CallHandler func(to, data string, block uint64) (string, bool)
+
+// NewNode starts a node at head block 1 that is shut down when the test ends.
+func NewNode(t testing.TB) *Node {
+	t.Helper()
+	n := &Node{
+		chainID:      MainnetChainID,
+		head:         1,
+		balances:     make(map[string][]balanceChange),
+		allowances:   make(map[string][]balanceChange),
+		blacklist:    make(map[string][]flagChange),
+		supply:       new(big.Int),
+		hashes:       make(map[uint64]string),
+		transactions: make(map[string]Transaction),
+		filters:      make(map[string]int),
+		requests:     make(map[string]int),
+	}
+	n.server = httptest.NewServer(http.HandlerFunc(n.serveHTTP))
+	t.Cleanup(n.server.Close)
+	return n
+}
+
+// URL returns the node's HTTP address.
+func (n *Node) URL() string {
+	return n.server.URL
+}
+
+// Endpoint returns a config.Endpoint pointing at the node.
+func (n *Node) Endpoint(name string) config.Endpoint {
+	return config.Endpoint{Name: name, URL: n.server.URL}
+}
+
+// SetChainID changes the chain ID reported by eth_chainId.
+func (n *Node) SetChainID(id uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.chainID = id
+}
+
+// SetHead sets the current head block.
+func (n *Node) SetHead(block uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.head = block
+}
+
+// Mine advances the head by count blocks and returns the new head.
+func (n *Node) Mine(count uint64) uint64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.head += count
+	return n.head
+}
+
+// Head returns the current head block.
+func (n *Node) Head() uint64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.head
+}
+
+// SetBalance scripts the USDC balance (base units) of address from block onward.
+func (n *Node) SetBalance(address string, fromBlock uint64, amount *big.Int) {
+	address = mustNormalize(address)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	changes := append(n.balances[address], balanceChange{block: fromBlock, amount: new(big.Int).Set(amount)})
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].block < changes[j].block })
+	n.balances[address] = changes
+}
+
+// AddLog appends a raw log. BlockNumber must be a hex quantity.
+func (n *Node) AddLog(log eth.Log) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.logs = append(n.logs, log)
+}
+
+// AddTransfer appends a USDC Transfer log and returns it.
+func (n *Node) AddTransfer(block uint64, from, to string, value *big.Int, txHash string) eth.Log {
+	fromTopic, err := eth.AddressTopic(from)
+	if err != nil {
+		panic(err)
+	}
+	toTopic, err := eth.AddressTopic(to)
+	if err != nil {
+		panic(err)
+	}
+	return n.addContractLog(block, []string{usdc.TransferTopic, fromTopic, toTopic}, fmt.Sprintf("0x%064x", value), txHash)
+}
+
+// SetAllowance scripts the USDC allowance owner grants spender from block
+// onward and appends the matching Approval log.
+func (n *Node) SetAllowance(block uint64, owner, spender string, amount *big.Int, txHash string) eth.Log {
+	owner, spender = mustNormalize(owner), mustNormalize(spender)
+	n.mu.Lock()
+	key := owner + "/" + spender
+	changes := append(n.allowances[key], balanceChange{block: block, amount: new(big.Int).Set(amount)})
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].block < changes[j].block })
+	n.allowances[key] = changes
+	n.mu.Unlock()
+	ownerTopic, err := eth.AddressTopic(owner)
+	if err != nil {
+		panic(err)
+	}
+	spenderTopic, err := eth.AddressTopic(spender)
+	if err != nil {
+		panic(err)
+	}
+	return n.addContractLog(block, []string{usdc.ApprovalTopic, ownerTopic, spenderTopic}, fmt.Sprintf("0x%064x", amount), txHash)
+}
+
+// SetTotalSupply sets the total supply (base units) before any scripted mint or burn.
+func (n *Node) SetTotalSupply(amount *big.Int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.supply = new(big.Int).Set(amount)
+}
+
+// Mint scripts a USDC mint in block, raising the total supply and appending
+// the Mint log and the Transfer from the zero address the contract emits.
+func (n *Node) Mint(block uint64, minter, to string, amount *big.Int, txHash string) {
+	n.changeSupply(block, amount)
+	minterTopic, err := eth.AddressTopic(minter)
+	if err != nil {
+		panic(err)
+	}
+	toTopic, err := eth.AddressTopic(to)
+	if err != nil {
+		panic(err)
+	}
+	n.addContractLog(block, []string{usdc.MintTopic, minterTopic, toTopic}, fmt.Sprintf("0x%064x", amount), txHash)
+	n.AddTransfer(block, usdc.ZeroAddress, to, amount, txHash)
+}
+
+// Burn scripts a USDC burn in block, lowering the total supply and appending
+// the Burn log and the Transfer to the zero address the contract emits.
+func (n *Node) Burn(block uint64, burner string, amount *big.Int, txHash string) {
+	n.changeSupply(block, new(big.Int).Neg(amount))
+	burnerTopic, err := eth.AddressTopic(burner)
+	if err != nil {
+		panic(err)
+	}
+	n.addContractLog(block, []string{usdc.BurnTopic, burnerTopic}, fmt.Sprintf("0x%064x", amount), txHash)
+	n.AddTransfer(block, burner, usdc.ZeroAddress, amount, txHash)
+}
+
+func (n *Node) changeSupply(block uint64, delta *big.Int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.minted = append(n.minted, balanceChange{block: block, amount: new(big.Int).Set(delta)})
+}
+
+func (n *Node) supplyAtLocked(block uint64) *big.Int {
+	total := new(big.Int).Set(n.supply)
+	for _, change := range n.minted {
+		if change.block <= block {
+			total.Add(total, change.amount)
+		}
+	}
+	return total
+}
+
+// SetBlacklisted scripts the USDC blacklist status of address from block
+// onward and appends the matching Blacklisted or UnBlacklisted log.
+func (n *Node) SetBlacklisted(block uint64, address string, blacklisted bool, txHash string) eth.Log {
+	address = mustNormalize(address)
+	n.mu.Lock()
+	n.blacklist[address] = insertFlag(n.blacklist[address], flagChange{block: block, set: blacklisted})
+	n.mu.Unlock()
+	topic, err := eth.AddressTopic(address)
+	if err != nil {
+		panic(err)
+	}
+	event := usdc.UnBlacklistedTopic
+	if blacklisted {
+		event = usdc.BlacklistedTopic
+	}
+	return n.addContractLog(block, []string{event, topic}, "0x", txHash)
+}
+
+// SetPaused scripts the USDC paused state from block onward and appends the
+// matching Pause or Unpause log.
+func (n *Node) SetPaused(block uint64, paused bool, txHash string) eth.Log {
+	n.mu.Lock()
+	n.paused = insertFlag(n.paused, flagChange{block: block, set: paused})
+	n.mu.Unlock()
+	event := usdc.UnpauseTopic
+	if paused {
+		event = usdc.PauseTopic
+	}
+	return n.addContractLog(block, []string{event}, "0x", txHash)
+}
+
+func insertFlag(changes []flagChange, change flagChange) []flagChange {
+	changes = append(changes, change)
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].block < changes[j].block })
+	return changes
+}
+
+// addContractLog appends a log emitted by the USDC contract in block, indexed
+// after the logs already in that block.
+func (n *Node) addContractLog(block uint64, topics []string, data, txHash string) eth.Log {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	index := 0
+	for _, l := range n.logs {
+		if l.BlockNumber == eth.FormatQuantity(block) {
+			index++
+		}
+	}
+	log := eth.Log{
+		Address:     usdc.ContractAddress,
+		Topics:      topics,
+		Data:        data,
+		BlockNumber: eth.FormatQuantity(block),
+		BlockHash:   n.blockHashLocked(block),
+		TxHash:      txHash,
+		LogIndex:    eth.FormatQuantity(uint64(index)),
+	}
+	n.logs = append(n.logs, log)
+	return log
+}
+
+// SetBlockHash overrides the hash reported for block, e.g. to simulate a reorg.
+func (n *Node) SetBlockHash(block uint64, hash string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.hashes[block] = hash
+}
+
+// BlockHash returns the hash reported for block.
+func (n *Node) BlockHash(block uint64) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.blockHashLocked(block)
+}
+
+func (n *Node) blockHashLocked(block uint64) string {
+	if hash, ok := n.hashes[block]; ok {
+		return hash
+	}
+	branch := 0
+	for _, fork := range n.forks {
+		if block > fork {
+			branch++
+		}
+	}
+	return fmt.Sprintf("0x%02x%062x", branch, block+1)
+}
+
+// Reorg replaces the chain after fork: scripted balances, allowances, status
+// changes, mints, burns and logs of later blocks are dropped and those blocks
+// get new hashes. The head is unchanged; script the new branch afterwards.
+func (n *Node) Reorg(fork uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.forks = append(n.forks, fork)
+	for block := range n.hashes {
+		if block > fork {
+			delete(n.hashes, block)
+		}
+	}
+	for _, changes := range []map[string][]balanceChange{n.balances, n.allowances} {
+		for key, list := range changes {
+			changes[key] = keepAmounts(list, fork)
+		}
+	}
+	for key, list := range n.blacklist {
+		n.blacklist[key] = keepFlags(list, fork)
+	}
+	n.paused = keepFlags(n.paused, fork)
+	n.minted = keepAmounts(n.minted, fork)
+	logs := n.logs[:0]
+	for _, log := range n.logs {
+		if block, err := eth.ParseQuantity(log.BlockNumber); err == nil && block <= fork {
+			logs = append(logs, log)
+		}
+	}
+	n.logs = logs
+}
+
+func keepAmounts(changes []balanceChange, fork uint64) []balanceChange {
+	kept := changes[:0]
+	for _, change := range changes {
+		if change.block <= fork {
+			kept = append(kept, change)
+		}
+	}
+	return kept
+}
+
+func keepFlags(changes []flagChange, fork uint64) []flagChange {
+	kept := changes[:0]
+	for _, change := range changes {
+		if change.block <= fork {
+			kept = append(kept, change)
+		}
+	}
+	return kept
+}
+
+// Transaction is a scripted transaction served by eth_getTransactionByHash
+// and eth_getTransactionReceipt. Its receipt carries the node's logs with the
+// same hash unless it failed. A transaction at block zero is pending: it has
+// no receipt and is listed by txpool_content and pending-transaction filters.
+type Transaction struct {
+	Hash    string
+	Block   uint64
+	From    string
+	To      string
+	Input   string
+	Nonce   uint64
+	GasUsed uint64
+	Failed  bool
+}
+
+// AddTransaction scripts a transaction. Adding a pending transaction again
+// with a block mines it.
+func (n *Node) AddTransaction(tx Transaction) {
+	tx.Hash = strings.ToLower(tx.Hash)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, known := n.transactions[tx.Hash]; !known && tx.Block == 0 {
+		n.pending = append(n.pending, tx.Hash)
+	}
+	n.transactions[tx.Hash] = tx
+}
+
+// RemoveTransaction forgets a transaction, as a node does when it evicts a
+// pending transaction from its pool.
+func (n *Node) RemoveTransaction(hash string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.transactions, strings.ToLower(hash))
+}
+
+// DeployMulticall serves Multicall3's aggregate3 at address, running each
+// sub-call as an eth_call at the same block. Without it, aggregate3 is only
+// served at addresses given code by an eth_call state override.
+func (n *Node) DeployMulticall(address string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.multicall = strings.ToLower(address)
+}
+
+// HandleCalls registers a handler for eth_call requests the node does not answer itself.
+func (n *Node) HandleCalls(handler CallHandler) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.calls = append(n.calls, handler)
+}
+
+// InjectFault adds a fault. Faults are applied in the order they were added.
+func (n *Node) InjectFault(f Fault) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.faults = append(n.faults, &f)
+}
+
+// ClearFaults removes every injected fault.
+func (n *Node) ClearFaults() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.faults = nil
+}
+
+// Requests returns how many requests for method the node has received.
+func (n *Node) Requests(method string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.requests[method]
+}
+
+type request struct {
+	JSONRPC string            `json:"jsonrpc"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+	ID      json.RawMessage   `json:"id"`
+}
+
+type rpcErrorBody struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcErrorBody   `json:"error,omitempty"`
+}
+
+func (n *Node) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	n.mu.Lock()
+	n.requests[req.Method]++
+	fault := n.takeFaultLocked(req.Method)
+	n.mu.Unlock()
+
+	if fault != nil {
+		if fault.Latency > 0 {
+			select {
+			case <-time.After(fault.Latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		switch {
+		case fault.HTTPStatus != 0:
+			http.Error(w, http.StatusText(fault.HTTPStatus), fault.HTTPStatus)
+			return
+		case fault.Malformed:
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"jsonrpc":"2.0","result":`)
+			return
+		case fault.RPCError != nil:
+			writeResponse(w, response{JSONRPC: "2.0", ID: req.ID, Error: &rpcErrorBody{Code: fault.RPCError.Code, Message: fault.RPCError.Message}})
+			return
+		}
+	}
+
+	result, rpcErr := n.dispatch(req)
+	resp := response{JSONRPC: "2.0", ID: req.ID, Result: result}
+	if rpcErr != nil {
+		resp.Result = nil
+		resp.Error = rpcErr
+	}
+	writeResponse(w, resp)
+}
+
+// takeFaultLocked returns the first fault matching method, consuming one use.
+func (n *Node) takeFaultLocked(method string) *Fault {
+	for i, f := range n.faults {
+		if !f.matches(method) {
+			continue
+		}
+		applied := *f
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				n.faults = append(n.faults[:i:i], n.faults[i+1:]...)
+			}
+		}
+		return &applied
+	}
+	return nil
+}
+
+func writeResponse(w http.ResponseWriter, resp response) {
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Result == nil && resp.Error == nil {
+		// Preserve an explicit null result, e.g. for unknown blocks.
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":null}`, resp.ID)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (n *Node) dispatch(req request) (interface{}, *rpcErrorBody) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	switch req.Method {
+	case "eth_chainId":
+		return eth.FormatQuantity(n.chainID), nil
+	case "eth_blockNumber":
+		return eth.FormatQuantity(n.head), nil
+	case "eth_getBlockByNumber":
+		var tag string
+		if len(req.Params) < 1 || json.Unmarshal(req.Params[0], &tag) != nil {
+			return nil, invalidParams("expected block tag")
+		}
+		block, err := n.resolveBlockLocked(tag)
+		if err != nil {
+			return nil, nil
+		}
+		return n.headerLocked(block), nil
+	case "eth_call":
+		return n.callLocked(req.Params)
+	case "eth_getCode":
+		var address string
+		if len(req.Params) < 1 || json.Unmarshal(req.Params[0], &address) != nil {
+			return nil, invalidParams("expected address")
+		}
+		address = strings.ToLower(address)
+		if address == strings.ToLower(usdc.ContractAddress) || (n.multicall != "" && address == n.multicall) {
+			return "0x6080604052", nil
+		}
+		return "0x", nil
+	case "eth_getLogs":
+		return n.getLogsLocked(req.Params)
+	case "eth_getTransactionByHash", "eth_getTransactionReceipt":
+		var hash string
+		if len(req.Params) < 1 || json.Unmarshal(req.Params[0], &hash) != nil {
+			return nil, invalidParams("expected transaction hash")
+		}
+		tx, ok := n.transactions[strings.ToLower(hash)]
+		if !ok {
+			return nil, nil
+		}
+		if req.Method == "eth_getTransactionByHash" {
+			return n.transactionLocked(tx), nil
+		}
+		if tx.Block == 0 {
+			return nil, nil
+		}
+		return n.receiptLocked(tx), nil
+	case "txpool_content":
+		pending := make(map[string]map[string]interface{})
+		for _, tx := range n.transactions {
+			if tx.Block != 0 {
+				continue
+			}
+			from := strings.ToLower(tx.From)
+			if pending[from] == nil {
+				pending[from] = make(map[string]interface{})
+			}
+			pending[from][fmt.Sprint(tx.Nonce)] = n.transactionLocked(tx)
+		}
+		return map[string]interface{}{"pending": pending, "queued": map[string]interface{}{}}, nil
+	case "eth_newPendingTransactionFilter":
+		id := eth.FormatQuantity(uint64(len(n.filters) + 1))
+		n.filters[id] = len(n.pending)
+		return id, nil
+	case "eth_getFilterChanges", "eth_uninstallFilter":
+		var id string
+		if len(req.Params) < 1 || json.Unmarshal(req.Params[0], &id) != nil {
+			return nil, invalidParams("expected filter id")
+		}
+		delivered, ok := n.filters[id]
+		if req.Method == "eth_uninstallFilter" {
+			delete(n.filters, id)
+			return ok, nil
+		}
+		if !ok {
+			return nil, &rpcErrorBody{Code: -32000, Message: "filter not found"}
+		}
+		n.filters[id] = len(n.pending)
+		return append([]string{}, n.pending[delivered:]...), nil
+	default:
+		return nil, &rpcErrorBody{Code: -32601, Message: "method not found"}
+	}
+}
+
+func (n *Node) transactionLocked(tx Transaction) map[string]interface{} {
+	var block interface{}
+	if tx.Block != 0 {
+		block = eth.FormatQuantity(tx.Block)
+	}
+	return map[string]interface{}{
+		"hash":        tx.Hash,
+		"blockNumber": block,
+		"from":        tx.From,
+		"to":          tx.To,
+		"input":       tx.Input,
+		"value":       "0x0",
+		"nonce":       eth.FormatQuantity(tx.Nonce),
+	}
+}
+
+func (n *Node) receiptLocked(tx Transaction) map[string]interface{} {
+	status := "0x1"
+	logs := []eth.Log{}
+	if tx.Failed {
+		status = "0x0"
+	} else {
+		for _, log := range n.logs {
+			if strings.EqualFold(log.TxHash, tx.Hash) {
+				logs = append(logs, log)
+			}
+		}
+	}
+	return map[string]interface{}{
+		"transactionHash":   tx.Hash,
+		"blockNumber":       eth.FormatQuantity(tx.Block),
+		"blockHash":         n.blockHashLocked(tx.Block),
+		"from":              tx.From,
+		"to":                tx.To,
+		"contractAddress":   nil,
+		"gasUsed":           eth.FormatQuantity(tx.GasUsed),
+		"effectiveGasPrice": "0x3b9aca00",
+		"status":            status,
+		"logs":              logs,
+	}
+}
+
+func (n *Node) headerLocked(block uint64) eth.Header {
+	var parent string
+	if block > 0 {
+		parent = n.blockHashLocked(block - 1)
+	} else {
+		parent = "0x" + strings.Repeat("0", 64)
+	}
+	return eth.Header{
+		Number:     eth.FormatQuantity(block),
+		Hash:       n.blockHashLocked(block),
+		ParentHash: parent,
+		Timestamp:  eth.FormatQuantity(uint64(GenesisTime.Add(time.Duration(block) * BlockTime).Unix())),
+	}
+}
+
+// resolveBlockLocked maps a block tag to a number, rejecting blocks past the head.
+func (n *Node) resolveBlockLocked(tag string) (uint64, error) {
+	switch tag {
+	case "latest", "pending", "safe", "finalized":
+		return n.head, nil
+	case "earliest":
+		return 0, nil
+	}
+	block, err := eth.ParseQuantity(tag)
+	if err != nil {
+		return 0, err
+	}
+	if block > n.head {
+		return 0, fmt.Errorf("header not found")
+	}
+	return block, nil
+}
+
+func (n *Node) callLocked(params []json.RawMessage) (interface{}, *rpcErrorBody) {
+	var call struct {
+		To   string `json:"to"`
+		Data string `json:"data"`
+	}
+	if len(params) < 1 || json.Unmarshal(params[0], &call) != nil {
+		return nil, invalidParams("expected call object")
+	}
+	tag := "latest"
+	if len(params) > 1 {
+		if err := json.Unmarshal(params[1], &tag); err != nil {
+			return nil, invalidParams("expected block tag")
+		}
+	}
+	block, err := n.resolveBlockLocked(tag)
+	if err != nil {
+		return nil, &rpcErrorBody{Code: -32000, Message: err.Error()}
+	}
+	// A state override placing code at the called address deploys Multicall3
+	// there for this call.
+	var overrides map[string]struct {
+		Code string `json:"code"`
+	}
+	if len(params) > 2 {
+		if err := json.Unmarshal(params[2], &overrides); err != nil {
+			return nil, invalidParams("expected state overrides")
+		}
+	}
+	to, data := strings.ToLower(call.To), strings.ToLower(call.Data)
+	overridden := false
+	for address, override := range overrides {
+		overridden = overridden || (strings.EqualFold(address, to) && override.Code != "")
+	}
+	if (overridden || (n.multicall != "" && to == n.multicall)) && strings.HasPrefix(data, "0x82ad56cb") {
+		return n.aggregate3Locked(data, block)
+	}
+	return n.evalCallLocked(to, data, block)
+}
+
+// evalCallLocked answers an eth_call of data to the address to at block.
+func (n *Node) evalCallLocked(to, data string, block uint64) (interface{}, *rpcErrorBody) {
+	if strings.EqualFold(to, usdc.ContractAddress) && strings.HasPrefix(data, "0x70a08231") && len(data) == 2+8+64 {
+		address := "0x" + data[len(data)-40:]
+		return fmt.Sprintf("0x%064x", n.balanceAtLocked(address, block)), nil
+	}
+	if strings.EqualFold(to, usdc.ContractAddress) && strings.HasPrefix(data, "0xdd62ed3e") && len(data) == 2+8+128 {
+		key := "0x" + data[2+8+24:2+8+64] + "/0x" + data[len(data)-40:]
+		return fmt.Sprintf("0x%064x", amountAt(n.allowances[key], block)), nil
+	}
+	if strings.EqualFold(to, usdc.ContractAddress) && strings.HasPrefix(data, "0xfe575a87") && len(data) == 2+8+64 {
+		address := "0x" + data[len(data)-40:]
+		return boolWord(flagAt(n.blacklist[address], block)), nil
+	}
+	if strings.EqualFold(to, usdc.ContractAddress) && data == usdc.EncodeTotalSupplyCall() {
+		return fmt.Sprintf("0x%064x", n.supplyAtLocked(block)), nil
+	}
+	if strings.EqualFold(to, usdc.ContractAddress) && data == usdc.EncodePausedCall() {
+		return boolWord(flagAt(n.paused, block)), nil
+	}
+	for _, handler := range n.calls {
+		if result, ok := handler(to, data, block); ok {
+			return result, nil
+		}
+	}
+	return nil, &rpcErrorBody{Code: -32000, Message: "execution reverted"}
+}
+
+// aggregate3Locked runs the sub-calls of Multicall3 aggregate3 call data,
+// each allowed to fail, and encodes their (bool, bytes)[] results.
+func (n *Node) aggregate3Locked(data string, block uint64) (interface{}, *rpcErrorBody) {
+	args := data[2+8:]
+	word := func(offset int) (int, bool) {
+		if offset < 0 || 2*offset+64 > len(args) {
+			return 0, false
+		}
+		var v int
+		_, err := fmt.Sscanf(args[2*offset:2*offset+64], "%x", &v)
+		return v, err == nil
+	}
+	array, ok := word(0)
+	count, ok2 := word(array)
+	if !ok || !ok2 {
+		return nil, invalidParams("malformed aggregate3 call")
+	}
+	var offsets, tuples strings.Builder
+	offset := 32 * count
+	for i := 0; i < count; i++ {
+		rel, ok := word(array + 32 + 32*i)
+		tuple := array + 32 + rel
+		bytesRel, ok2 := word(tuple + 64)
+		length, ok3 := word(tuple + bytesRel)
+		start := 2 * (tuple + bytesRel + 32)
+		if !ok || !ok2 || !ok3 || start+2*length > len(args) {
+			return nil, invalidParams("malformed aggregate3 call")
+		}
+		target := "0x" + args[2*tuple+24:2*tuple+64]
+		result, failure := n.evalCallLocked(target, "0x"+args[start:start+2*length], block)
+		success, returned := 1, ""
+		if failure != nil {
+			success = 0
+		} else {
+			returned = strings.TrimPrefix(result.(string), "0x")
+		}
+		encoded := fmt.Sprintf("%064x%064x%064x", success, 64, len(returned)/2) + returned
+		if rem := len(returned) % 64; rem != 0 {
+			encoded += strings.Repeat("0", 64-rem)
+		}
+		offsets.WriteString(fmt.Sprintf("%064x", offset))
+		tuples.WriteString(encoded)
+		offset += len(encoded) / 2
+	}
+	return fmt.Sprintf("0x%064x%064x", 32, count) + offsets.String() + tuples.String(), nil
+}
+
+func (n *Node) balanceAtLocked(address string, block uint64) *big.Int {
+	return amountAt(n.balances[address], block)
+}
+
+func amountAt(changes []balanceChange, block uint64) *big.Int {
+	amount := new(big.Int)
+	for _, change := range changes {
+		if change.block > block {
+			break
+		}
+		amount = change.amount
+	}
+	return amount
+}
+
+func flagAt(changes []flagChange, block uint64) bool {
+	set := false
+	for _, change := range changes {
+		if change.block > block {
+			break
+		}
+		set = change.set
+	}
+	return set
+}
+
+func boolWord(value bool) string {
+	if value {
+		return fmt.Sprintf("0x%064x", 1)
+	}
+	return fmt.Sprintf("0x%064x", 0)
+}
+
+func (n *Node) getLogsLocked(params []json.RawMessage) (interface{}, *rpcErrorBody) {
+	var filter struct {
+		FromBlock string            `json:"fromBlock"`
+		ToBlock   string            `json:"toBlock"`
+		Address   string            `json:"address"`
+		Topics    []json.RawMessage `json:"topics"`
+	}
+	if len(params) < 1 || json.Unmarshal(params[0], &filter) != nil {
+		return nil, invalidParams("expected filter object")
+	}
+	from, to := n.head, n.head
+	var err error
+	if filter.FromBlock != "" {
+		if from, err = n.resolveBlockLocked(filter.FromBlock); err != nil {
+			return nil, invalidParams(err.Error())
+		}
+	}
+	if filter.ToBlock != "" {
+		if to, err = n.resolveBlockLocked(filter.ToBlock); err != nil {
+			return nil, invalidParams(err.Error())
+		}
+	}
+	topics := make([][]string, len(filter.Topics))
+	for i, raw := range filter.Topics {
+		var single string
+		var multiple []string
+		switch {
+		case string(raw) == "null":
+		case json.Unmarshal(raw, &single) == nil:
+			topics[i] = []string{single}
+		case json.Unmarshal(raw, &multiple) == nil:
+			topics[i] = multiple
+		default:
+			return nil, invalidParams("invalid topic filter")
+		}
+	}
+
+	out := []eth.Log{}
+	for _, log := range n.logs {
+		block, err := eth.ParseQuantity(log.BlockNumber)
+		if err != nil || block < from || block > to {
+			continue
+		}
+		if filter.Address != "" && !strings.EqualFold(filter.Address, log.Address) {
+			continue
+		}
+		if !topicsMatch(topics, log.Topics) {
+			continue
+		}
+		out = append(out, log)
+	}
+	return out, nil
+}
+
+func topicsMatch(filter [][]string, topics []string) bool {
+	for i, alternatives := range filter {
+		if len(alternatives) == 0 {
+			continue
+		}
+		if i >= len(topics) {
+			return false
+		}
+		matched := false
+		for _, want := range alternatives {
+			if strings.EqualFold(want, topics[i]) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func invalidParams(message string) *rpcErrorBody {
+	return &rpcErrorBody{Code: -32602, Message: message}
+}
+
+func mustNormalize(address string) string {
+	normalized, err := eth.NormalizeAddress(address)
+	if err != nil {
+		panic(err)
+	}
+	return normalized
+}
@@ -0,0 +1,273 @@
+package rpctest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"usdc-watch/internal/config"
+	"usdc-watch/internal/eth"
+	"usdc-watch/internal/rpc"
+	"usdc-watch/internal/usdc"
+)
+
+const (
+	alice = "0x00000000000000000000000000000000000000a1"
+	bob   = "0x00000000000000000000000000000000000000b0"
+)
+
+func newClient(t *testing.T, nodes ...*Node) *rpc.Client {
+	t.Helper()
+	var endpoints []config.Endpoint
+	for i, n := range nodes {
+		endpoints = append(endpoints, n.Endpoint(string(rune('a'+i))))
+	}
+	client, err := rpc.NewClient(endpoints, &http.Client{Timeout: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	return client
+}
+
+func balanceAt(t *testing.T, client *rpc.Client, address, tag string) (*big.Int, error) {
+	t.Helper()
+	data, err := usdc.EncodeBalanceOfCall(address)
+	if err != nil {
+		t.Fatalf("EncodeBalanceOfCall error: %v", err)
+	}
+	raw, _, err := client.Call(context.Background(), "eth_call", []interface{}{map[string]string{"to": usdc.ContractAddress, "data": data}, tag})
+	if err != nil {
+		return nil, err
+	}
+	var hexValue string
+	if err := json.Unmarshal(raw, &hexValue); err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	value, _ := new(big.Int).SetString(strings.TrimPrefix(hexValue, "0x"), 16)
+	return value, nil
+}
+
+func TestNodeScriptedState(t *testing.T) {
+	node := NewNode(t)
+	node.SetHead(20)
+	node.SetBalance(alice, 10, big.NewInt(5))
+	node.SetBalance(alice, 15, big.NewInt(7))
+	node.AddTransfer(15, bob, alice, big.NewInt(2), "0x01")
+	client := newClient(t, node)
+	ctx := context.Background()
+
+	if head, _, err := client.BlockNumber(ctx); err != nil || head != 20 {
+		t.Fatalf("BlockNumber = %d, %v", head, err)
+	}
+	if id, _, err := client.ChainID(ctx); err != nil || id != MainnetChainID {
+		t.Fatalf("ChainID = %d, %v", id, err)
+	}
+	for tag, want := range map[string]int64{"0x9": 0, "0xa": 5, "0xe": 5, "latest": 7} {
+		got, err := balanceAt(t, client, alice, tag)
+		if err != nil || got.Int64() != want {
+			t.Fatalf("balance at %s = %v, %v; expected %d", tag, got, err, want)
+		}
+	}
+	if _, err := balanceAt(t, client, alice, "0x64"); err == nil {
+		t.Fatalf("expected error for block past head")
+	}
+
+	topic, _ := eth.AddressTopic(alice)
+	logs, _, err := client.GetLogs(ctx, rpc.LogFilter{FromBlock: 1, ToBlock: 20, Address: usdc.ContractAddress, Topics: []interface{}{usdc.TransferTopic, nil, topic}})
+	if err != nil || len(logs) != 1 {
+		t.Fatalf("GetLogs = %v, %v", logs, err)
+	}
+	transfer, err := usdc.DecodeTransfer(logs[0])
+	if err != nil || transfer.To != alice || transfer.Value.Int64() != 2 {
+		t.Fatalf("DecodeTransfer = %+v, %v", transfer, err)
+	}
+	logs, _, err = client.GetLogs(ctx, rpc.LogFilter{FromBlock: 1, ToBlock: 20, Topics: []interface{}{usdc.TransferTopic, topic}})
+	if err != nil || len(logs) != 0 {
+		t.Fatalf("expected no logs sent by alice, got %v, %v", logs, err)
+	}
+
+	header, _, err := client.HeaderByNumber(ctx, 5)
+	if err != nil || header.ParentHash != node.BlockHash(4) {
+		t.Fatalf("HeaderByNumber = %+v, %v", header, err)
+	}
+}
+
+func TestNodeFaults(t *testing.T) {
+	broken := NewNode(t)
+	healthy := NewNode(t)
+	healthy.SetHead(42)
+	client := newClient(t, broken, healthy)
+	ctx := context.Background()
+
+	faults := []Fault{
+		{HTTPStatus: http.StatusBadGateway},
+		{RPCError: &RPCError{Code: -32005, Message: "rate limited"}},
+		{Malformed: true},
+		{Latency: 500 * time.Millisecond},
+	}
+	for _, fault := range faults {
+		broken.ClearFaults()
+		broken.InjectFault(fault)
+		head, endpoint, err := client.BlockNumber(ctx)
+		if err != nil || head != 42 || endpoint.Name != "b" {
+			t.Fatalf("fault %+v: BlockNumber = %d via %s, %v; expected failover", fault, head, endpoint.Name, err)
+		}
+		if health := client.Health(); health[0].Healthy() {
+			t.Fatalf("fault %+v: expected faulty endpoint to be unhealthy", fault)
+		}
+		// Reset rotation so the faulty node is tried first again.
+		client.BlockNumber(ctx)
+	}
+
+	broken.ClearFaults()
+	broken.InjectFault(Fault{Methods: []string{"eth_chainId"}, Times: 1, RPCError: &RPCError{Code: -1, Message: "once"}})
+	single := newClient(t, broken)
+	if _, _, err := single.ChainID(ctx); err == nil || !strings.Contains(err.Error(), "once") {
+		t.Fatalf("expected injected error, got %v", err)
+	}
+	if _, _, err := single.ChainID(ctx); err != nil {
+		t.Fatalf("fault should apply only once: %v", err)
+	}
+	if _, _, err := single.BlockNumber(ctx); err != nil {
+		t.Fatalf("fault should not apply to other methods: %v", err)
+	}
+	if broken.Requests("eth_chainId") != 2 {
+		t.Fatalf("expected 2 eth_chainId requests, got %d", broken.Requests("eth_chainId"))
+	}
+}
+
+func TestNodesDiverge(t *testing.T) {
+	first, second := NewNode(t), NewNode(t)
+	first.SetBalance(alice, 0, big.NewInt(100))
+	second.SetBalance(alice, 0, big.NewInt(90))
+
+	a, err := balanceAt(t, newClient(t, first), alice, "latest")
+	if err != nil {
+		t.Fatalf("balance error: %v", err)
+	}
+	b, err := balanceAt(t, newClient(t, second), alice, "latest")
+	if err != nil {
+		t.Fatalf("balance error: %v", err)
+	}
+	if a.Cmp(b) == 0 {
+		t.Fatalf("expected nodes to disagree, both returned %s", a)
+	}
+}
+
+func TestNodeReorg(t *testing.T) {
+	node := NewNode(t)
+	node.SetHead(20)
+	node.SetBalance(alice, 10, big.NewInt(5))
+	node.SetBalance(alice, 15, big.NewInt(7))
+	node.AddTransfer(15, bob, alice, big.NewInt(2), "0x01")
+	before14, before15 := node.BlockHash(14), node.BlockHash(15)
+
+	node.Reorg(14)
+	if node.BlockHash(14) != before14 || node.BlockHash(15) == before15 {
+		t.Fatalf("hashes after reorg: 14 %s (was %s), 15 %s (was %s)", node.BlockHash(14), before14, node.BlockHash(15), before15)
+	}
+	client := newClient(t, node)
+	if got, err := balanceAt(t, client, alice, "latest"); err != nil || got.Int64() != 5 {
+		t.Fatalf("balance after reorg = %v, %v; expected 5", got, err)
+	}
+	logs, _, err := client.GetLogs(context.Background(), rpc.LogFilter{FromBlock: 1, ToBlock: 20, Address: usdc.ContractAddress})
+	if err != nil || len(logs) != 0 {
+		t.Fatalf("expected the orphaned transfer to be dropped, got %v, %v", logs, err)
+	}
+	header, _, err := client.HeaderByNumber(context.Background(), 15)
+	if err != nil || header.ParentHash != before14 || header.Hash != node.BlockHash(15) {
+		t.Fatalf("HeaderByNumber after reorg = %+v, %v", header, err)
+	}
+}
+
+func TestNodeTransactions(t *testing.T) {
+	node := NewNode(t)
+	node.SetHead(20)
+	node.AddTransfer(15, bob, alice, big.NewInt(2), "0x01")
+	node.AddTransaction(Transaction{Hash: "0x01", Block: 15, From: bob, To: usdc.ContractAddress, Input: "0xa9059cbb", GasUsed: 51_000})
+	client := newClient(t, node)
+	ctx := context.Background()
+
+	tx, _, err := client.TransactionByHash(ctx, "0x01")
+	if err != nil || tx.From != bob || !strings.EqualFold(tx.To, usdc.ContractAddress) || tx.Block != 15 || tx.Selector() != "a9059cbb" {
+		t.Fatalf("TransactionByHash = %+v, %v", tx, err)
+	}
+	receipt, _, err := client.TransactionReceipt(ctx, "0x01")
+	if err != nil || !receipt.Success || receipt.GasUsed != 51_000 || len(receipt.Logs) != 1 {
+		t.Fatalf("TransactionReceipt = %+v, %v", receipt, err)
+	}
+	if _, _, err := client.TransactionReceipt(ctx, "0x02"); !errors.Is(err, rpc.ErrNotFound) {
+		t.Fatalf("TransactionReceipt of an unknown transaction: %v, want ErrNotFound", err)
+	}
+}
+
+func TestNodePendingTransactions(t *testing.T) {
+	node := NewNode(t)
+	node.SetHead(20)
+	client := newClient(t, node)
+	ctx := context.Background()
+	filter, err := client.NewPendingFilter(ctx)
+	if err != nil {
+		t.Fatalf("NewPendingFilter error: %v", err)
+	}
+	node.AddTransaction(Transaction{Hash: "0x01", From: bob, To: usdc.ContractAddress, Nonce: 4})
+	node.AddTransaction(Transaction{Hash: "0x02", From: alice, To: usdc.ContractAddress})
+	node.AddTransaction(Transaction{Hash: "0x03", From: bob, To: usdc.ContractAddress, Nonce: 5})
+	node.RemoveTransaction("0x02")
+
+	txs, err := filter.Changes(ctx)
+	if err != nil || len(txs) != 2 || txs[0].Hash != "0x01" || txs[1].Hash != "0x03" || txs[0].Block != 0 {
+		t.Fatalf("Changes = %+v, %v; want the two transactions still pending", txs, err)
+	}
+	if txs, err := filter.Changes(ctx); err != nil || len(txs) != 0 {
+		t.Fatalf("second Changes = %+v, %v; want none", txs, err)
+	}
+	if _, _, err := client.TransactionReceipt(ctx, "0x01"); !errors.Is(err, rpc.ErrNotFound) {
+		t.Fatalf("receipt of a pending transaction: %v, want ErrNotFound", err)
+	}
+
+	node.AddTransaction(Transaction{Hash: "0x01", Block: 20, From: bob, To: usdc.ContractAddress, Nonce: 4})
+	pool, _, err := client.TxPoolContent(ctx)
+	if err != nil || len(pool) != 1 || pool[0].Hash != "0x03" || pool[0].Nonce != 5 {
+		t.Fatalf("TxPoolContent = %+v, %v; want the transaction left pending", pool, err)
+	}
+	if receipt, _, err := client.TransactionReceipt(ctx, "0x01"); err != nil || receipt.Block != 20 {
+		t.Fatalf("receipt of the mined transaction = %+v, %v", receipt, err)
+	}
+
+	if err := filter.Uninstall(ctx); err != nil {
+		t.Fatalf("Uninstall error: %v", err)
+	}
+	if _, err := filter.Changes(ctx); err == nil {
+		t.Fatalf("expected error polling an uninstalled filter")
+	}
+}
+
+func TestPendingFilterRetriesFailedLookup(t *testing.T) {
+	node := NewNode(t)
+	node.SetHead(20)
+	client := newClient(t, node)
+	ctx := context.Background()
+	filter, err := client.NewPendingFilter(ctx)
+	if err != nil {
+		t.Fatalf("NewPendingFilter error: %v", err)
+	}
+	node.AddTransaction(Transaction{Hash: "0x01", From: bob, To: usdc.ContractAddress})
+	node.AddTransaction(Transaction{Hash: "0x02", From: alice, To: usdc.ContractAddress})
+	node.InjectFault(Fault{Methods: []string{"eth_getTransactionByHash"}, Times: 1, RPCError: &RPCError{Code: -32000, Message: "header not found"}})
+
+	txs, err := filter.Changes(ctx)
+	if err != nil || len(txs) != 1 || txs[0].Hash != "0x02" {
+		t.Fatalf("Changes = %+v, %v; want the transaction whose lookup succeeded", txs, err)
+	}
+	node.AddTransaction(Transaction{Hash: "0x03", From: bob, To: usdc.ContractAddress, Nonce: 1})
+	txs, err = filter.Changes(ctx)
+	if err != nil || len(txs) != 2 || txs[0].Hash != "0x01" || txs[1].Hash != "0x03" {
+		t.Fatalf("second Changes = %+v, %v; want the failed lookup retried", txs, err)
+	}
+}
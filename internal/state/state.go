@@ -0,0 +1,144 @@
+// Package state keeps the watcher's in-memory view of watched addresses.
+package state
+
+import (
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultHistorySize is the number of observations retained per address when none is specified.
+const DefaultHistorySize = 256
+
+// Observation is a single successful balance reading.
+type Observation struct {
+	Time     time.Time
+	Block    uint64
+	Balance  *big.Int
+	Endpoint string
+}
+
+// AddressState summarises the latest known status of a watched address.
+type AddressState struct {
+	Address     string
+	Balance     *big.Int
+	Endpoint    string
+	UpdatedAt   time.Time
+	LastError   string
+	LastErrorAt time.Time
+}
+
+// Store records balance observations per address, keeping a bounded history.
+type Store struct {
+	mu          sync.RWMutex
+	historySize int
+	addresses   map[string]*entry
+}
+
+type entry struct {
+	state   AddressState
+	history []Observation
+}
+
+// NewStore creates a store retaining up to historySize observations per address.
+func NewStore(historySize int) *Store {
+	if historySize <= 0 {
+		historySize = DefaultHistorySize
+	}
+	return &Store{historySize: historySize, addresses: make(map[string]*entry)}
+}
+
+// Track registers an address so it is reported before the first observation arrives.
+func (s *Store) Track(address string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lookup(address)
+}
+
+// Untrack forgets an address and its history.
+func (s *Store) Untrack(address string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.addresses, address)
+}
+
+// Record stores a successful observation for the address.
+func (s *Store) Record(address string, obs Observation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.lookup(address)
+	e.state.Balance = new(big.Int).Set(obs.Balance)
+	e.state.Endpoint = obs.Endpoint
+	e.state.UpdatedAt = obs.Time
+	obs.Balance = e.state.Balance
+	e.history = append(e.history, obs)
+	if len(e.history) > s.historySize {
+		e.history = append(e.history[:0:0], e.history[len(e.history)-s.historySize:]...)
+	}
+}
+
+// RecordError stores the most recent poll failure for the address.
+func (s *Store) RecordError(address string, at time.Time, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.lookup(address)
+	e.state.LastError = err.Error()
+	e.state.LastErrorAt = at
+}
+
+// Revert drops the observations of blocks after block, which a chain
+// reorganisation orphaned, and restores each address's balance from the
+// newest observation left.
+func (s *Store) Revert(block uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.addresses {
+		kept := len(e.history)
+		for kept > 0 && e.history[kept-1].Block > block {
+			kept--
+		}
+		if kept == len(e.history) {
+			continue
+		}
+		e.history = e.history[:kept]
+		e.state.Balance, e.state.Endpoint, e.state.UpdatedAt = nil, "", time.Time{}
+		if kept > 0 {
+			last := e.history[kept-1]
+			e.state.Balance, e.state.Endpoint, e.state.UpdatedAt = last.Balance, last.Endpoint, last.Time
+		}
+	}
+}
+
+// Addresses returns the current state of every tracked address, sorted by address.
+func (s *Store) Addresses() []AddressState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]AddressState, 0, len(s.addresses))
+	for _, e := range s.addresses {
+		out = append(out, e.state)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Address < out[j].Address })
+	return out
+}
+
+// History returns the retained observations for the address, oldest first.
+// The boolean reports whether the address is tracked.
+func (s *Store) History(address string) ([]Observation, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.addresses[address]
+	if !ok {
+		return nil, false
+	}
+	return append([]Observation(nil), e.history...), true
+}
+
+func (s *Store) lookup(address string) *entry {
+	e, ok := s.addresses[address]
+	if !ok {
+		e = &entry{state: AddressState{Address: address}}
+		s.addresses[address] = e
+	}
+	return e
+}
@@ -0,0 +1,64 @@
+package state
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestStoreRecord(t *testing.T) {
+	store := NewStore(2)
+	store.Track("0xb")
+	base := time.Unix(1_700_000_000, 0)
+	for i := int64(1); i <= 3; i++ {
+		store.Record("0xa", Observation{Time: base.Add(time.Duration(i) * time.Minute), Balance: big.NewInt(i), Endpoint: "primary"})
+	}
+	store.RecordError("0xa", base, errors.New("timeout"))
+
+	addresses := store.Addresses()
+	if len(addresses) != 2 {
+		t.Fatalf("expected 2 addresses, got %d", len(addresses))
+	}
+	if addresses[0].Address != "0xa" || addresses[0].Balance.Int64() != 3 {
+		t.Fatalf("unexpected latest state: %+v", addresses[0])
+	}
+	if addresses[0].LastError != "timeout" {
+		t.Fatalf("expected last error to be recorded, got %q", addresses[0].LastError)
+	}
+	if addresses[1].Balance != nil {
+		t.Fatalf("tracked address without observations should have nil balance")
+	}
+
+	history, ok := store.History("0xa")
+	if !ok {
+		t.Fatalf("expected history for tracked address")
+	}
+	if len(history) != 2 || history[0].Balance.Int64() != 2 || history[1].Balance.Int64() != 3 {
+		t.Fatalf("history not trimmed to newest entries: %+v", history)
+	}
+	if _, ok := store.History("0xc"); ok {
+		t.Fatalf("expected unknown address to be reported as untracked")
+	}
+}
+
+func TestStoreRevert(t *testing.T) {
+	store := NewStore(0)
+	base := time.Unix(1_700_000_000, 0)
+	for i := int64(1); i <= 3; i++ {
+		store.Record("0xa", Observation{Time: base.Add(time.Duration(i) * time.Minute), Block: uint64(100 + i), Balance: big.NewInt(i), Endpoint: "primary"})
+	}
+	store.Record("0xb", Observation{Time: base, Block: 103, Balance: big.NewInt(9)})
+
+	store.Revert(101)
+	addresses := store.Addresses()
+	if addresses[0].Balance.Int64() != 1 || !addresses[0].UpdatedAt.Equal(base.Add(time.Minute)) {
+		t.Fatalf("reverted state = %+v, want the block 101 balance", addresses[0])
+	}
+	if addresses[1].Balance != nil {
+		t.Fatalf("address without remaining observations should have nil balance, got %+v", addresses[1])
+	}
+	if history, _ := store.History("0xa"); len(history) != 1 || history[0].Block != 101 {
+		t.Fatalf("reverted history = %+v, want only block 101", history)
+	}
+}
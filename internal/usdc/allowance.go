@@ -0,0 +1,89 @@
+package usdc
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"usdc-watch/internal/eth"
+)
+
+const methodAllowance = "dd62ed3e"
+
+// ApprovalTopic is the keccak256 hash of Approval(address,address,uint256).
+const ApprovalTopic = "0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925"
+
+// MaxUint256 is the allowance wallets grant for an unlimited approval.
+var MaxUint256 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+// IsUnlimited reports whether amount is an unlimited allowance.
+func IsUnlimited(amount *big.Int) bool {
+	return amount != nil && amount.Cmp(MaxUint256) == 0
+}
+
+// EncodeAllowanceCall builds the data payload for an allowance(owner, spender) call.
+func EncodeAllowanceCall(owner, spender string) (string, error) {
+	ownerData, err := encodeAddressCall(methodAllowance, owner)
+	if err != nil {
+		return "", fmt.Errorf("encode owner: %w", err)
+	}
+	spenderHex, err := eth.AddressDataHex(spender)
+	if err != nil {
+		return "", fmt.Errorf("encode spender: %w", err)
+	}
+	if len(spenderHex) != 40 {
+		return "", fmt.Errorf("unexpected address length: %d", len(spenderHex))
+	}
+	return ownerData + strings.Repeat("0", 24) + spenderHex, nil
+}
+
+// DecodeAmount parses an ABI-encoded uint256 returned by an eth_call, such as
+// an allowance or a total supply.
+func DecodeAmount(data string) (*big.Int, error) {
+	return decodeWord(data)
+}
+
+// Approval is a decoded ERC-20 Approval event.
+type Approval struct {
+	Owner    string
+	Spender  string
+	Value    *big.Int
+	Block    uint64
+	TxHash   string
+	LogIndex uint64
+}
+
+// DecodeApproval decodes an Approval log emitted by the USDC contract.
+func DecodeApproval(log eth.Log) (Approval, error) {
+	if len(log.Topics) != 3 || !strings.EqualFold(log.Topics[0], ApprovalTopic) {
+		return Approval{}, fmt.Errorf("log is not an Approval event")
+	}
+	owner, err := eth.TopicAddress(log.Topics[1])
+	if err != nil {
+		return Approval{}, fmt.Errorf("decode owner: %w", err)
+	}
+	spender, err := eth.TopicAddress(log.Topics[2])
+	if err != nil {
+		return Approval{}, fmt.Errorf("decode spender: %w", err)
+	}
+	value, err := decodeWord(log.Data)
+	if err != nil {
+		return Approval{}, fmt.Errorf("decode value: %w", err)
+	}
+	block, err := eth.ParseQuantity(log.BlockNumber)
+	if err != nil {
+		return Approval{}, fmt.Errorf("decode block: %w", err)
+	}
+	index, err := eth.ParseQuantity(log.LogIndex)
+	if err != nil {
+		return Approval{}, fmt.Errorf("decode log index: %w", err)
+	}
+	return Approval{
+		Owner:    owner,
+		Spender:  spender,
+		Value:    value,
+		Block:    block,
+		TxHash:   strings.ToLower(log.TxHash),
+		LogIndex: index,
+	}, nil
+}
@@ -0,0 +1,53 @@
+package usdc
+
+import (
+	"fmt"
+	"testing"
+
+	"usdc-watch/internal/eth"
+)
+
+func TestEncodeAllowanceCall(t *testing.T) {
+	data, err := EncodeAllowanceCall("0x0000000000000000000000000000000000000001", "0x0000000000000000000000000000000000000002")
+	if err != nil {
+		t.Fatalf("EncodeAllowanceCall error: %v", err)
+	}
+	expected := "0xdd62ed3e" +
+		"0000000000000000000000000000000000000000000000000000000000000001" +
+		"0000000000000000000000000000000000000000000000000000000000000002"
+	if data != expected {
+		t.Fatalf("EncodeAllowanceCall mismatch: got %s, expected %s", data, expected)
+	}
+	if _, err := EncodeAllowanceCall("0x01", "0x0000000000000000000000000000000000000002"); err == nil {
+		t.Fatalf("expected error for a short owner address")
+	}
+}
+
+func TestDecodeApproval(t *testing.T) {
+	log := eth.Log{
+		Topics: []string{
+			ApprovalTopic,
+			"0x00000000000000000000000000000000000000000000000000000000000000a1",
+			"0x00000000000000000000000000000000000000000000000000000000000000c1",
+		},
+		Data:        fmt.Sprintf("0x%064x", MaxUint256),
+		BlockNumber: "0x5",
+		TxHash:      "0xAB",
+		LogIndex:    "0x0",
+	}
+	approval, err := DecodeApproval(log)
+	if err != nil {
+		t.Fatalf("DecodeApproval error: %v", err)
+	}
+	if approval.Owner != "0x00000000000000000000000000000000000000a1" || approval.Spender != "0x00000000000000000000000000000000000000c1" {
+		t.Fatalf("unexpected parties: %+v", approval)
+	}
+	if !IsUnlimited(approval.Value) || approval.Block != 5 || approval.TxHash != "0xab" {
+		t.Fatalf("unexpected approval fields: %+v", approval)
+	}
+
+	log.Topics[0] = TransferTopic
+	if _, err := DecodeApproval(log); err == nil {
+		t.Fatalf("expected error for a Transfer log")
+	}
+}
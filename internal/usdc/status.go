@@ -0,0 +1,106 @@
+package usdc
+
+import (
+	"fmt"
+	"strings"
+
+	"usdc-watch/internal/eth"
+)
+
+const (
+	methodIsBlacklisted = "fe575a87"
+	methodPaused        = "5c975abb"
+)
+
+// Topics of the events USDC emits when an account's blacklist status or the
+// contract's paused state changes.
+const (
+	// BlacklistedTopic is the keccak256 hash of Blacklisted(address).
+	BlacklistedTopic = "0xffa4e6181777692565cf28528fc88fd1516ea86b56da075235fa575af6a4b855"
+	// UnBlacklistedTopic is the keccak256 hash of UnBlacklisted(address).
+	UnBlacklistedTopic = "0x117e3210bb9aa7d9baff172026820255c6f6c30ba8999d1c2fd88e2848137c4e"
+	// PauseTopic is the keccak256 hash of Pause().
+	PauseTopic = "0x6985a02210a168e66602d3235cb6db0e70f92b3ba4d376a33c0f3d9434bff625"
+	// UnpauseTopic is the keccak256 hash of Unpause().
+	UnpauseTopic = "0x7805862f689e2f13df9f062ff482ad3ad112aca9e0847911ed832e158c525b33"
+)
+
+// StatusTopics lists the topics of every status event, for use as the first
+// position of a log filter.
+var StatusTopics = []string{BlacklistedTopic, UnBlacklistedTopic, PauseTopic, UnpauseTopic}
+
+// EncodeIsBlacklistedCall builds the data payload for an isBlacklisted(address) call.
+func EncodeIsBlacklistedCall(address string) (string, error) {
+	return encodeAddressCall(methodIsBlacklisted, address)
+}
+
+// EncodePausedCall builds the data payload for a paused() call.
+func EncodePausedCall() string {
+	return "0x" + methodPaused
+}
+
+// DecodeBool parses an ABI-encoded bool returned by an eth_call.
+func DecodeBool(data string) (bool, error) {
+	value, err := decodeWord(data)
+	if err != nil {
+		return false, err
+	}
+	if value.BitLen() > 1 {
+		return false, fmt.Errorf("invalid bool word: %s", data)
+	}
+	return value.Sign() != 0, nil
+}
+
+// StatusEvent is a decoded Blacklisted, UnBlacklisted, Pause or Unpause event.
+type StatusEvent struct {
+	// Account is the affected address for blacklist events and empty for
+	// pause events.
+	Account string
+	// Blacklisted and Paused report the state after the event; only the one
+	// matching the event kind is meaningful.
+	Blacklisted bool
+	Paused      bool
+	Block       uint64
+	TxHash      string
+	LogIndex    uint64
+}
+
+// IsPause reports whether the event changed the paused state rather than an
+// account's blacklist status.
+func (e StatusEvent) IsPause() bool {
+	return e.Account == ""
+}
+
+// DecodeStatusEvent decodes a status event emitted by the USDC contract.
+func DecodeStatusEvent(log eth.Log) (StatusEvent, error) {
+	if len(log.Topics) == 0 {
+		return StatusEvent{}, fmt.Errorf("log has no topics")
+	}
+	var event StatusEvent
+	switch strings.ToLower(log.Topics[0]) {
+	case BlacklistedTopic, UnBlacklistedTopic:
+		if len(log.Topics) != 2 {
+			return StatusEvent{}, fmt.Errorf("blacklist event without an indexed account")
+		}
+		account, err := eth.TopicAddress(log.Topics[1])
+		if err != nil {
+			return StatusEvent{}, fmt.Errorf("decode account: %w", err)
+		}
+		event.Account = account
+		event.Blacklisted = strings.EqualFold(log.Topics[0], BlacklistedTopic)
+	case PauseTopic, UnpauseTopic:
+		event.Paused = strings.EqualFold(log.Topics[0], PauseTopic)
+	default:
+		return StatusEvent{}, fmt.Errorf("log is not a status event")
+	}
+	block, err := eth.ParseQuantity(log.BlockNumber)
+	if err != nil {
+		return StatusEvent{}, fmt.Errorf("decode block: %w", err)
+	}
+	index, err := eth.ParseQuantity(log.LogIndex)
+	if err != nil {
+		return StatusEvent{}, fmt.Errorf("decode log index: %w", err)
+	}
+	event.Block, event.TxHash, event.LogIndex = block, strings.ToLower(log.TxHash), index
+	return event, nil
+}
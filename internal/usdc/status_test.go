@@ -0,0 +1,79 @@
+package usdc
+
+import (
+	"testing"
+
+	"usdc-watch/internal/eth"
+)
+
+func TestEncodeStatusCalls(t *testing.T) {
+	data, err := EncodeIsBlacklistedCall("0x0000000000000000000000000000000000000001")
+	if err != nil {
+		t.Fatalf("EncodeIsBlacklistedCall error: %v", err)
+	}
+	if expected := "0xfe575a870000000000000000000000000000000000000000000000000000000000000001"; data != expected {
+		t.Fatalf("EncodeIsBlacklistedCall mismatch: got %s, expected %s", data, expected)
+	}
+	if data := EncodePausedCall(); data != "0x5c975abb" {
+		t.Fatalf("EncodePausedCall mismatch: got %s", data)
+	}
+}
+
+func TestDecodeBool(t *testing.T) {
+	cases := []struct {
+		data     string
+		expected bool
+		wantErr  bool
+	}{
+		{data: "0x0000000000000000000000000000000000000000000000000000000000000000", expected: false},
+		{data: "0x0000000000000000000000000000000000000000000000000000000000000001", expected: true},
+		{data: "0x0000000000000000000000000000000000000000000000000000000000000002", wantErr: true},
+		{data: "0x", wantErr: true},
+	}
+	for _, tc := range cases {
+		got, err := DecodeBool(tc.data)
+		if tc.wantErr {
+			if err == nil {
+				t.Fatalf("DecodeBool(%q) expected error", tc.data)
+			}
+			continue
+		}
+		if err != nil || got != tc.expected {
+			t.Fatalf("DecodeBool(%q) = %v, %v", tc.data, got, err)
+		}
+	}
+}
+
+func TestDecodeStatusEvent(t *testing.T) {
+	log := eth.Log{
+		Topics:      []string{BlacklistedTopic, "0x00000000000000000000000000000000000000000000000000000000000000a1"},
+		BlockNumber: "0x20",
+		TxHash:      "0xBEEF",
+		LogIndex:    "0x1",
+	}
+	event, err := DecodeStatusEvent(log)
+	if err != nil {
+		t.Fatalf("DecodeStatusEvent error: %v", err)
+	}
+	if event.Account != "0x00000000000000000000000000000000000000a1" || !event.Blacklisted || event.IsPause() {
+		t.Fatalf("unexpected blacklist event: %+v", event)
+	}
+	if event.Block != 32 || event.LogIndex != 1 || event.TxHash != "0xbeef" {
+		t.Fatalf("unexpected event position: %+v", event)
+	}
+
+	log.Topics = []string{UnpauseTopic}
+	event, err = DecodeStatusEvent(log)
+	if err != nil || !event.IsPause() || event.Paused {
+		t.Fatalf("unexpected unpause event: %+v, %v", event, err)
+	}
+
+	log.Topics = []string{UnBlacklistedTopic}
+	if _, err := DecodeStatusEvent(log); err == nil {
+		t.Fatalf("expected error for blacklist event without an account")
+	}
+	log.Topics = []string{TransferTopic}
+	if _, err := DecodeStatusEvent(log); err == nil {
+		t.Fatalf("expected error for a Transfer log")
+	}
+}
@@ -0,0 +1,139 @@
+package usdc
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"usdc-watch/internal/eth"
+)
+
+const methodTotalSupply = "18160ddd"
+
+// ZeroAddress is the counterparty of transfers that mint or burn tokens.
+const ZeroAddress = "0x0000000000000000000000000000000000000000"
+
+const (
+	// MintTopic is the keccak256 hash of Mint(address,address,uint256).
+	MintTopic = "0xab8530f87dc9b59234c4623bf917212bb2536d647574c8e7e5da92c2ede0c9f8"
+	// BurnTopic is the keccak256 hash of Burn(address,uint256).
+	BurnTopic = "0xcc16f5dbb4873280815c1ee09dbd06736cffcc184412cf7a71a0fdb75d397ca5"
+)
+
+// EncodeTotalSupplyCall builds the data payload for a totalSupply() call.
+func EncodeTotalSupplyCall() string {
+	return "0x" + methodTotalSupply
+}
+
+// SupplyChange is a decoded mint or burn, from a Mint or Burn event or from a
+// Transfer to or from the zero address.
+type SupplyChange struct {
+	Mint bool
+	// Account receives a mint or gives up a burnt amount.
+	Account string
+	Amount  *big.Int
+	Block   uint64
+	TxHash  string
+	// LogIndex positions the change in its block.
+	LogIndex uint64
+	// Transfer reports that the change was decoded from a Transfer log.
+	Transfer bool
+}
+
+// Delta is the change in total supply: positive for mints, negative for burns.
+func (c SupplyChange) Delta() *big.Int {
+	if c.Mint {
+		return new(big.Int).Set(c.Amount)
+	}
+	return new(big.Int).Neg(c.Amount)
+}
+
+// DecodeSupplyChange decodes a Mint, Burn, or zero-address Transfer log
+// emitted by the USDC contract.
+func DecodeSupplyChange(log eth.Log) (SupplyChange, error) {
+	if len(log.Topics) == 0 {
+		return SupplyChange{}, fmt.Errorf("log has no topics")
+	}
+	var change SupplyChange
+	switch strings.ToLower(log.Topics[0]) {
+	case MintTopic:
+		if len(log.Topics) != 3 {
+			return SupplyChange{}, fmt.Errorf("mint event without indexed minter and recipient")
+		}
+		to, err := eth.TopicAddress(log.Topics[2])
+		if err != nil {
+			return SupplyChange{}, fmt.Errorf("decode recipient: %w", err)
+		}
+		change.Mint, change.Account = true, to
+	case BurnTopic:
+		if len(log.Topics) != 2 {
+			return SupplyChange{}, fmt.Errorf("burn event without an indexed burner")
+		}
+		burner, err := eth.TopicAddress(log.Topics[1])
+		if err != nil {
+			return SupplyChange{}, fmt.Errorf("decode burner: %w", err)
+		}
+		change.Account = burner
+	case TransferTopic:
+		transfer, err := DecodeTransfer(log)
+		if err != nil {
+			return SupplyChange{}, err
+		}
+		switch {
+		case transfer.From == ZeroAddress:
+			change.Mint, change.Account = true, transfer.To
+		case transfer.To == ZeroAddress:
+			change.Account = transfer.From
+		default:
+			return SupplyChange{}, fmt.Errorf("transfer neither mints nor burns")
+		}
+		change.Transfer = true
+	default:
+		return SupplyChange{}, fmt.Errorf("log is not a supply change")
+	}
+	amount, err := decodeWord(log.Data)
+	if err != nil {
+		return SupplyChange{}, fmt.Errorf("decode amount: %w", err)
+	}
+	block, err := eth.ParseQuantity(log.BlockNumber)
+	if err != nil {
+		return SupplyChange{}, fmt.Errorf("decode block: %w", err)
+	}
+	index, err := eth.ParseQuantity(log.LogIndex)
+	if err != nil {
+		return SupplyChange{}, fmt.Errorf("decode log index: %w", err)
+	}
+	change.Amount, change.Block, change.TxHash, change.LogIndex = amount, block, strings.ToLower(log.TxHash), index
+	return change, nil
+}
+
+// MergeSupplyChanges drops zero-address Transfers that accompany a Mint or
+// Burn event of the same amount in the same transaction, so each change is
+// counted once, and orders the rest by block and log index.
+func MergeSupplyChanges(changes []SupplyChange) []SupplyChange {
+	key := func(c SupplyChange) string {
+		return fmt.Sprintf("%s/%t/%s/%s", c.TxHash, c.Mint, c.Account, c.Amount)
+	}
+	events := make(map[string]int)
+	for _, c := range changes {
+		if !c.Transfer {
+			events[key(c)]++
+		}
+	}
+	merged := make([]SupplyChange, 0, len(changes))
+	for _, c := range changes {
+		if c.Transfer && events[key(c)] > 0 {
+			events[key(c)]--
+			continue
+		}
+		merged = append(merged, c)
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].Block != merged[j].Block {
+			return merged[i].Block < merged[j].Block
+		}
+		return merged[i].LogIndex < merged[j].LogIndex
+	})
+	return merged
+}
@@ -0,0 +1,64 @@
+package usdc
+
+import (
+	"math/big"
+	"testing"
+
+	"usdc-watch/internal/eth"
+)
+
+func TestDecodeSupplyChange(t *testing.T) {
+	const (
+		minterTopic = "0x00000000000000000000000000000000000000000000000000000000000000d1"
+		aliceTopic  = "0x00000000000000000000000000000000000000000000000000000000000000a1"
+		zeroTopic   = "0x0000000000000000000000000000000000000000000000000000000000000000"
+		amount      = "0x00000000000000000000000000000000000000000000000000000000000f4240"
+		alice       = "0x00000000000000000000000000000000000000a1"
+	)
+	cases := []struct {
+		name     string
+		topics   []string
+		mint     bool
+		transfer bool
+	}{
+		{name: "mint", topics: []string{MintTopic, minterTopic, aliceTopic}, mint: true},
+		{name: "burn", topics: []string{BurnTopic, aliceTopic}},
+		{name: "transfer from zero", topics: []string{TransferTopic, zeroTopic, aliceTopic}, mint: true, transfer: true},
+		{name: "transfer to zero", topics: []string{TransferTopic, aliceTopic, zeroTopic}, transfer: true},
+	}
+	for _, tc := range cases {
+		change, err := DecodeSupplyChange(eth.Log{Topics: tc.topics, Data: amount, BlockNumber: "0x9", TxHash: "0xAA", LogIndex: "0x2"})
+		if err != nil {
+			t.Fatalf("%s: DecodeSupplyChange error: %v", tc.name, err)
+		}
+		if change.Mint != tc.mint || change.Transfer != tc.transfer || change.Account != alice || change.Amount.Int64() != 1_000_000 {
+			t.Fatalf("%s: unexpected change %+v", tc.name, change)
+		}
+		if want := int64(-1_000_000); !tc.mint && change.Delta().Int64() != want {
+			t.Fatalf("%s: Delta = %s, want %d", tc.name, change.Delta(), want)
+		}
+	}
+
+	if _, err := DecodeSupplyChange(eth.Log{Topics: []string{TransferTopic, aliceTopic, minterTopic}, Data: amount, BlockNumber: "0x9", LogIndex: "0x0"}); err == nil {
+		t.Fatalf("expected error for an ordinary transfer")
+	}
+}
+
+func TestMergeSupplyChanges(t *testing.T) {
+	mint := func(block, index uint64, tx string, amount int64, transfer bool) SupplyChange {
+		return SupplyChange{Mint: true, Account: "0xa1", Amount: big.NewInt(amount), Block: block, TxHash: tx, LogIndex: index, Transfer: transfer}
+	}
+	merged := MergeSupplyChanges([]SupplyChange{
+		mint(5, 1, "0x02", 7, false),
+		mint(4, 0, "0x01", 3, false),
+		mint(4, 1, "0x01", 3, true),
+		mint(5, 0, "0x02", 7, true),
+		mint(6, 0, "0x03", 9, true),
+	})
+	if len(merged) != 3 {
+		t.Fatalf("expected 3 changes, got %+v", merged)
+	}
+	if merged[0].TxHash != "0x01" || merged[0].Transfer || merged[1].TxHash != "0x02" || merged[1].Transfer || merged[2].TxHash != "0x03" || !merged[2].Transfer {
+		t.Fatalf("unexpected merged changes: %+v", merged)
+	}
+}
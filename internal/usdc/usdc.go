@@ -18,6 +18,11 @@ const (
 
 // EncodeBalanceOfCall builds the data payload for an ERC-20 balanceOf(address) call.
 func EncodeBalanceOfCall(address string) (string, error) {
+	return encodeAddressCall(methodBalanceOf, address)
+}
+
+// encodeAddressCall builds the data payload for a call taking a single address.
+func encodeAddressCall(method, address string) (string, error) {
 	addrHex, err := eth.AddressDataHex(address)
 	if err != nil {
 		return "", err
@@ -25,7 +30,7 @@ func EncodeBalanceOfCall(address string) (string, error) {
 	if len(addrHex) != 40 {
 		return "", fmt.Errorf("unexpected address length: %d", len(addrHex))
 	}
-	return "0x" + methodBalanceOf + strings.Repeat("0", 64-len(addrHex)) + addrHex, nil
+	return "0x" + method + strings.Repeat("0", 64-len(addrHex)) + addrHex, nil
 }
 
 // ParseAmount converts a human-readable USDC amount into base units (6 decimals).
@@ -77,3 +82,109 @@ func FormatAmount(amount *big.Int) string {
 	}
 	return fmt.Sprintf("%s.%06d", intPart.String(), fracPart.Int64())
 }
+
+// TransferTopic is the keccak256 hash of Transfer(address,address,uint256).
+const TransferTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+// Transfer is a decoded ERC-20 Transfer event.
+type Transfer struct {
+	From     string
+	To       string
+	Value    *big.Int
+	Block    uint64
+	TxHash   string
+	LogIndex uint64
+}
+
+// DecodeTransfer decodes a Transfer log emitted by the USDC contract.
+func DecodeTransfer(log eth.Log) (Transfer, error) {
+	if len(log.Topics) != 3 || !strings.EqualFold(log.Topics[0], TransferTopic) {
+		return Transfer{}, fmt.Errorf("log is not a Transfer event")
+	}
+	from, err := eth.TopicAddress(log.Topics[1])
+	if err != nil {
+		return Transfer{}, fmt.Errorf("decode from: %w", err)
+	}
+	to, err := eth.TopicAddress(log.Topics[2])
+	if err != nil {
+		return Transfer{}, fmt.Errorf("decode to: %w", err)
+	}
+	value, err := decodeWord(log.Data)
+	if err != nil {
+		return Transfer{}, fmt.Errorf("decode value: %w", err)
+	}
+	block, err := eth.ParseQuantity(log.BlockNumber)
+	if err != nil {
+		return Transfer{}, fmt.Errorf("decode block: %w", err)
+	}
+	index, err := eth.ParseQuantity(log.LogIndex)
+	if err != nil {
+		return Transfer{}, fmt.Errorf("decode log index: %w", err)
+	}
+	return Transfer{
+		From:     from,
+		To:       to,
+		Value:    value,
+		Block:    block,
+		TxHash:   strings.ToLower(log.TxHash),
+		LogIndex: index,
+	}, nil
+}
+
+// Selectors of transfer(address,uint256) and transferFrom(address,address,uint256).
+const (
+	methodTransfer     = "a9059cbb"
+	methodTransferFrom = "23b872dd"
+)
+
+// DecodeTransferCall decodes a transaction calling transfer or transferFrom
+// on the USDC contract into the transfer it makes once mined: from the sender,
+// or from the owner named by transferFrom. Block is zero while tx is pending
+// and LogIndex is always zero.
+func DecodeTransferCall(tx eth.Transaction) (Transfer, error) {
+	if !strings.EqualFold(tx.To, ContractAddress) {
+		return Transfer{}, fmt.Errorf("transaction does not call the USDC contract")
+	}
+	args := strings.ToLower(strings.TrimPrefix(tx.Input, "0x"))
+	if len(args) < 8 {
+		return Transfer{}, fmt.Errorf("call data has no method selector")
+	}
+	method, args := args[:8], args[8:]
+	words := 2
+	if method == methodTransferFrom {
+		words = 3
+	} else if method != methodTransfer {
+		return Transfer{}, fmt.Errorf("call is not a transfer")
+	}
+	if len(args) < words*64 {
+		return Transfer{}, fmt.Errorf("call data has %d hex characters of arguments, want %d", len(args), words*64)
+	}
+	t := Transfer{From: tx.From, Block: tx.Block, TxHash: strings.ToLower(tx.Hash)}
+	var err error
+	if method == methodTransferFrom {
+		if t.From, err = eth.TopicAddress(args[:64]); err != nil {
+			return Transfer{}, fmt.Errorf("decode from: %w", err)
+		}
+		args = args[64:]
+	}
+	if t.To, err = eth.TopicAddress(args[:64]); err != nil {
+		return Transfer{}, fmt.Errorf("decode to: %w", err)
+	}
+	if t.Value, err = decodeWord(args[64:128]); err != nil {
+		return Transfer{}, fmt.Errorf("decode value: %w", err)
+	}
+	return t, nil
+}
+
+// decodeWord parses a single 32-byte ABI word into an unsigned integer.
+func decodeWord(data string) (*big.Int, error) {
+	trimmed := strings.TrimPrefix(data, "0x")
+	if len(trimmed) != 64 {
+		return nil, fmt.Errorf("expected 32-byte word, got %d hex characters", len(trimmed))
+	}
+	value, ok := new(big.Int).SetString(trimmed, 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid hex word: %s", data)
+	}
+	return value, nil
+}
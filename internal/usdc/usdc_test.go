@@ -2,7 +2,10 @@ package usdc
 
 import (
 	"math/big"
+	"strings"
 	"testing"
+
+	"usdc-watch/internal/eth"
 )
 
 func TestParseAmount(t *testing.T) {
@@ -67,3 +70,62 @@ func TestEncodeBalanceOfCall(t *testing.T) {
 		t.Fatalf("EncodeBalanceOfCall mismatch: got %s, expected %s", data, expected)
 	}
 }
+
+func TestDecodeTransfer(t *testing.T) {
+	log := eth.Log{
+		Topics: []string{
+			TransferTopic,
+			"0x0000000000000000000000000000000000000000000000000000000000000001",
+			"0x0000000000000000000000000000000000000000000000000000000000000002",
+		},
+		Data:        "0x00000000000000000000000000000000000000000000000000000000000f4240",
+		BlockNumber: "0x10",
+		TxHash:      "0xABC",
+		LogIndex:    "0x3",
+	}
+	transfer, err := DecodeTransfer(log)
+	if err != nil {
+		t.Fatalf("DecodeTransfer error: %v", err)
+	}
+	if transfer.From != "0x0000000000000000000000000000000000000001" || transfer.To != "0x0000000000000000000000000000000000000002" {
+		t.Fatalf("unexpected parties: %+v", transfer)
+	}
+	if transfer.Value.Int64() != 1_000_000 || transfer.Block != 16 || transfer.LogIndex != 3 || transfer.TxHash != "0xabc" {
+		t.Fatalf("unexpected transfer fields: %+v", transfer)
+	}
+
+	log.Topics = log.Topics[:1]
+	if _, err := DecodeTransfer(log); err == nil {
+		t.Fatalf("expected error for log without indexed parties")
+	}
+}
+
+func TestDecodeTransferCall(t *testing.T) {
+	const (
+		sender = "0x00000000000000000000000000000000000000a1"
+		owner  = "0x00000000000000000000000000000000000000b0"
+		to     = "0x00000000000000000000000000000000000000c2"
+	)
+	word := func(hex string) string { return strings.Repeat("0", 64-len(hex)) + hex }
+	tx := eth.Transaction{Hash: "0xABC", From: sender, To: ContractAddress, Input: "0xa9059cbb" + word(to[2:]) + word("4c4b40")}
+	transfer, err := DecodeTransferCall(tx)
+	if err != nil || transfer.From != sender || transfer.To != to || transfer.Value.Int64() != 5_000_000 || transfer.TxHash != "0xabc" || transfer.Block != 0 {
+		t.Fatalf("transfer call = %+v, %v", transfer, err)
+	}
+
+	tx.Input = "0x23b872dd" + word(owner[2:]) + word(to[2:]) + word("0f4240")
+	transfer, err = DecodeTransferCall(tx)
+	if err != nil || transfer.From != owner || transfer.To != to || transfer.Value.Int64() != 1_000_000 {
+		t.Fatalf("transferFrom call = %+v, %v", transfer, err)
+	}
+
+	for name, tx := range map[string]eth.Transaction{
+		"approve":   {From: sender, To: ContractAddress, Input: "0x095ea7b3" + word(to[2:]) + word("1")},
+		"truncated": {From: sender, To: ContractAddress, Input: "0xa9059cbb" + word(to[2:])},
+		"other":     {From: sender, To: to, Input: "0xa9059cbb" + word(to[2:]) + word("1")},
+	} {
+		if _, err := DecodeTransferCall(tx); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}